@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/bus"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/debug"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/disasm"
+)
+
+// addBreakpoint parses "[bank:]addr[ if cond]" or "label[ if cond]", e.g. "03:4A2F if A==$10".
+func addBreakpoint(d *debug.Debugger, spec string) error {
+	loc, cond, _ := strings.Cut(spec, " if ")
+	addr, bank, err := d.Resolve(loc)
+	if err != nil {
+		return err
+	}
+	c, err := debug.ParseCondition(cond)
+	if err != nil {
+		return err
+	}
+	bp := d.AddBreakpoint(addr, bank, c)
+	fmt.Printf("breakpoint %d at %s\n", bp.ID, location(d, addr, bank))
+	return nil
+}
+
+// addWatchpoint parses "start[-end][:rwx]" with addresses or labels; the kind defaults to w.
+func addWatchpoint(d *debug.Debugger, spec string) error {
+	rng, kinds, ok := strings.Cut(spec, ":")
+	if !ok {
+		kinds = "w"
+	}
+	lo, hi, _ := strings.Cut(rng, "-")
+	start, _, err := d.Resolve(lo)
+	if err != nil {
+		return err
+	}
+	end := start
+	if hi != "" {
+		if end, _, err = d.Resolve(hi); err != nil {
+			return err
+		}
+	}
+	var kind debug.WatchKind
+	for _, k := range strings.ToLower(kinds) {
+		switch k {
+		case 'r':
+			kind |= debug.WatchRead
+		case 'w':
+			kind |= debug.WatchWrite
+		case 'x':
+			kind |= debug.WatchExec
+		default:
+			return fmt.Errorf("bad watch kind %q (want r, w, x)", k)
+		}
+	}
+	wp := d.AddWatchpoint(start, end, kind)
+	fmt.Printf("watchpoint %d on %04X-%04X:%s\n", wp.ID, wp.Start, wp.End, kinds)
+	return nil
+}
+
+// location formats an address with its bank and, with symbols loaded, its label.
+func location(d *debug.Debugger, addr uint16, bank int) string {
+	loc := fmt.Sprintf("%04X", addr)
+	if bank != debug.AnyBank {
+		loc = fmt.Sprintf("%02X:%04X", bank, addr)
+	}
+	if t := d.Symbols(); t != nil {
+		if s := t.Describe(bank, addr); !strings.HasPrefix(s, "$") {
+			loc += " <" + s + ">"
+		}
+	}
+	return loc
+}
+
+func printStop(d *debug.Debugger, s debug.Stop) {
+	switch s.Reason {
+	case debug.StopBreakpoint:
+		fmt.Printf("\nbreakpoint %d at %s\n", s.Breakpoint.ID, location(d, s.PC, s.Bank))
+	case debug.StopWatchpoint:
+		dir := map[debug.WatchKind]string{debug.WatchRead: "read", debug.WatchWrite: "write", debug.WatchExec: "exec"}[s.Access]
+		fmt.Printf("\nwatchpoint %d: %s %04X=%02X by %s\n", s.Watchpoint.ID, dir, s.Addr, s.Value, location(d, s.PC, s.Bank))
+	default:
+		fmt.Printf("%s at %s\n", s.Reason, location(d, s.PC, s.Bank))
+	}
+}
+
+func printRegs(d *debug.Debugger, c *cpu.CPU, b *bus.Bus) {
+	fmt.Printf("PC=%04X A=%02X F=%02X B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X SP=%04X IME=%t IF=%02X IE=%02X\n",
+		c.PC, c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L, c.SP, c.IME, b.Peek(0xFF0F), b.Peek(0xFFFF))
+	unassemble(d, c, b, "", 1)
+}
+
+const consoleHelp = `commands:
+  c              continue
+  s              step into
+  n              step over (CALL/RST run to completion)
+  o              step out of the current function
+  r              show registers
+  x addr [n]     dump n bytes (default 16)
+  u [addr] [n]   disassemble n instructions (default 8) from addr or PC
+  b [bank:]addr [if cond]   add breakpoint, e.g. b 01:4000 if A==$3
+  w start[-end][:rwx]       add watchpoint, e.g. w C000-C0FF:w
+  d id           delete breakpoint or watchpoint
+  l              list breakpoints and watchpoints
+  q              quit`
+
+// console runs the debugger prompt while d is paused. It returns false to quit.
+func console(d *debug.Debugger, c *cpu.CPU, b *bus.Bus, in *bufio.Reader) bool {
+	printStop(d, d.LastStop())
+	printRegs(d, c, b)
+	for d.Paused() {
+		fmt.Print("(gbdbg) ")
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return false
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		arg = strings.TrimSpace(arg)
+		switch cmd {
+		case "c":
+			d.Continue()
+		case "s":
+			d.StepInto()
+		case "n":
+			d.StepOver()
+		case "o":
+			d.StepOut()
+		case "r":
+			printRegs(d, c, b)
+		case "x":
+			dump(d, b, arg)
+		case "u":
+			unassemble(d, c, b, arg, 8)
+		case "b":
+			if err := addBreakpoint(d, arg); err != nil {
+				fmt.Println(err)
+			}
+		case "w":
+			if err := addWatchpoint(d, arg); err != nil {
+				fmt.Println(err)
+			}
+		case "d":
+			var id int
+			if _, err := fmt.Sscan(arg, &id); err != nil || !d.Remove(id) {
+				fmt.Printf("no breakpoint or watchpoint %q\n", arg)
+			}
+		case "l":
+			for _, bp := range d.Breakpoints() {
+				fmt.Printf("%d: break %s %s hits=%d\n", bp.ID, location(d, bp.Addr, bp.Bank), bp.Cond, bp.Hits)
+			}
+			for _, wp := range d.Watchpoints() {
+				fmt.Printf("%d: watch %04X-%04X kind=%d hits=%d\n", wp.ID, wp.Start, wp.End, wp.Kind, wp.Hits)
+			}
+		case "q":
+			return false
+		case "":
+		default:
+			fmt.Println(consoleHelp)
+		}
+	}
+	return true
+}
+
+func dump(d *debug.Debugger, b *bus.Bus, arg string) {
+	f := strings.Fields(arg)
+	if len(f) == 0 {
+		fmt.Println("x addr [n]")
+		return
+	}
+	addr, _, err := d.Resolve(f[0])
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	n := 16
+	if len(f) > 1 {
+		fmt.Sscan(f[1], &n)
+	}
+	for i := 0; i < n; i++ {
+		if i%16 == 0 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%04X:", addr+uint16(i))
+		}
+		fmt.Printf(" %02X", b.Peek(addr+uint16(i)))
+	}
+	fmt.Println()
+}
+
+// unassemble lists n instructions (or the count in arg) from PC or arg's address,
+// naming labels and operands from the debugger's symbols.
+func unassemble(d *debug.Debugger, c *cpu.CPU, b *bus.Bus, arg string, n int) {
+	f := strings.Fields(arg)
+	addr := c.PC
+	if len(f) > 0 {
+		a, _, err := d.Resolve(f[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		addr = a
+	}
+	if len(f) > 1 {
+		fmt.Sscan(f[1], &n)
+	}
+	var labels disasm.Labels
+	if t := d.Symbols(); t != nil {
+		labels = t
+	}
+	bank := romBank(b, 0x4000)
+	for i := 0; i < n; i++ {
+		if t := d.Symbols(); t != nil {
+			if name, ok := t.Lookup(bank, addr); ok {
+				fmt.Printf("%s:\n", name)
+			}
+		}
+		in := disasm.Decode(b.Peek, addr)
+		fmt.Printf("  %04X  %s\n", addr, in.Format(labels, bank))
+		addr = in.Next()
+	}
+}
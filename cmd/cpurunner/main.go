@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
@@ -12,28 +13,87 @@ import (
 	"time"
 
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/bus"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cart"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/debug"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/disasm"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/symbols"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/trace"
 )
 
-// writerFunc adapts a function to io.Writer
-type writerFunc func(p []byte) (n int, err error)
+// serialCapture is the serial device test ROMs report through. It echoes every byte
+// to out and, when capturing for pattern detection, keeps the whole output plus the
+// most recent bytes in a ring for diagnostics on failure.
+type serialCapture struct {
+	out       io.Writer
+	capture   bool
+	buf       bytes.Buffer
+	ring      []byte
+	idx, fill int
+}
+
+func (s *serialCapture) Ready() bool { return true }
+
+func (s *serialCapture) Exchange(out byte) byte {
+	_, _ = s.out.Write([]byte{out})
+	if s.capture {
+		s.buf.WriteByte(out)
+		s.ring[s.idx] = out
+		s.idx = (s.idx + 1) % len(s.ring)
+		if s.fill < len(s.ring) {
+			s.fill++
+		}
+	}
+	return 0xFF // nothing answers
+}
+
+func (s *serialCapture) Poll(func(byte) byte) {}
 
-func (f writerFunc) Write(p []byte) (n int, err error) { return f(p) }
+func (s *serialCapture) String() string { return s.buf.String() }
+
+// recent returns the last bytes kept in the ring, oldest first.
+func (s *serialCapture) recent() []byte {
+	out := make([]byte, 0, s.fill)
+	for j := 0; j < s.fill; j++ {
+		out = append(out, s.ring[(s.idx-s.fill+j+len(s.ring))%len(s.ring)])
+	}
+	return out
+}
+
+// listFlag collects a repeatable string flag.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
 
 func main() {
 	romPath := flag.String("rom", "", "path to ROM (.gb)")
 	bootPath := flag.String("bootrom", "", "optional DMG boot ROM to run from 0x0000 until FF50 disables it")
 	steps := flag.Int("steps", 5_000_000, "max CPU steps to run")
 	startPC := flag.Int("pc", 0x0100, "initial PC value")
-	trace := flag.Bool("trace", false, "print PC/opcodes")
+	printTrace := flag.Bool("trace", false, "print PC/opcodes")
 	until := flag.String("until", "Passed", "stop when serial output contains this substring (case-insensitive); empty to disable")
 	auto := flag.Bool("auto", false, "auto-detect 'Passed' or 'Failed N tests' in serial output and exit with code 0/1")
 	timeout := flag.Duration("timeout", 0, "optional wall-clock timeout (e.g. 30s, 2m); 0 disables")
 	traceOnFail := flag.Bool("traceOnFail", false, "when -auto detects failure, print a recent trace window (slows down)")
 	traceWindow := flag.Int("traceWindow", 200, "number of recent instructions to include in 'traceOnFail' dump")
 	serialWindowFlag := flag.Int("serialWindow", 8192, "number of recent serial bytes to retain for diagnostics on fail")
+	symPath := flag.String("sym", "", "RGBDS .sym or .map file naming addresses in traces and the debugger (default: <rom>.sym/.map next to the ROM)")
+	coveragePath := flag.String("coverage", "", "write executed instruction addresses (bank:addr per line) to this file on exit, for gbdis -coverage")
+	debugStart := flag.Bool("debug", false, "start paused in the interactive debugger")
+	traceFile := flag.String("tracefile", "", "write a gameboy-doctor format CPU log to this file (\"-\" for stdout)")
+	doctor := flag.Bool("doctor", false, "make LY read $90 as gameboy-doctor logs expect")
+	diffPath := flag.String("diff", "", "compare this gameboy-doctor log with -ref and report the first divergence, then exit")
+	refPath := flag.String("ref", "", "reference log for -diff")
+	diffContext := flag.Int("context", 10, "lines of matching context to print before a -diff divergence")
+	var breaks, watches listFlag
+	flag.Var(&breaks, "break", "breakpoint \"[bank:]addr[ if cond]\", e.g. \"01:4000 if A==$3\" (repeatable); opens the debugger on hit")
+	flag.Var(&watches, "watch", "watchpoint \"start[-end][:rwx]\", e.g. \"C000-C0FF:w\" (repeatable); opens the debugger on hit")
 	flag.Parse()
 
+	if *diffPath != "" {
+		os.Exit(runDiff(*diffPath, *refPath, *diffContext))
+	}
 	if *romPath == "" {
 		log.Fatal("-rom is required")
 	}
@@ -41,6 +101,15 @@ func main() {
 	if err != nil {
 		log.Fatalf("read rom: %v", err)
 	}
+	if *symPath == "" {
+		*symPath = symbols.Find(*romPath)
+	}
+	var syms *symbols.Table
+	if *symPath != "" {
+		if syms, err = symbols.Load(*symPath); err != nil {
+			log.Fatalf("read symbols: %v", err)
+		}
+	}
 	var boot []byte
 	if *bootPath != "" {
 		if b, err := os.ReadFile(*bootPath); err == nil {
@@ -54,36 +123,32 @@ func main() {
 	if len(boot) >= 0x100 {
 		b.SetBootROM(boot)
 	}
-	// Stream serial to stdout and capture in-memory for pattern detection
-	var ser bytes.Buffer
-	// Keep a compact serial ring for last N bytes to print on failure
+	// Stream serial to stdout and capture in-memory for pattern detection, keeping a
+	// compact ring of the last N bytes to print on failure
 	serialWindow := *serialWindowFlag
 	if serialWindow < 256 {
 		serialWindow = 256
 	}
-	serRing := make([]byte, serialWindow)
-	serRingIdx := 0
-	serRingFill := 0
-	w := io.Writer(os.Stdout)
-	if *until != "" || *auto {
-		w = io.MultiWriter(os.Stdout, &ser)
-	}
-	// Wrap writer to also update the ring
-	if *until != "" || *auto {
-		w = io.MultiWriter(os.Stdout, &ser, writerFunc(func(p []byte) (int, error) {
-			for _, ch := range p {
-				serRing[serRingIdx] = ch
-				serRingIdx = (serRingIdx + 1) % serialWindow
-				if serRingFill < serialWindow {
-					serRingFill++
-				}
-			}
-			return len(p), nil
-		}))
-	}
-	b.SetSerialWriter(w)
+	ser := &serialCapture{out: os.Stdout, capture: *until != "" || *auto, ring: make([]byte, serialWindow)}
+	b.SetSerialDevice(ser)
 
 	c := cpu.New(b)
+	var doctorOut *bufio.Writer
+	if *traceFile != "" {
+		out := os.Stdout
+		if *traceFile != "-" {
+			if out, err = os.Create(*traceFile); err != nil {
+				log.Fatalf("trace file: %v", err)
+			}
+			defer out.Close()
+		}
+		doctorOut = bufio.NewWriterSize(out, 1<<16)
+		defer doctorOut.Flush()
+		c.SetTracer(trace.NewDoctor(doctorOut))
+	}
+	if *doctor {
+		b.SetLYOverride(trace.DoctorLY)
+	}
 	if len(boot) >= 0x100 {
 		// Boot ROM path: start at 0x0000; rely on boot to init IO
 		c.SP = 0xFFFE
@@ -110,6 +175,28 @@ func main() {
 		b.Write(0xFFFF, 0x00) // IE
 	}
 
+	var dbg *debug.Debugger
+	var stdin *bufio.Reader
+	if *debugStart || len(breaks) > 0 || len(watches) > 0 {
+		dbg = debug.New()
+		dbg.Attach(c, b)
+		dbg.SetSymbols(syms)
+		stdin = bufio.NewReader(os.Stdin)
+		for _, spec := range breaks {
+			if err := addBreakpoint(dbg, spec); err != nil {
+				log.Fatalf("-break %q: %v", spec, err)
+			}
+		}
+		for _, spec := range watches {
+			if err := addWatchpoint(dbg, spec); err != nil {
+				log.Fatalf("-watch %q: %v", spec, err)
+			}
+		}
+		if *debugStart {
+			dbg.Pause()
+		}
+	}
+
 	start := time.Now()
 	var deadline time.Time
 	if *timeout > 0 {
@@ -131,30 +218,61 @@ func main() {
 		ime                    bool
 		ifreg                  byte
 		ie                     byte
+		text                   string // disassembly
 	}
 	ring := make([]traceEntry, *traceWindow)
+	var cov *disasm.Coverage
+	if *coveragePath != "" {
+		cov = &disasm.Coverage{}
+		defer writeCoverage(cov, *coveragePath)
+	}
+	// exit flushes the coverage and trace logs, which os.Exit would skip
+	exit := func(code int) {
+		if cov != nil {
+			writeCoverage(cov, *coveragePath)
+		}
+		if doctorOut != nil {
+			doctorOut.Flush()
+		}
+		os.Exit(code)
+	}
 	ringIdx := 0
 	ringFill := 0
 	var cycles int
 	for i := 0; i < *steps; i++ {
+		if dbg != nil && dbg.Paused() && !console(dbg, c, b, stdin) {
+			return
+		}
 		pc := c.PC
 		var op byte
-		if *trace || *traceOnFail {
-			op = b.Read(pc)
+		var text string
+		if *printTrace || *traceOnFail {
+			op = b.Peek(pc)
+			in := disasm.Decode(b.Peek, pc)
+			if syms != nil {
+				bank := romBank(b, pc)
+				text = syms.Describe(bank, pc) + ": " + in.Format(syms, bank)
+			} else {
+				text = in.String()
+			}
+		}
+		if cov != nil {
+			cov.Add(romBank(b, pc), pc)
 		}
 		cyc := c.Step()
 		cycles += cyc
-		if *trace || *traceOnFail {
+		if *printTrace || *traceOnFail {
 			te := traceEntry{
 				pc:  pc,
 				op:  op,
 				cyc: cyc,
 				a:   c.A, f: c.F, b: c.B, c: c.C, d: c.D, e: c.E, h: c.H, l: c.L,
-				sp: c.SP, ime: c.IME, ifreg: b.Read(0xFF0F), ie: b.Read(0xFFFF),
+				sp: c.SP, ime: c.IME, ifreg: b.Peek(0xFF0F), ie: b.Peek(0xFFFF),
+				text: text,
 			}
-			if *trace {
-				fmt.Printf("PC=%04X OP=%02X cyc=%d A=%02X F=%02X B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X SP=%04X IME=%t IF=%02X IE=%02X\n",
-					te.pc, te.op, te.cyc, te.a, te.f, te.b, te.c, te.d, te.e, te.h, te.l, te.sp, te.ime, te.ifreg, te.ie)
+			if *printTrace {
+				fmt.Printf("PC=%04X OP=%02X cyc=%d A=%02X F=%02X B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X SP=%04X IME=%t IF=%02X IE=%02X ; %s\n",
+					te.pc, te.op, te.cyc, te.a, te.f, te.b, te.c, te.d, te.e, te.h, te.l, te.sp, te.ime, te.ifreg, te.ie, te.text)
 			}
 			if *traceOnFail && *traceWindow > 0 {
 				ring[ringIdx] = te
@@ -175,7 +293,7 @@ func main() {
 					fmt.Printf("Last stage seen: %s\n", lastStage)
 				}
 				fmt.Printf("\nDone: steps=%d cycles~=%d elapsed=%s\n", i+1, cycles, time.Since(start).Truncate(time.Millisecond))
-				os.Exit(0)
+				exit(0)
 			}
 			if m := failRe.FindStringSubmatch(s); m != nil {
 				fmt.Printf("\nDetected %s in serial output.\n", m[0])
@@ -189,22 +307,20 @@ func main() {
 					for j := 0; j < ringFill; j++ {
 						idx := (startIdx + j) % *traceWindow
 						te := ring[idx]
-						fmt.Printf("PC=%04X OP=%02X cyc=%d A=%02X F=%02X B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X SP=%04X IME=%t IF=%02X IE=%02X\n",
-							te.pc, te.op, te.cyc, te.a, te.f, te.b, te.c, te.d, te.e, te.h, te.l, te.sp, te.ime, te.ifreg, te.ie)
+						fmt.Printf("PC=%04X OP=%02X cyc=%d A=%02X F=%02X B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X SP=%04X IME=%t IF=%02X IE=%02X ; %s\n",
+							te.pc, te.op, te.cyc, te.a, te.f, te.b, te.c, te.d, te.e, te.h, te.l, te.sp, te.ime, te.ifreg, te.ie, te.text)
 					}
 					fmt.Printf("--- end trace ---\n")
 				}
-				if serRingFill > 0 {
-					fmt.Printf("\n--- recent serial (last %d bytes) ---\n", serRingFill)
-					start := (serRingIdx - serRingFill + serialWindow) % serialWindow
-					for j := 0; j < serRingFill; j++ {
-						idx := (start + j) % serialWindow
-						fmt.Printf("%c", serRing[idx])
+				if recent := ser.recent(); len(recent) > 0 {
+					fmt.Printf("\n--- recent serial (last %d bytes) ---\n", len(recent))
+					for _, ch := range recent {
+						fmt.Printf("%c", ch)
 					}
 					fmt.Printf("\n--- end serial ---\n")
 				}
 				fmt.Printf("\nDone: steps=%d cycles~=%d elapsed=%s\n", i+1, cycles, time.Since(start).Truncate(time.Millisecond))
-				os.Exit(1)
+				exit(1)
 			}
 		} else if *until != "" {
 			if strings.Contains(strings.ToLower(ser.String()), strings.ToLower(*until)) {
@@ -216,9 +332,64 @@ func main() {
 		if !deadline.IsZero() && time.Now().After(deadline) {
 			fmt.Printf("\nTimeout after %s.\n", time.Since(start).Truncate(time.Millisecond))
 			fmt.Printf("\nDone: steps=%d cycles~=%d elapsed=%s\n", i+1, cycles, time.Since(start).Truncate(time.Millisecond))
-			os.Exit(2)
+			exit(2)
 		}
 	}
 	dur := time.Since(start)
 	fmt.Printf("\nDone: steps=%d cycles~=%d elapsed=%s\n", *steps, cycles, dur.Truncate(time.Millisecond))
 }
+
+// romBank returns the bank an address executes from, for coverage logs.
+func romBank(b *bus.Bus, pc uint16) int {
+	if pc < 0x4000 {
+		return 0
+	}
+	if br, ok := b.Cart().(cart.BankedROM); ok {
+		return br.ROMBank()
+	}
+	return 1
+}
+
+func writeCoverage(cov *disasm.Coverage, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Printf("coverage: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := cov.WriteTo(f); err != nil {
+		log.Printf("coverage: %v", err)
+	}
+}
+
+// runDiff compares two gameboy-doctor logs and returns the exit code: 0 when they
+// match, 1 at a divergence, 2 on error.
+func runDiff(oursPath, refPath string, context int) int {
+	if refPath == "" {
+		log.Print("-diff needs -ref")
+		return 2
+	}
+	ours, err := os.Open(oursPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	defer ours.Close()
+	ref, err := os.Open(refPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	defer ref.Close()
+	d, err := trace.Diff(ours, ref, context)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	if d == nil {
+		fmt.Println("logs match")
+		return 0
+	}
+	fmt.Print(d)
+	return 1
+}
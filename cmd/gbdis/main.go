@@ -0,0 +1,112 @@
+// Command gbdis disassembles Game Boy ROMs into RGBDS source.
+//
+//	gbdis -rom game.gb                       all banks
+//	gbdis -rom game.gb -bank 2               one bank
+//	gbdis -rom game.gb -start 0150 -end 01FF a range in bank 0 (or -bank's window)
+//
+// -sym names addresses from an RGBDS .sym or .map file (found next to the ROM by default). -coverage takes a log written by
+// "cpurunner -coverage" and emits everything that never executed as data.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/debug"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/disasm"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/romfile"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/symbols"
+)
+
+func main() {
+	romPath := flag.String("rom", "", "path to ROM (.gb/.gbc, or a .zip/.gz holding one)")
+	bank := flag.Int("bank", -1, "ROM bank to disassemble; -1 for all (or the bank holding -start)")
+	startFlag := flag.String("start", "", "first address (hex); defaults to the start of the bank")
+	endFlag := flag.String("end", "", "last address (hex, inclusive); defaults to the end of the bank")
+	symPath := flag.String("sym", "", "RGBDS .sym or .map file for labels (default: <rom>.sym/.map next to the ROM)")
+	covPath := flag.String("coverage", "", "coverage log (bank:addr per line) separating code from data")
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	if *romPath == "" {
+		log.Fatal("-rom is required")
+	}
+	rom, err := romfile.Read(*romPath)
+	if err != nil {
+		log.Fatalf("read rom: %v", err)
+	}
+	var opt disasm.Options
+	if *symPath == "" {
+		*symPath = symbols.Find(*romPath)
+	}
+	if *symPath != "" {
+		t, err := symbols.Load(*symPath)
+		if err != nil {
+			log.Fatalf("read sym: %v", err)
+		}
+		opt.Labels = t
+	}
+	if *covPath != "" {
+		f, err := os.Open(*covPath)
+		if err != nil {
+			log.Fatalf("read coverage: %v", err)
+		}
+		opt.Coverage, err = disasm.ReadCoverage(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// -start/-end take addresses, "bank:addr" or labels
+	syms, _ := opt.Labels.(*symbols.Table)
+	start, end, startBank := uint16(0), uint16(0xFFFF), 0
+	if *startFlag != "" {
+		if start, startBank, err = debug.ResolveLocation(syms, *startFlag); err != nil {
+			log.Fatalf("-start: %v", err)
+		}
+	}
+	if *endFlag != "" {
+		if end, _, err = debug.ResolveLocation(syms, *endFlag); err != nil {
+			log.Fatalf("-end: %v", err)
+		}
+	}
+	banks := (len(rom) + 0x3FFF) / 0x4000
+	first, last := 0, banks-1
+	switch {
+	case *bank >= 0:
+		if *bank >= banks {
+			log.Fatalf("-bank %d: ROM has %d banks", *bank, banks)
+		}
+		first, last = *bank, *bank
+	case *startFlag != "":
+		// A range without -bank: bank 0 for 0000-3FFF, else the bank in "bank:addr" or 1
+		first = 0
+		if start >= 0x4000 {
+			first = 1
+			if startBank > 0 {
+				first = startBank
+			}
+		}
+		last = first
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	for b := first; b <= last; b++ {
+		if b > first {
+			w.WriteString("\n")
+		}
+		if err := disasm.WriteBank(w, rom, b, start, end, opt); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"hash/crc32"
 	"image"
 	"image/png"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +16,11 @@ import (
 
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cart"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/emu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/link"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/patch"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/printer"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/romfile"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/symbols"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/ui"
 )
 
@@ -24,8 +31,18 @@ type CLIFlags struct {
 	Scale        int
 	Title        string
 	Trace        bool
-	SaveRAM      bool // persist battery RAM next to ROM (.sav)
-	UseFetcherBG bool // render BG using fetcher/FIFO path
+	TraceFile    string // write the CPU trace here instead of stdout (implies Trace)
+	Doctor       bool   // gameboy-doctor mode: LY reads $90
+	SaveRAM      bool   // persist battery RAM next to ROM (.sav)
+	UseFetcherBG bool   // render BG using fetcher/FIFO path
+	ClassicPPU   bool   // draw frames from per-line snapshots instead of the PPU's pixel FIFO
+	Camera       string // image file or directory of frames for the Pocket Camera sensor
+	Patch        string // IPS/BPS/UPS patch; defaults to <rom>.bps/.ups/.ips next to the ROM
+	Symbols      string // RGBDS .sym/.map file; defaults to <rom>.sym/.map next to the ROM
+	Model        string // hardware model (see emu.ParseModel)
+	LinkListen   string // wait for a link cable partner on this address
+	LinkConnect  string // connect the link cable to a partner at host:port
+	Printer      string // plug a Game Boy Printer into the serial port, printing into this directory
 
 	// headless
 	Headless bool
@@ -36,14 +53,24 @@ type CLIFlags struct {
 
 func parseFlags() CLIFlags {
 	var f CLIFlags
-	flag.StringVar(&f.ROMPath, "rom", "", "path to ROM (.gb)")
+	flag.StringVar(&f.ROMPath, "rom", "", "path to ROM (.gb/.gbc, or a .zip/.gz archive)")
 	flag.StringVar(&f.BootROM, "bootrom", "", "optional DMG boot ROM")
 	flag.StringVar(&f.CGBBootROM, "cgbboot", "", "optional CGB boot ROM")
 	flag.IntVar(&f.Scale, "scale", 3, "window scale")
 	flag.StringVar(&f.Title, "title", "gbemu", "window title")
-	flag.BoolVar(&f.Trace, "trace", false, "CPU trace log")
+	flag.BoolVar(&f.Trace, "trace", false, "CPU trace log in gameboy-doctor format")
+	flag.StringVar(&f.TraceFile, "tracefile", "", "write the CPU trace log to this file (implies -trace)")
+	flag.BoolVar(&f.Doctor, "doctor", false, "make LY read $90 as gameboy-doctor logs expect")
 	flag.BoolVar(&f.SaveRAM, "save", true, "persist battery RAM to ROM.sav on exit and load on start")
 	flag.BoolVar(&f.UseFetcherBG, "usefetcherbg", false, "render BG via fetcher/FIFO (experimental)")
+	flag.BoolVar(&f.ClassicPPU, "classicppu", false, "draw frames from per-line register snapshots instead of the PPU pixel FIFO (no mid-scanline effects)")
+	flag.StringVar(&f.Patch, "patch", "", "IPS/BPS/UPS patch to apply (default: <rom>.bps/.ups/.ips next to the ROM)")
+	flag.StringVar(&f.Symbols, "sym", "", "RGBDS .sym or .map file for debugging output (default: <rom>.sym/.map next to the ROM)")
+	flag.StringVar(&f.Model, "model", "auto", "hardware model: auto, dmg0, dmg, mgb, sgb, sgb2, cgb or agb")
+	flag.StringVar(&f.Camera, "camera", "", "PNG/JPEG file or directory of frames fed to the Game Boy Camera sensor")
+	flag.StringVar(&f.LinkListen, "link-listen", "", "wait for a link cable partner on this address (e.g. :5000)")
+	flag.StringVar(&f.LinkConnect, "link-connect", "", "connect the link cable to a partner at host:port")
+	flag.StringVar(&f.Printer, "printer", "", "plug in a Game Boy Printer that writes its prints as PNGs to this directory")
 
 	// headless options
 	flag.BoolVar(&f.Headless, "headless", false, "run without a window")
@@ -65,7 +92,8 @@ func runHeadless(m *emu.Machine, frames int, pngPath, expectCRC string) error {
 	}
 	dur := time.Since(start)
 
-	fb := m.Framebuffer() // RGBA 160x144*4
+	fb := m.Framebuffer() // RGBA, 160x144 or 256x224 with the SGB border
+	w, h := m.FrameSize()
 	crc := crc32.ChecksumIEEE(fb)
 	fps := float64(frames) / dur.Seconds()
 
@@ -73,7 +101,7 @@ func runHeadless(m *emu.Machine, frames int, pngPath, expectCRC string) error {
 		frames, dur.Truncate(time.Millisecond), fps, crc)
 
 	if pngPath != "" {
-		if err := saveFramePNG(fb, 160, 144, pngPath); err != nil {
+		if err := saveFramePNG(fb, w, h, pngPath); err != nil {
 			return fmt.Errorf("write PNG: %w", err)
 		}
 		log.Printf("wrote %s", pngPath)
@@ -116,11 +144,34 @@ func mustRead(path string) []byte {
 	return b
 }
 
+// mustReadROM reads a ROM, unpacking the first .gb/.gbc entry of a zip or gzip archive.
+func mustReadROM(path string) []byte {
+	b, err := romfile.Read(path)
+	if err != nil {
+		log.Fatalf("read %s: %v", path, err)
+	}
+	return b
+}
+
 func main() {
 	f := parseFlags()
+	// Files next to the ROM are named after the entry loaded from a zip with several ROMs
+	name := romfile.NamePath(f.ROMPath, "")
 	var rom []byte
 	if f.ROMPath != "" {
-		rom = mustRead(f.ROMPath)
+		rom = mustReadROM(f.ROMPath)
+		pp := f.Patch
+		if pp == "" {
+			pp = patch.Find(name)
+		}
+		if pp != "" {
+			patched, err := patch.ApplyFile(rom, pp)
+			if err != nil {
+				log.Fatalf("apply patch: %v", err)
+			}
+			rom = patched
+			log.Printf("applied patch %s", pp)
+		}
 	}
 	boot := mustRead(f.BootROM)
 	cgbBoot := mustRead(f.CGBBootROM)
@@ -131,45 +182,111 @@ func main() {
 		}
 	}
 
+	var traceOut *bufio.Writer
+	if f.Trace || f.TraceFile != "" {
+		out := os.Stdout
+		if f.TraceFile != "" {
+			tf, err := os.Create(f.TraceFile)
+			if err != nil {
+				log.Fatalf("trace file: %v", err)
+			}
+			defer tf.Close()
+			out = tf
+		}
+		traceOut = bufio.NewWriterSize(out, 1<<16)
+		defer traceOut.Flush()
+	}
+	model, err := emu.ParseModel(f.Model)
+	if err != nil {
+		log.Fatal(err)
+	}
 	emuCfg := emu.Config{
-		Trace:        f.Trace,
+		Model:        model,
+		Trace:        traceOut != nil,
+		DoctorLY:     f.Doctor,
 		LimitFPS:     false, // headless wants max speed
 		UseFetcherBG: f.UseFetcherBG,
+		ClassicPPU:   f.ClassicPPU,
+	}
+	if traceOut != nil {
+		emuCfg.TraceOut = traceOut
 	}
 	m := emu.New(emuCfg)
 	if len(boot) >= 0x100 {
 		m.SetBootROM(boot)
 	}
+	if f.Camera != "" {
+		src, err := cart.OpenCameraSource(f.Camera)
+		if err != nil {
+			log.Fatalf("camera source: %v", err)
+		}
+		m.SetCameraSource(src)
+	}
 	if len(cgbBoot) >= 0x800 {
 		m.SetCGBBootROM(cgbBoot)
 	}
+	if conn := dialLink(f); conn != nil {
+		defer conn.Close()
+		m.SetSerialDevice(conn)
+	} else if f.Printer != "" {
+		if err := os.MkdirAll(f.Printer, 0o755); err != nil {
+			log.Fatalf("printer: %v", err)
+		}
+		p := printer.New(f.Printer)
+		p.OnPrint = func(path string, err error) {
+			if err != nil {
+				log.Printf("printer: %v", err)
+			} else {
+				log.Printf("printer: wrote %s", path)
+			}
+		}
+		defer p.Close()
+		m.SetSerialDevice(p)
+	}
 	if len(rom) > 0 {
 		if err := m.LoadCartridge(rom, boot); err != nil {
 			log.Fatalf("load cart: %v", err)
 		}
 		// Mark ROM path on the machine so UI knows a game is loaded
 		if f.ROMPath != "" {
-			if abs, err := filepath.Abs(f.ROMPath); err == nil {
+			if abs, err := filepath.Abs(name); err == nil {
 				m.SetROMPath(abs)
 			} else {
-				m.SetROMPath(f.ROMPath)
+				m.SetROMPath(name)
+			}
+		}
+		sp := f.Symbols
+		if sp == "" && f.ROMPath != "" {
+			sp = symbols.Find(name)
+		}
+		if sp != "" {
+			if err := m.LoadSymbols(sp); err != nil {
+				log.Printf("symbols: %v", err)
+			} else {
+				log.Printf("loaded %d symbols from %s", m.Symbols().Len(), sp)
 			}
 		}
 	}
+	// On a crash, log where the emulated CPU was before re-panicking
+	defer func() {
+		if r := recover(); r != nil {
+			if traceOut != nil {
+				traceOut.Flush()
+			}
+			log.Printf("crash: %v\n%s", r, m.CrashReport())
+			panic(r)
+		}
+	}()
 	// Choose boot ROM based on ROM header and current CGB toggle
 	// Prefer CGB boot for CGB-capable ROMs when CGB colors are enabled; else DMG boot if available.
 	if h, err := cart.ParseHeader(rom); err == nil {
-		// CGB-only carts have CGBFlag 0xC0; dual carts have 0x80
-		cgbOnly := (h.CGBFlag & 0xC0) == 0xC0
-		useCGB := (h.CGBFlag & 0x80) != 0
-		// Honor user toggle for dual carts; force CGB for CGB-only
-		if cgbOnly {
-			useCGB = true
-		} else if !m.UseCGBBG() {
-			useCGB = false
-		}
-		if useCGB {
-			// Our CPU core doesn’t implement the full CGB boot ROM; simulate CGB post-boot instead.
+		// Color games run in CGB mode whenever the model has CGB hardware; with -model auto
+		// that is CGB-only carts always and dual carts while CGB colors are on
+		useCGB := (h.CGBFlag&0x80) != 0 && m.Model().CGB()
+		if (useCGB || m.WantCGBColors()) && m.HasCGBBootROM() {
+			// The CGB boot ROM decides between CGB and compatibility mode itself
+			m.ResetWithCGBBoot()
+		} else if useCGB {
 			m.ResetCGBPostBoot(false)
 		} else if m.WantCGBColors() {
 			// DMG ROM, but user wants CGB colors: run in CGB compatibility mode
@@ -190,7 +307,7 @@ func main() {
 	// Battery RAM: load .sav if present
 	var savPath string
 	if f.SaveRAM && f.ROMPath != "" {
-		savPath = strings.TrimSuffix(f.ROMPath, ".gb") + ".sav"
+		savPath = romfile.SavePath(name)
 		if data, err := os.ReadFile(savPath); err == nil {
 			if m.LoadBattery(data) {
 				log.Printf("loaded save RAM: %s (%d bytes)", savPath, len(data))
@@ -200,6 +317,9 @@ func main() {
 
 	if f.Headless {
 		if err := runHeadless(m, f.Frames, f.PNGOut, f.Expect); err != nil {
+			if traceOut != nil {
+				traceOut.Flush() // log.Fatal skips deferred calls
+			}
 			log.Fatal(err)
 		}
 		if f.SaveRAM && savPath != "" {
@@ -212,7 +332,7 @@ func main() {
 		return
 	}
 
-	uiCfg := ui.Config{Title: f.Title, Scale: f.Scale}
+	uiCfg := ui.Config{Title: f.Title, Scale: f.Scale, ClassicPPU: f.ClassicPPU}
 	app := ui.NewApp(uiCfg, m)
 	if err := app.Run(); err != nil {
 		log.Fatal(err)
@@ -225,8 +345,8 @@ func main() {
 	// UI exit: save battery RAM if enabled (derive path from current ROM if needed)
 	if f.SaveRAM {
 		outSav := savPath
-		if outSav == "" && m.ROMPath() != "" && strings.HasSuffix(strings.ToLower(m.ROMPath()), ".gb") {
-			outSav = strings.TrimSuffix(m.ROMPath(), ".gb") + ".sav"
+		if outSav == "" && m.ROMPath() != "" {
+			outSav = romfile.SavePath(m.ROMPath())
 		}
 		if outSav != "" {
 			if data, ok := m.SaveBattery(); ok {
@@ -237,3 +357,33 @@ func main() {
 		}
 	}
 }
+
+// dialLink connects the link cable as requested by -link-listen or -link-connect.
+func dialLink(f CLIFlags) *link.Conn {
+	var conn *link.Conn
+	var err error
+	switch {
+	case f.LinkListen != "" && f.LinkConnect != "":
+		log.Fatal("use only one of -link-listen and -link-connect")
+	case (f.LinkListen != "" || f.LinkConnect != "") && f.Printer != "":
+		log.Fatal("the link cable and -printer share the serial port; use only one")
+	case f.LinkListen != "":
+		log.Printf("link: waiting for partner on %s", f.LinkListen)
+		conn, err = link.Listen(f.LinkListen)
+	case f.LinkConnect != "":
+		conn, err = link.Dial(f.LinkConnect)
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Fatalf("link: %v", err)
+	}
+	log.Printf("link: connected")
+	go func() {
+		<-conn.Done()
+		if err := conn.Err(); err != net.ErrClosed {
+			log.Printf("link: disconnected: %v", err)
+		}
+	}()
+	return conn
+}
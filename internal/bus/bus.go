@@ -4,18 +4,19 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
-	"io"
 	"os"
 
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/apu"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cart"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/ppu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/sgb"
 )
 
 // Bus wires CPU-visible address space to cartridge, WRAM, HRAM, and IO.
 // This is an early skeleton: IO, OAM, VRAM etc. are stubbed as 0xFF.
 type Bus struct {
-	cart cart.Cartridge
+	cart      cart.Cartridge
+	cartClock cart.Clocked // set when the cartridge has clocked hardware (e.g. camera sensor)
 
 	// Work RAM
 	// DMG: 8 KiB at 0xC000–0xDFFF
@@ -33,6 +34,9 @@ type Bus struct {
 	// APU for audio
 	apu *apu.APU
 
+	// Super Game Boy, when SGB features are on; receives the packets sent through JOYP
+	sgb *sgb.SGB
+
 	// Interrupt registers
 	ie    byte // IE at 0xFFFF
 	ifReg byte // IF at 0xFF0F (lower 5 bits used)
@@ -52,9 +56,17 @@ type Bus struct {
 	timaReloadDelay int // cycles remaining until reload from TMA; 0 means no pending reload
 
 	// Serial
-	sb byte      // FF01 data
-	sc byte      // FF02 control (bit7 start, bit0 clock source; we do immediate external)
-	sw io.Writer // sink for serial output (optional)
+	sb            byte         // FF01 data
+	sc            byte         // FF02 control (bit7 transfer, bit1 CGB fast clock, bit0 internal clock)
+	serialBits    int          // bits shifted so far in the current transfer
+	serial        SerialDevice // what is plugged into the port, nil when nothing is
+	serialIn      byte         // the device's byte, shifted in bit by bit
+	serialReceive func(in byte) byte
+
+	// Infrared (CGB)
+	rp     byte     // FF56: bit0 LED on, bits 6-7 read enable
+	ir     IRDevice // what faces the IR port, nil when nothing does
+	cycles uint64   // normal-speed CPU cycles since power-on (see Cycles)
 
 	// Internal 16-bit divider that increments every T-cycle; DIV reads upper 8 bits
 	divInternal uint16
@@ -67,19 +79,31 @@ type Bus struct {
 	dmaSrc    uint16
 	dmaIndex  int
 
+	// CGB VRAM DMA (HDMA1..5)
+	hdmaSrc    uint16 // source address (low 4 bits clear)
+	hdmaDst    uint16 // destination offset within VRAM (0x0000..0x1FF0)
+	hdmaRemain int    // remaining 16-byte blocks
+	hdmaActive bool   // HBlank DMA in progress
+	stall      int    // CPU cycles to stall for GDMA/HDMA, drained via TakeStall
+	cpuHalted  bool   // HBlank DMA pauses while the CPU is in HALT (set via SetCPUHalted)
+
 	// Boot ROM support
 	bootROM     []byte // DMG boot (0x100)
-	cgbBootROM  []byte // CGB boot (0x800)
+	cgbBootROM  []byte // CGB boot, laid out as mapped: 0000-00FF and 0200-08FF (0x900)
 	bootEnabled bool
 	bootMode    byte // 0=none, 1=DMG, 2=CGB
+	key0        byte // FF4C: written by the CGB boot ROM; bit 2 selects DMG compatibility mode
 
 	// debug
 	debugTimer bool
+	watch      func(addr uint16, v byte, write bool) // observes CPU accesses (see SetWatchHook)
+	lyOverride int                                   // when >= 0, FF44 reads return it (see SetLYOverride)
 
 	// CGB mode exposure
 	cgbMode     bool // if true, expose CGB-only registers and WRAM banking
 	key1        byte // KEY1 prepare bit (bit0); other bits read as per CGB
-	doubleSpeed bool // current speed (ignored, but exposed via KEY1 bit7 when true)
+	doubleSpeed bool // current speed; CPU/timers run 2x relative to PPU/APU when true
+	speedPhase  bool // double speed: toggles each CPU cycle, PPU/APU advance on every other one
 }
 
 // New constructs a Bus with a ROM-only cartridge for convenience.
@@ -89,9 +113,11 @@ func New(rom []byte) *Bus {
 
 // NewWithCartridge wires a provided cartridge implementation.
 func NewWithCartridge(c cart.Cartridge) *Bus {
-	b := &Bus{cart: c}
+	b := &Bus{cart: c, lyOverride: -1}
+	b.cartClock, _ = c.(cart.Clocked)
 	// hook PPU to request IF bits through bus
 	b.ppu = ppu.New(func(bit int) { b.ifReg |= 1 << bit })
+	b.ppu.SetHBlankHook(b.hdmaStep)
 	// APU with default sample rate; UI can pull samples via Machine later
 	b.apu = apu.New(48000)
 	if os.Getenv("GB_DEBUG_TIMER") != "" {
@@ -115,6 +141,7 @@ func (b *Bus) APU() *apu.APU { return b.apu }
 // When enabled, games can detect CGB hardware and will use CGB palette registers.
 func (b *Bus) SetCGBMode(on bool) {
 	b.cgbMode = on
+	b.ppu.SetCGBMode(on)
 	if on {
 		if b.wramBankID == 0 {
 			b.wramBankID = 1
@@ -122,7 +149,35 @@ func (b *Bus) SetCGBMode(on bool) {
 	}
 }
 
+// SetWatchHook installs a callback that sees every Read and Write with the value
+// transferred; nil removes it. DMA transfers, Peek, Poke and Fetch bypass the hook.
+func (b *Bus) SetWatchHook(fn func(addr uint16, v byte, write bool)) { b.watch = fn }
+
+// Read returns the byte the CPU sees at addr.
 func (b *Bus) Read(addr uint16) byte {
+	v := b.read(addr)
+	if b.watch != nil {
+		b.watch(addr, v, false)
+	}
+	return v
+}
+
+// SetLYOverride makes LY (FF44) read as v; -1 restores the real value. Test harnesses
+// such as gameboy-doctor expect LY to read $90 so logs do not depend on PPU timing.
+func (b *Bus) SetLYOverride(v int) { b.lyOverride = v }
+
+// Peek reads like Read without notifying the watch hook, for debuggers and tools.
+func (b *Bus) Peek(addr uint16) byte { return b.read(addr) }
+
+// Fetch reads an instruction byte for the CPU. The watch hook is not notified: fetches
+// are execution, not data reads.
+func (b *Bus) Fetch(addr uint16) byte { return b.read(addr) }
+
+// Poke writes like Write without notifying the watch hook, for the CPU's own register
+// updates such as acknowledging an interrupt.
+func (b *Bus) Poke(addr uint16, value byte) { b.write(addr, value) }
+
+func (b *Bus) read(addr uint16) byte {
 	switch {
 	// Cartridge ROM and External RAM (banked) are handled by the cartridge
 	case addr < 0x8000:
@@ -133,13 +188,9 @@ func (b *Bus) Read(addr uint16) byte {
 					return b.bootROM[addr]
 				}
 			} else if b.bootMode == 2 { // CGB split mapping: 0000-00FF and 0200-08FF
-				if len(b.cgbBootROM) >= 0x800 {
-					if addr < 0x0100 {
-						return b.cgbBootROM[addr]
-					}
-					if addr >= 0x0200 && addr <= 0x08FF {
-						return b.cgbBootROM[0x100+addr-0x0200]
-					}
+				// 0100-01FF is a hole so the boot ROM can read the cartridge header
+				if addr < 0x0100 || (addr >= 0x0200 && addr <= 0x08FF) {
+					return b.cgbBootROM[addr]
 				}
 			}
 		}
@@ -194,6 +245,16 @@ func (b *Bus) Read(addr uint16) byte {
 	case addr == 0xFF00:
 		// Upper bits 7-6 read as 1, bits 5-4 reflect selection, bits 3-0 depend on selected group(s)
 		res := byte(0xC0 | (b.joypSelect & 0x30) | 0x0F)
+		if b.sgb != nil {
+			// SGB multiplayer: with no group selected the low bits give the joypad ID,
+			// and only player 1 has buttons
+			if id, ok := b.sgb.JoypadID(); ok && b.joypSelect&0x30 == 0x30 {
+				return res&0xF0 | id
+			}
+			if b.sgb.Player() != 0 {
+				return res
+			}
+		}
 		// If P14 (bit4) == 0, select D-Pad (Right, Left, Up, Down => bits 0..3)
 		if (b.joypSelect & 0x10) == 0 {
 			// Clear bits for pressed D-Pad buttons (active-low)
@@ -239,14 +300,21 @@ func (b *Bus) Read(addr uint16) byte {
 	case addr == 0xFF01:
 		return b.sb
 	case addr == 0xFF02:
-		// upper bits read as 1 except bit7 reflects transfer in progress; we complete immediately
+		// unused bits read as 1; bit1 (fast clock) only exists on CGB
+		if b.cgbMode {
+			return 0x7C | (b.sc & 0x83)
+		}
 		return 0x7E | (b.sc & 0x81)
+	case addr == 0xFF56: // RP (CGB only)
+		return b.rpRead()
+	case addr == 0xFF44 && b.lyOverride >= 0:
+		return byte(b.lyOverride)
 	// LCDC/STAT/LY/LYC and scroll/window via PPU
 	case addr == 0xFF40, addr == 0xFF41, addr == 0xFF42, addr == 0xFF43,
 		addr == 0xFF44, addr == 0xFF45,
 		addr == 0xFF47, addr == 0xFF48, addr == 0xFF49,
 		addr == 0xFF4A, addr == 0xFF4B, addr == 0xFF4F,
-		addr == 0xFF68, addr == 0xFF69, addr == 0xFF6A, addr == 0xFF6B:
+		addr == 0xFF68, addr == 0xFF69, addr == 0xFF6A, addr == 0xFF6B, addr == 0xFF6C:
 		return b.ppu.CPURead(addr)
 	// CGB-only: KEY1 and SVBK exposure
 	case addr == 0xFF4D: // KEY1 (CGB only)
@@ -270,6 +338,8 @@ func (b *Bus) Read(addr uint16) byte {
 			bank = 1
 		}
 		return 0xF8 | bank
+	case addr >= 0xFF51 && addr <= 0xFF55: // HDMA1..HDMA5 (CGB only)
+		return b.hdmaRead(addr)
 	// APU registers (subset): NR10..NR14, NR21..NR24, NR30..NR34, NR41..NR44, NR50..NR52, and wave RAM FF30..FF3F
 	case addr >= 0xFF10 && addr <= 0xFF14,
 		addr >= 0xFF16 && addr <= 0xFF19,
@@ -297,7 +367,15 @@ func (b *Bus) Read(addr uint16) byte {
 	return 0xFF
 }
 
+// Write stores a CPU write to addr.
 func (b *Bus) Write(addr uint16, value byte) {
+	if b.watch != nil {
+		b.watch(addr, value, true)
+	}
+	b.write(addr, value)
+}
+
+func (b *Bus) write(addr uint16, value byte) {
 	switch {
 	// Cartridge control and external RAM writes
 	case addr < 0x8000:
@@ -359,6 +437,9 @@ func (b *Bus) Write(addr uint16, value byte) {
 	// IO: JOYP at 0xFF00
 	case addr == 0xFF00:
 		b.joypSelect = value & 0x30
+		if b.sgb != nil {
+			b.sgb.WriteJOYP(value)
+		}
 		b.updateJoypadIRQ()
 		return
 	// IO: Timers
@@ -407,16 +488,13 @@ func (b *Bus) Write(addr uint16, value byte) {
 		b.sb = value
 		return
 	case addr == 0xFF02:
-		b.sc = value & 0x81
+		b.sc = value & 0x83
+		if !b.cgbMode {
+			b.sc &= 0x81
+		}
 		if (b.sc & 0x80) != 0 {
-			// Start transfer: we do immediate completion; write byte to sink if present
-			if b.sw != nil {
-				_, _ = b.sw.Write([]byte{b.sb})
-			}
-			// Request serial interrupt (IF bit 3)
-			b.ifReg |= 1 << 3
-			// Clear transfer start bit to indicate done
-			b.sc &^= 0x80
+			// Start transfer: bits shift on the serial clock (see Tick)
+			b.serialStart()
 		}
 		return
 	// LCDC/STAT/LY/LYC and scroll/window via PPU
@@ -424,12 +502,20 @@ func (b *Bus) Write(addr uint16, value byte) {
 		addr == 0xFF44, addr == 0xFF45,
 		addr == 0xFF47, addr == 0xFF48, addr == 0xFF49,
 		addr == 0xFF4A, addr == 0xFF4B, addr == 0xFF4F,
-		addr == 0xFF68, addr == 0xFF69, addr == 0xFF6A, addr == 0xFF6B:
+		addr == 0xFF68, addr == 0xFF69, addr == 0xFF6A, addr == 0xFF6B, addr == 0xFF6C:
 		b.ppu.CPUWrite(addr, value)
 		return
+	case addr == 0xFF4C: // KEY0: only the CGB boot ROM can write it
+		if b.bootEnabled && b.bootMode == 2 {
+			b.key0 = value
+		}
+		return
+	case addr == 0xFF56: // RP (CGB only)
+		b.rpWrite(value)
+		return
 	case addr == 0xFF4D: // KEY1 (CGB only)
 		if b.cgbMode {
-			b.key1 = value & 0x01 // store prepare bit; we don't actually switch speed
+			b.key1 = value & 0x01 // prepare bit; the switch happens on the next STOP
 		}
 		return
 	case addr == 0xFF70: // SVBK (CGB only)
@@ -440,6 +526,9 @@ func (b *Bus) Write(addr uint16, value byte) {
 			}
 		}
 		return
+	case addr >= 0xFF51 && addr <= 0xFF55: // HDMA1..HDMA5 (CGB only)
+		b.hdmaWrite(addr, value)
+		return
 	// APU registers
 	case addr >= 0xFF10 && addr <= 0xFF14,
 		addr >= 0xFF16 && addr <= 0xFF19,
@@ -496,8 +585,11 @@ func (b *Bus) SetJoypadState(mask byte) {
 	b.updateJoypadIRQ()
 }
 
-// SetSerialWriter sets a sink that receives bytes written via the serial port.
-func (b *Bus) SetSerialWriter(w io.Writer) { b.sw = w }
+// SetSGB attaches a Super Game Boy to JOYP, or detaches it with nil.
+func (b *Bus) SetSGB(s *sgb.SGB) { b.sgb = s }
+
+// SGB returns the attached Super Game Boy, or nil.
+func (b *Bus) SGB() *sgb.SGB { return b.sgb }
 
 // SetBootROM loads a DMG boot ROM to be mapped at 0x0000-0x00FF until disabled via 0xFF50 write.
 func (b *Bus) SetBootROM(data []byte) {
@@ -509,19 +601,29 @@ func (b *Bus) SetBootROM(data []byte) {
 	}
 }
 
-// SetCGBBootROM loads a CGB boot ROM (0x900 total mapped as 0x100+0x700) used when boot mode is CGB.
+// SetCGBBootROM loads a CGB boot ROM used when boot mode is CGB. Full 0x900-byte dumps
+// map as they are, with the header hole at 0100-01FF left to the cartridge; 0x800-byte
+// dumps without the hole hold 0000-00FF followed by 0200-08FF.
 func (b *Bus) SetCGBBootROM(data []byte) {
 	b.cgbBootROM = nil
-	if len(data) >= 0x900 { // some dumps include 0x900 with padding; accept >=0x800 too
-		// keep last 0x800 bytes starting at 0x100 if provided 0x900
-		b.cgbBootROM = make([]byte, 0x800)
-		copy(b.cgbBootROM, data[len(data)-0x800:])
-	} else if len(data) >= 0x800 {
-		b.cgbBootROM = make([]byte, 0x800)
-		copy(b.cgbBootROM, data[:0x800])
+	switch {
+	case len(data) >= 0x900:
+		b.cgbBootROM = make([]byte, 0x900)
+		copy(b.cgbBootROM, data[:0x900])
+	case len(data) >= 0x800:
+		b.cgbBootROM = make([]byte, 0x900)
+		copy(b.cgbBootROM, data[:0x100])
+		copy(b.cgbBootROM[0x200:], data[0x100:0x800])
 	}
 }
 
+// BootROMActive reports whether a boot ROM is still mapped (FF50 not yet written).
+func (b *Bus) BootROMActive() bool { return b.bootEnabled }
+
+// DMGCompatMode reports whether the CGB boot ROM selected DMG compatibility mode
+// through KEY0 for a game without CGB support.
+func (b *Bus) DMGCompatMode() bool { return b.key0&0x04 != 0 }
+
 // EnableBoot selects and enables the boot ROM overlay; mode: 1=DMG, 2=CGB; any other disables.
 func (b *Bus) EnableBoot(mode byte) {
 	if mode == 1 && len(b.bootROM) >= 0x100 {
@@ -529,16 +631,55 @@ func (b *Bus) EnableBoot(mode byte) {
 		b.bootMode = 1
 		return
 	}
-	if mode == 2 && len(b.cgbBootROM) >= 0x800 {
+	if mode == 2 && len(b.cgbBootROM) >= 0x900 {
 		b.bootEnabled = true
 		b.bootMode = 2
+		b.key0 = 0
 		return
 	}
 	b.bootEnabled = false
 	b.bootMode = 0
 }
 
+// speedSwitchCycles is how long the CPU stays stopped while the clock changes speed
+// (2050 M-cycles).
+const speedSwitchCycles = 8200
+
+// SpeedSwitch is called by the CPU when it executes STOP. If KEY1's prepare bit is
+// armed in CGB mode, it toggles between normal and double speed, clears the prepare
+// bit, resets DIV and returns the number of cycles the switch takes. Otherwise it
+// returns 0 and STOP behaves as before.
+func (b *Bus) SpeedSwitch() int {
+	if !b.cgbMode || (b.key1&0x01) == 0 {
+		return 0
+	}
+	b.doubleSpeed = !b.doubleSpeed
+	b.speedPhase = false
+	b.key1 = 0
+	b.write(0xFF04, 0)
+	return speedSwitchCycles
+}
+
+// SetDivider sets the internal divider whose high byte is DIV, e.g. to the phase a
+// boot ROM leaves behind. Unlike a DIV write it does not clock TIMA.
+func (b *Bus) SetDivider(v uint16) {
+	b.divInternal = v
+	b.div = byte(v >> 8)
+}
+
+// DoubleSpeed reports whether the CGB CPU currently runs at double speed.
+func (b *Bus) DoubleSpeed() bool { return b.doubleSpeed }
+
+// ResetSpeed returns the CPU to normal speed and clears KEY1, as a console reset does.
+func (b *Bus) ResetSpeed() {
+	b.doubleSpeed = false
+	b.speedPhase = false
+	b.key1 = 0
+}
+
 // Tick advances timers by the given number of CPU cycles.
+// In CGB double-speed mode the timers, DIV and OAM DMA follow the CPU clock
+// while the PPU and APU only advance on every second cycle.
 // True-to-hardware: TIMA increments on falling edge of selected divider bit
 // determined by TAC (00:bit9, 01:bit3, 10:bit5, 11:bit7), gated by TAC enable.
 func (b *Bus) Tick(cycles int) {
@@ -547,6 +688,7 @@ func (b *Bus) Tick(cycles int) {
 	}
 	for i := 0; i < cycles; i++ {
 		oldInput := b.timerInput()
+		oldDiv := b.divInternal
 		b.divInternal++
 		b.div = byte(b.divInternal >> 8)
 		newInput := b.timerInput()
@@ -566,19 +708,39 @@ func (b *Bus) Tick(cycles int) {
 		if falling {
 			b.incrementTIMA()
 		}
+		// The internal serial clock also comes off the divider: 8192 Hz, or 262144 Hz
+		// with the CGB fast clock (both twice as fast in double speed)
+		if b.sc&0x81 == 0x81 {
+			bit := uint16(1 << 8)
+			if b.sc&0x02 != 0 {
+				bit = 1 << 3
+			}
+			if oldDiv&bit != 0 && b.divInternal&bit == 0 {
+				b.serialClock()
+			}
+		}
+		// In double speed only every other CPU cycle is a PPU/APU dot
+		dot := true
+		if b.doubleSpeed {
+			b.speedPhase = !b.speedPhase
+			dot = !b.speedPhase
+		}
+		if dot {
+			b.cycles++
+		}
 		// Tick PPU via module
-		if b.ppu != nil {
+		if dot && b.ppu != nil {
 			b.ppu.Tick(1)
 		}
 		// Tick APU via module
-		if b.apu != nil {
+		if dot && b.apu != nil {
 			b.apu.Tick(1)
 		}
 
 		// Step OAM DMA (1 byte per cycle) if active
 		if b.dmaActive {
 			if b.dmaIndex < 0xA0 {
-				v := b.Read(b.dmaSrc + uint16(b.dmaIndex))
+				v := b.read(b.dmaSrc + uint16(b.dmaIndex))
 				b.ppu.CPUWrite(0xFE00+uint16(b.dmaIndex), v)
 				b.dmaIndex++
 			}
@@ -587,6 +749,12 @@ func (b *Bus) Tick(cycles int) {
 			}
 		}
 	}
+	if b.cartClock != nil {
+		b.cartClock.Tick(cycles)
+	}
+	if b.serial != nil && b.sc&0x81 == 0x80 && b.serial.Ready() {
+		b.serial.Poll(b.serialReceive)
+	}
 }
 
 // timerInput computes the current timer clock input (after TAC gating).
@@ -682,18 +850,31 @@ type busState struct {
 	TAC       byte
 	TIMARelay int
 	SB, SC    byte
+	SerBits   int
+	SerIn     byte
 	DivInt    uint16
 	DMA       byte
 	DMAActive bool
 	DMASrc    uint16
 	DMAIdx    int
 	BootEn    bool
+	BootMode  byte
+	KEY0      byte
 	// CGB-related state
 	CGBMode     bool
 	WRAMBankID  byte
 	KEY1        byte
 	DoubleSpeed bool
-	APU         []byte
+	// CGB VRAM DMA
+	HDMASrc    uint16
+	HDMADst    uint16
+	HDMARemain int
+	HDMAActive bool
+	Stall      int
+	SpeedPhase bool
+	RP         byte
+	Cycles     uint64
+	APU        []byte
 	// PPU and cartridge will handle their own state via their interfaces
 }
 
@@ -705,13 +886,23 @@ func (b *Bus) SaveState() []byte {
 		IE: b.ie, IF: b.ifReg,
 		JoypSel: b.joypSelect, Joypad: b.joypad, JoypL4: b.joypLower4,
 		DIV: b.div, TIMA: b.tima, TMA: b.tma, TAC: b.tac, TIMARelay: b.timaReloadDelay,
-		SB: b.sb, SC: b.sc, DivInt: b.divInternal,
+		SB: b.sb, SC: b.sc, SerBits: b.serialBits, SerIn: b.serialIn, DivInt: b.divInternal,
 		DMA: b.dma, DMAActive: b.dmaActive, DMASrc: b.dmaSrc, DMAIdx: b.dmaIndex,
 		BootEn:      b.bootEnabled,
+		BootMode:    b.bootMode,
+		KEY0:        b.key0,
 		CGBMode:     b.cgbMode,
 		WRAMBankID:  b.wramBankID,
 		KEY1:        b.key1,
 		DoubleSpeed: b.doubleSpeed,
+		HDMASrc:     b.hdmaSrc,
+		HDMADst:     b.hdmaDst,
+		HDMARemain:  b.hdmaRemain,
+		HDMAActive:  b.hdmaActive,
+		Stall:       b.stall,
+		SpeedPhase:  b.speedPhase,
+		RP:          b.rp,
+		Cycles:      b.cycles,
 	}
 	_ = enc.Encode(s)
 	// Append PPU and Cart states after a simple header so we can restore later
@@ -736,6 +927,12 @@ func (b *Bus) SaveState() []byte {
 	} else {
 		_ = enc.Encode([]byte(nil))
 	}
+	// SGB state
+	if b.sgb != nil {
+		_ = enc.Encode(b.sgb.SaveState())
+	} else {
+		_ = enc.Encode([]byte(nil))
+	}
 	return buf.Bytes()
 }
 
@@ -751,17 +948,22 @@ func (b *Bus) LoadState(data []byte) {
 	b.ie, b.ifReg = s.IE, s.IF
 	b.joypSelect, b.joypad, b.joypLower4 = s.JoypSel, s.Joypad, s.JoypL4
 	b.div, b.tima, b.tma, b.tac, b.timaReloadDelay = s.DIV, s.TIMA, s.TMA, s.TAC, s.TIMARelay
-	b.sb, b.sc, b.divInternal = s.SB, s.SC, s.DivInt
+	b.sb, b.sc, b.serialBits, b.serialIn, b.divInternal = s.SB, s.SC, s.SerBits, s.SerIn, s.DivInt
 	b.dma, b.dmaActive, b.dmaSrc, b.dmaIndex = s.DMA, s.DMAActive, s.DMASrc, s.DMAIdx
-	b.bootEnabled = s.BootEn
+	b.bootEnabled, b.bootMode, b.key0 = s.BootEn, s.BootMode, s.KEY0
 	// Restore CGB exposure and related flags exactly as saved
 	b.cgbMode = s.CGBMode
+	b.ppu.SetCGBMode(s.CGBMode)
 	b.wramBankID = s.WRAMBankID
 	if b.wramBankID == 0 {
 		b.wramBankID = 1
 	}
 	b.key1 = s.KEY1
 	b.doubleSpeed = s.DoubleSpeed
+	b.hdmaSrc, b.hdmaDst, b.hdmaRemain, b.hdmaActive = s.HDMASrc, s.HDMADst, s.HDMARemain, s.HDMAActive
+	b.stall = s.Stall
+	b.speedPhase = s.SpeedPhase
+	b.rp, b.cycles = s.RP, s.Cycles
 	// PPU
 	var ps []byte
 	if err := dec.Decode(&ps); err == nil && b.ppu != nil {
@@ -779,4 +981,9 @@ func (b *Bus) LoadState(data []byte) {
 			bb.LoadState(cs)
 		}
 	}
+	// SGB
+	var ss []byte
+	if err := dec.Decode(&ss); err == nil && b.sgb != nil && len(ss) > 0 {
+		b.sgb.LoadState(ss)
+	}
 }
@@ -106,7 +106,7 @@ func TestBus_JOYP_And_Timers(t *testing.T) {
 	}
 }
 
-func TestBus_SerialImmediate(t *testing.T) {
+func TestBus_SerialInternalClock(t *testing.T) {
 	b := New(make([]byte, 0x8000))
 	var out []byte
 	b.SetSerialWriter(writerFunc(func(p []byte) (int, error) {
@@ -115,16 +115,174 @@ func TestBus_SerialImmediate(t *testing.T) {
 	}))
 
 	b.Write(0xFF01, 0x41) // 'A'
-	b.Write(0xFF02, 0x81) // start, external clock
+	b.Write(0xFF02, 0x81) // start, internal clock
 	if len(out) != 1 || out[0] != 0x41 {
 		t.Fatalf("serial out got %v want [0x41]", out)
 	}
+	b.Tick(7 * 512)
+	if got := b.Read(0xFF02); got&0x80 == 0 {
+		t.Fatalf("transfer done after 7 bits at 8192 Hz")
+	}
+	b.Tick(512)
 	if got := b.Read(0xFF02); (got & 0x80) != 0 { // transfer done => bit7 cleared
 		t.Fatalf("serial control bit7 not cleared: %02x", got)
 	}
 	if (b.Read(0xFF0F) & (1 << 3)) == 0 { // IF bit3 set
 		t.Fatalf("serial IF bit not set after transfer")
 	}
+	if got := b.Read(0xFF01); got != 0xFF {
+		t.Fatalf("SB without a partner got %02x want ff", got)
+	}
+
+	// External clock with nothing plugged in never completes
+	b.Write(0xFF02, 0x80)
+	b.Tick(16 * 512)
+	if got := b.Read(0xFF02); got&0x80 == 0 {
+		t.Fatalf("external-clock transfer completed without a partner")
+	}
+}
+
+func TestBus_SerialLinkCable(t *testing.T) {
+	master, slave := New(make([]byte, 0x8000)), New(make([]byte, 0x8000))
+	master.SetCGBMode(true)
+	master.ConnectSerial(slave)
+	slave.Write(0xFF01, 0x5A)
+	slave.Write(0xFF02, 0x80)
+	master.Write(0xFF01, 0xC3)
+	master.Write(0xFF02, 0x83) // CGB fast clock: one bit per 16 cycles
+	master.Tick(8 * 16)
+	if master.Read(0xFF01) != 0x5A || slave.Read(0xFF01) != 0xC3 {
+		t.Fatalf("exchanged master=%02x slave=%02x, want 5a/c3", master.Read(0xFF01), slave.Read(0xFF01))
+	}
+	if master.Read(0xFF02)&0x80 != 0 || slave.Read(0xFF02)&0x80 != 0 {
+		t.Fatalf("transfer still running: master SC=%02x slave SC=%02x", master.Read(0xFF02), slave.Read(0xFF02))
+	}
+	if master.Read(0xFF0F)&0x08 == 0 || slave.Read(0xFF0F)&0x08 == 0 {
+		t.Fatalf("serial interrupt missing on one side")
+	}
+}
+
+// scriptedDevice answers each byte from a script and, when clock is set, drives the
+// clock itself to send its next byte.
+type scriptedDevice struct {
+	script   []byte
+	sent     []byte
+	clock    bool
+	notReady bool
+}
+
+func (d *scriptedDevice) Ready() bool { return !d.notReady }
+
+func (d *scriptedDevice) Exchange(out byte) byte {
+	d.sent = append(d.sent, out)
+	in := d.script[0]
+	d.script = d.script[1:]
+	return in
+}
+
+func (d *scriptedDevice) Poll(receive func(byte) byte) {
+	if d.clock {
+		d.clock = false
+		in := d.script[0]
+		d.script = d.script[1:]
+		d.sent = append(d.sent, receive(in))
+	}
+}
+
+func TestBus_SerialDevice(t *testing.T) {
+	b := New(make([]byte, 0x8000))
+	d := &scriptedDevice{script: []byte{0x3C, 0x7E}}
+	b.SetSerialDevice(d)
+
+	// Internal clock: the device's byte shifts in over eight clock edges
+	b.Write(0xFF01, 0x11)
+	b.Write(0xFF02, 0x81)
+	b.Tick(4 * 512)
+	if got := b.Read(0xFF01); got != 0x13 { // low nibble of $11, high nibble of $3C
+		t.Fatalf("SB mid-transfer %02x want 13", got)
+	}
+	b.Tick(4 * 512)
+	if got := b.Read(0xFF01); got != 0x3C || b.Read(0xFF02)&0x80 != 0 {
+		t.Fatalf("SB=%02x SC=%02x after transfer", got, b.Read(0xFF02))
+	}
+
+	// External clock: the device clocks its byte in when polled
+	b.Write(0xFF01, 0x22)
+	b.Write(0xFF02, 0x80)
+	b.Tick(4)
+	if b.Read(0xFF02)&0x80 == 0 {
+		t.Fatalf("completed before the device clocked")
+	}
+	d.clock = true
+	b.Tick(4)
+	if got := b.Read(0xFF01); got != 0x7E || b.Read(0xFF02)&0x80 != 0 || b.Read(0xFF0F)&0x08 == 0 {
+		t.Fatalf("external transfer: SB=%02x SC=%02x IF=%02x", got, b.Read(0xFF02), b.Read(0xFF0F))
+	}
+	if string(d.sent) != "\x11\x22" {
+		t.Fatalf("device received % x, want 11 22", d.sent)
+	}
+
+	// A device that is not ready is treated as unplugged
+	d.notReady = true
+	b.Write(0xFF02, 0x81)
+	b.Tick(8 * 512)
+	if got := b.Read(0xFF01); got != 0xFF || len(d.sent) != 2 {
+		t.Fatalf("SB=%02x with the device not ready", got)
+	}
+}
+
+func TestBus_Infrared(t *testing.T) {
+	a, b := New(make([]byte, 0x8000)), New(make([]byte, 0x8000))
+	if got := a.Read(0xFF56); got != 0xFF {
+		t.Fatalf("RP outside CGB mode reads %02x want ff", got)
+	}
+	a.SetCGBMode(true)
+	b.SetCGBMode(true)
+	a.ConnectIR(b)
+	b.Write(0xFF56, 0xC0) // enable reading
+	if got := b.Read(0xFF56); got != 0xFE {
+		t.Fatalf("RP in the dark reads %02x want fe", got)
+	}
+
+	// a's LED turns on 100 cycles in; b only sees it once its clock gets there
+	a.Tick(100)
+	a.Write(0xFF56, 0x01)
+	if got := a.Read(0xFF56); got != 0x3F {
+		t.Fatalf("RP with LED on and reading disabled %02x want 3f", got)
+	}
+	b.Tick(96)
+	if b.Read(0xFF56)&0x02 == 0 {
+		t.Fatalf("light seen before it was sent")
+	}
+	b.Tick(4)
+	if got := b.Read(0xFF56); got != 0xFC {
+		t.Fatalf("RP receiving light reads %02x want fc", got)
+	}
+	b.Write(0xFF56, 0x00)
+	if b.Read(0xFF56)&0x02 == 0 {
+		t.Fatalf("light reported with reading disabled")
+	}
+
+	// LED off again; reconnecting the same pair keeps the pending change
+	a.Tick(50)
+	a.Write(0xFF56, 0x00)
+	a.ConnectIR(b)
+	b.Write(0xFF56, 0xC0)
+	b.Tick(40)
+	if b.Read(0xFF56)&0x02 != 0 {
+		t.Fatalf("LED switched off too early")
+	}
+	b.Tick(10)
+	if b.Read(0xFF56)&0x02 == 0 {
+		t.Fatalf("LED still seen after it went off")
+	}
+
+	a.ConnectIR(nil)
+	a.Write(0xFF56, 0x01)
+	b.Tick(4)
+	if b.Read(0xFF56)&0x02 == 0 || b.ir != nil {
+		t.Fatalf("light seen after disconnecting")
+	}
 }
 
 func TestBus_TimerEdge_OnDIVAndTACWrites(t *testing.T) {
@@ -255,3 +413,116 @@ func TestBus_TIMAOverflow_ReloadTiming_AndCancellation(t *testing.T) {
 type writerFunc func([]byte) (int, error)
 
 func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestBus_DoubleSpeedSwitch(t *testing.T) {
+	b := newCGBBus()
+	// Without the prepare bit STOP does not switch
+	if n := b.SpeedSwitch(); n != 0 {
+		t.Fatalf("switch without KEY1 armed returned %d", n)
+	}
+	b.Write(0xFF4D, 0x01)
+	if got := b.Read(0xFF4D); got != 0x7F {
+		t.Fatalf("KEY1 armed got %02X want 7F", got)
+	}
+	tick(b, 1000)
+	if n := b.SpeedSwitch(); n != speedSwitchCycles {
+		t.Fatalf("switch cycles got %d want %d", n, speedSwitchCycles)
+	}
+	if got := b.Read(0xFF4D); got != 0xFE {
+		t.Fatalf("KEY1 after switch got %02X want FE", got)
+	}
+	if got := b.Read(0xFF04); got != 0 {
+		t.Fatalf("DIV not reset by speed switch: %02X", got)
+	}
+	// PPU advances one dot per two CPU cycles: 80 CPU cycles is still OAM scan
+	b.Write(0xFF40, 0x80)
+	tick(b, 80)
+	if mode := b.Read(0xFF41) & 0x03; mode != 2 {
+		t.Fatalf("mode after 80 fast cycles got %d want 2", mode)
+	}
+	tick(b, 80)
+	if mode := b.Read(0xFF41) & 0x03; mode != 3 {
+		t.Fatalf("mode after 160 fast cycles got %d want 3", mode)
+	}
+	// DIV keeps counting at the CPU clock
+	if got := b.Read(0xFF04); got != 0 {
+		t.Fatalf("DIV after 160 cycles got %02X want 00", got)
+	}
+	tick(b, 256-160)
+	if got := b.Read(0xFF04); got != 1 {
+		t.Fatalf("DIV after 256 cycles got %02X want 01", got)
+	}
+	// Switching back returns to normal speed
+	b.Write(0xFF4D, 0x01)
+	b.SpeedSwitch()
+	if got := b.Read(0xFF4D); got != 0x7E {
+		t.Fatalf("KEY1 after switching back got %02X want 7E", got)
+	}
+}
+
+func TestBus_DoubleSpeedHDMAStall(t *testing.T) {
+	b := newCGBBus()
+	b.Write(0xFF4D, 0x01)
+	b.SpeedSwitch()
+	b.Write(0xFF51, 0xC0)
+	b.Write(0xFF52, 0x00)
+	b.Write(0xFF53, 0x80)
+	b.Write(0xFF54, 0x00)
+	b.Write(0xFF55, 0x00) // GDMA, 1 block
+	if got := b.TakeStall(); got != 64 {
+		t.Fatalf("double-speed GDMA stall got %d want 64", got)
+	}
+}
+
+func TestBus_ResetSpeed(t *testing.T) {
+	b := New(make([]byte, 0x8000))
+	b.SetCGBMode(true)
+	b.Write(0xFF4D, 0x01)
+	if b.SpeedSwitch() == 0 || !b.DoubleSpeed() {
+		t.Fatalf("speed switch not taken")
+	}
+	b.Write(0xFF4D, 0x01)
+	b.ResetSpeed()
+	if b.DoubleSpeed() {
+		t.Fatalf("still double speed after reset")
+	}
+	if got := b.Read(0xFF4D); got&0x81 != 0 {
+		t.Fatalf("KEY1 after reset got %02X, want speed and prepare bits clear", got)
+	}
+}
+
+func TestBus_CGBBootROMMapping(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0000], rom[0x0104], rom[0x0200] = 0xC3, 0xCE, 0x77
+	boot := make([]byte, 0x900)
+	boot[0x0000], boot[0x0104], boot[0x0200], boot[0x08FF] = 0x31, 0xEE, 0x21, 0x99
+	b := New(rom)
+	b.SetCGBMode(true)
+	b.SetCGBBootROM(boot)
+	b.EnableBoot(2)
+	for _, tc := range []struct {
+		addr uint16
+		want byte
+	}{{0x0000, 0x31}, {0x0104, 0xCE}, {0x0200, 0x21}, {0x08FF, 0x99}} {
+		if got := b.Read(tc.addr); got != tc.want {
+			t.Errorf("read %04X got %02X want %02X", tc.addr, got, tc.want)
+		}
+	}
+	// 0x800-byte dumps lack the header hole and map 0100-07FF at 0200
+	b.SetCGBBootROM(append(boot[:0x100:0x100], boot[0x200:]...))
+	if got := b.Read(0x0200); got != 0x21 {
+		t.Errorf("0x800 dump: read 0200 got %02X want 21", got)
+	}
+	b.Write(0xFF4C, 0x04)
+	b.Write(0xFF50, 0x11)
+	if b.BootROMActive() || !b.DMGCompatMode() {
+		t.Fatalf("after FF50: boot active=%t compat=%t", b.BootROMActive(), b.DMGCompatMode())
+	}
+	if got := b.Read(0x0000); got != 0xC3 {
+		t.Errorf("cart not mapped after FF50, read %02X", got)
+	}
+	b.Write(0xFF4C, 0x00) // locked once the boot ROM is gone
+	if !b.DMGCompatMode() {
+		t.Errorf("KEY0 writable after boot")
+	}
+}
@@ -0,0 +1,126 @@
+package bus
+
+// CGB VRAM DMA (HDMA1..HDMA5 at FF51–FF55).
+//
+// - FF51/FF52: source address (high/low); low 4 bits ignored. Valid ranges 0000–7FF0 and A000–DFF0.
+// - FF53/FF54: destination within VRAM (only bits 12-4 are used; always 8000–9FF0).
+// - FF55 write: bit7=0 starts General-Purpose DMA (all blocks at once, CPU halted);
+//   bit7=1 starts HBlank DMA (one 16-byte block per HBlank). Low 7 bits = blocks-1.
+//   Writing bit7=0 while an HBlank DMA is active cancels it.
+// - FF55 read: bit7=0 while HBlank DMA active, 1 otherwise; low 7 bits = remaining blocks-1 (0xFF when done).
+
+// hdmaBlockCycles is the CPU stall per 16-byte block in single-speed mode (8 M-cycles).
+const hdmaBlockCycles = 32
+
+// hdmaStall returns the CPU stall for n blocks. The transfer takes the same real time
+// in double-speed mode, which is twice as many (faster) CPU cycles.
+func (b *Bus) hdmaStall(n int) int {
+	if b.doubleSpeed {
+		return n * hdmaBlockCycles * 2
+	}
+	return n * hdmaBlockCycles
+}
+
+// hdmaRead returns the value of FF51–FF55.
+func (b *Bus) hdmaRead(addr uint16) byte {
+	if !b.cgbMode || addr != 0xFF55 {
+		return 0xFF
+	}
+	if b.hdmaActive {
+		return byte(b.hdmaRemain-1) & 0x7F
+	}
+	if b.hdmaRemain == 0 {
+		return 0xFF
+	}
+	// Cancelled transfer: bit7 set with the remaining length preserved
+	return 0x80 | (byte(b.hdmaRemain-1) & 0x7F)
+}
+
+// hdmaWrite handles writes to FF51–FF55.
+func (b *Bus) hdmaWrite(addr uint16, value byte) {
+	if !b.cgbMode {
+		return
+	}
+	switch addr {
+	case 0xFF51:
+		b.hdmaSrc = (b.hdmaSrc & 0x00F0) | uint16(value)<<8
+	case 0xFF52:
+		b.hdmaSrc = (b.hdmaSrc & 0xFF00) | uint16(value&0xF0)
+	case 0xFF53:
+		b.hdmaDst = (b.hdmaDst & 0x00F0) | uint16(value&0x1F)<<8
+	case 0xFF54:
+		b.hdmaDst = (b.hdmaDst & 0x1F00) | uint16(value&0xF0)
+	case 0xFF55:
+		blocks := int(value&0x7F) + 1
+		if (value & 0x80) == 0 {
+			if b.hdmaActive {
+				// Cancel an in-flight HBlank DMA; remaining length stays readable
+				b.hdmaActive = false
+				return
+			}
+			// General-Purpose DMA: copy everything now and stall the CPU for the duration
+			b.hdmaRemain = blocks
+			for b.hdmaRemain > 0 {
+				b.hdmaCopyBlock()
+			}
+			b.stall += b.hdmaStall(blocks)
+			return
+		}
+		b.hdmaRemain = blocks
+		b.hdmaActive = true
+		// With the LCD off no HBlank will occur; hardware transfers one block right away
+		if (b.ppu.LCDC() & 0x80) == 0 {
+			b.hdmaStep()
+		}
+	}
+}
+
+// hdmaStep transfers one block for an active HBlank DMA. Invoked from the PPU's HBlank hook.
+// While the CPU is halted the transfer pauses and resumes with the first HBlank after wake-up.
+func (b *Bus) hdmaStep() {
+	if !b.hdmaActive || b.hdmaRemain == 0 || b.cpuHalted {
+		return
+	}
+	b.hdmaCopyBlock()
+	b.stall += b.hdmaStall(1)
+	if b.hdmaRemain == 0 {
+		b.hdmaActive = false
+	}
+}
+
+// hdmaCopyBlock copies 16 bytes from the source to VRAM and advances both pointers.
+func (b *Bus) hdmaCopyBlock() {
+	for i := 0; i < 0x10; i++ {
+		src := b.hdmaSrc + uint16(i)
+		var v byte
+		// Sources in VRAM (8000–9FFF) or E000+ are invalid and read as 0xFF
+		if src < 0x8000 || (src >= 0xA000 && src < 0xE000) {
+			v = b.read(src)
+		} else {
+			v = 0xFF
+		}
+		b.ppu.DMAWriteVRAM(0x8000|((b.hdmaDst+uint16(i))&0x1FFF), v)
+	}
+	b.hdmaSrc += 0x10
+	b.hdmaDst = (b.hdmaDst + 0x10) & 0x1FF0
+	b.hdmaRemain--
+}
+
+// TakeStall returns and clears the number of cycles the CPU must stay halted
+// because a DMA engine (GDMA/HDMA) owned the bus.
+func (b *Bus) TakeStall() int {
+	n := b.stall
+	b.stall = 0
+	return n
+}
+
+// SetCPUHalted tells the bus whether the CPU is in HALT, which pauses HBlank DMA.
+func (b *Bus) SetCPUHalted(on bool) { b.cpuHalted = on }
+
+// ResetHDMA drops any VRAM DMA in flight and its pending CPU stall, as a console reset does.
+func (b *Bus) ResetHDMA() {
+	b.hdmaActive = false
+	b.hdmaRemain = 0
+	b.hdmaSrc, b.hdmaDst = 0, 0
+	b.stall = 0
+}
@@ -0,0 +1,118 @@
+package bus
+
+import "testing"
+
+func newCGBBus() *Bus {
+	b := New(make([]byte, 0x8000))
+	b.SetCGBMode(true)
+	return b
+}
+
+func TestHDMA_GeneralPurposeCopiesAndStalls(t *testing.T) {
+	b := newCGBBus()
+	for i := 0; i < 0x20; i++ {
+		b.Write(0xC000+uint16(i), byte(0x40+i))
+	}
+	b.Write(0xFF51, 0xC0)
+	b.Write(0xFF52, 0x00)
+	b.Write(0xFF53, 0x81)
+	b.Write(0xFF54, 0x00)
+	b.Write(0xFF55, 0x01) // GDMA, 2 blocks
+	for i := 0; i < 0x20; i++ {
+		if got := b.PPU().RawVRAM(0x8100 + uint16(i)); got != byte(0x40+i) {
+			t.Fatalf("VRAM[%04X] got %02X want %02X", 0x8100+i, got, byte(0x40+i))
+		}
+	}
+	if got := b.Read(0xFF55); got != 0xFF {
+		t.Fatalf("HDMA5 after GDMA got %02X want FF", got)
+	}
+	if got := b.TakeStall(); got != 2*hdmaBlockCycles {
+		t.Fatalf("GDMA stall got %d want %d", got, 2*hdmaBlockCycles)
+	}
+	if got := b.TakeStall(); got != 0 {
+		t.Fatalf("stall not drained: %d", got)
+	}
+}
+
+func TestHDMA_HBlankOneBlockPerLine(t *testing.T) {
+	b := newCGBBus()
+	for i := 0; i < 0x30; i++ {
+		b.Write(0xC000+uint16(i), byte(i+1))
+	}
+	b.Write(0xFF40, 0x80) // LCD on, LY=0 mode 2
+	b.Write(0xFF51, 0xC0)
+	b.Write(0xFF52, 0x00)
+	b.Write(0xFF53, 0x00)
+	b.Write(0xFF54, 0x00)
+	b.Write(0xFF55, 0x82) // HBlank DMA, 3 blocks
+	if got := b.Read(0xFF55); got != 0x02 {
+		t.Fatalf("HDMA5 active read got %02X want 02", got)
+	}
+	if got := b.PPU().RawVRAM(0x8000); got != 0x00 {
+		t.Fatalf("HBlank DMA copied before HBlank: %02X", got)
+	}
+	tick(b, 80+172) // first HBlank
+	if got := b.PPU().RawVRAM(0x800F); got != 0x10 {
+		t.Fatalf("block 0 not copied: %02X", got)
+	}
+	if got := b.PPU().RawVRAM(0x8010); got != 0x00 {
+		t.Fatalf("block 1 copied too early: %02X", got)
+	}
+	if got := b.Read(0xFF55); got != 0x01 {
+		t.Fatalf("HDMA5 remaining got %02X want 01", got)
+	}
+	// Cancel before the next HBlank; the remaining length stays visible with bit7 set
+	b.Write(0xFF55, 0x00)
+	if got := b.Read(0xFF55); got != 0x81 {
+		t.Fatalf("HDMA5 after cancel got %02X want 81", got)
+	}
+	tick(b, 456)
+	if got := b.PPU().RawVRAM(0x8010); got != 0x00 {
+		t.Fatalf("cancelled HDMA still copied: %02X", got)
+	}
+}
+
+func TestHDMA_HiddenOnDMG(t *testing.T) {
+	b := New(make([]byte, 0x8000))
+	b.Write(0xFF55, 0x00)
+	if got := b.Read(0xFF55); got != 0xFF {
+		t.Fatalf("HDMA5 on DMG got %02X want FF", got)
+	}
+	if got := b.TakeStall(); got != 0 {
+		t.Fatalf("DMG HDMA write stalled CPU: %d", got)
+	}
+}
+
+func TestHDMA_HBlankPausedWhileHalted(t *testing.T) {
+	b := newCGBBus()
+	for i := 0; i < 0x20; i++ {
+		b.Write(0xC000+uint16(i), byte(i+1))
+	}
+	b.Write(0xFF40, 0x80)
+	b.Write(0xFF51, 0xC0)
+	b.Write(0xFF52, 0x00)
+	b.Write(0xFF53, 0x00)
+	b.Write(0xFF54, 0x00)
+	b.Write(0xFF55, 0x81) // HBlank DMA, 2 blocks
+	b.SetCPUHalted(true)
+	tick(b, 456)
+	if got := b.PPU().RawVRAM(0x8000); got != 0x00 {
+		t.Fatalf("HBlank DMA ran while halted: %02X", got)
+	}
+	if got := b.TakeStall(); got != 0 {
+		t.Fatalf("halted CPU charged stall %d", got)
+	}
+	b.SetCPUHalted(false)
+	tick(b, 456)
+	if got := b.PPU().RawVRAM(0x8000); got != 0x01 {
+		t.Fatalf("HBlank DMA did not resume after HALT: %02X", got)
+	}
+	// Reset drops the remaining block
+	b.ResetHDMA()
+	if got := b.Read(0xFF55); got != 0xFF {
+		t.Fatalf("HDMA5 after reset got %02X want FF", got)
+	}
+	if got := b.TakeStall(); got != 0 {
+		t.Fatalf("stall survived reset: %d", got)
+	}
+}
@@ -0,0 +1,112 @@
+package bus
+
+// IRDevice faces the CGB infrared port (RP, FF56): it sees this side's LED and decides
+// whether light reaches the sensor. Times are Cycles values, so a device can line the
+// two sides up in emulated time however far apart they run.
+type IRDevice interface {
+	// SetLED is called when the LED turns on or off.
+	SetLED(on bool, cycle uint64)
+	// Light reports whether light reaches the sensor at cycle.
+	Light(cycle uint64) bool
+}
+
+// SetIRDevice points d at the infrared port, or removes the current device with nil.
+func (b *Bus) SetIRDevice(d IRDevice) {
+	if e, ok := b.ir.(irEnd); ok {
+		e.c.ends[1-e.i].ir = nil
+	}
+	b.ir = d
+}
+
+// Cycles returns the CPU cycles run since power-on at normal speed: in double speed
+// two CPU cycles count as one, so the counter follows emulated time.
+func (b *Bus) Cycles() uint64 { return b.cycles }
+
+// ConnectIR points the infrared ports of b and other at each other, or removes b's
+// device when other is nil. Connecting two ports that already face each other keeps
+// the light in flight.
+func (b *Bus) ConnectIR(other *Bus) {
+	if other == nil {
+		b.SetIRDevice(nil)
+		return
+	}
+	if e, ok := b.ir.(irEnd); ok && e.c.ends[1-e.i] == other {
+		return
+	}
+	b.SetIRDevice(nil)
+	other.SetIRDevice(nil)
+	c := &irCable{
+		ends: [2]*Bus{b, other},
+		base: [2]uint64{b.cycles, other.cycles},
+		led:  [2]bool{b.rp&0x01 != 0, other.rp&0x01 != 0},
+	}
+	b.ir, other.ir = irEnd{c, 0}, irEnd{c, 1}
+}
+
+func (b *Bus) rpRead() byte {
+	if !b.cgbMode {
+		return 0xFF
+	}
+	v := 0x3E | b.rp // bit1 reads 0 while light is received
+	if b.rp&0xC0 == 0xC0 && b.ir != nil && b.ir.Light(b.cycles) {
+		v &^= 0x02
+	}
+	return v
+}
+
+func (b *Bus) rpWrite(value byte) {
+	if !b.cgbMode {
+		return
+	}
+	old := b.rp
+	b.rp = value & 0xC1
+	if (old^b.rp)&0x01 != 0 && b.ir != nil {
+		b.ir.SetLED(b.rp&0x01 != 0, b.cycles)
+	}
+}
+
+// maxIREdges bounds the LED changes kept for a side that never looks.
+const maxIREdges = 1024
+
+type irEdge struct {
+	at uint64 // cycles since the cable was connected
+	on bool
+}
+
+// irCable carries light between two ports in the same process. Each side's LED
+// changes are kept with their time until the other side's clock passes them, so the
+// sensor sees the LED as it was at the same point in emulated time even when one
+// machine runs a little ahead.
+type irCable struct {
+	ends  [2]*Bus
+	base  [2]uint64   // each side's Cycles when connected
+	led   [2]bool     // LED state before the first kept edge
+	edges [2][]irEdge // LED changes the other side has not passed yet
+}
+
+// irEnd is one side's view of an irCable.
+type irEnd struct {
+	c *irCable
+	i int
+}
+
+func (e irEnd) SetLED(on bool, cycle uint64) {
+	c := e.c
+	c.edges[e.i] = append(c.edges[e.i], irEdge{cycle - c.base[e.i], on})
+	if len(c.edges[e.i]) > maxIREdges {
+		c.led[e.i] = c.edges[e.i][0].on
+		c.edges[e.i] = c.edges[e.i][1:]
+	}
+}
+
+func (e irEnd) Light(cycle uint64) bool {
+	c, o := e.c, 1-e.i
+	at := cycle - c.base[e.i]
+	n := 0
+	for n < len(c.edges[o]) && c.edges[o][n].at <= at {
+		c.led[o] = c.edges[o][n].on
+		n++
+	}
+	c.edges[o] = c.edges[o][n:]
+	return c.led[o]
+}
@@ -178,9 +178,9 @@ func TestPPU_WriteLYResetsLineAndMode(t *testing.T) {
 func TestPPU_STAT_VBlankInterruptEnable(t *testing.T) {
 	b := New(make([]byte, 0x8000))
 	b.Write(0xFF40, 0x80) // LCD on
-	b.Write(0xFF0F, 0)
-	// Disable STAT VBlank interrupt
+	// Disable STAT VBlank interrupt (on DMG the write itself raises STAT while LY=LYC)
 	b.Write(0xFF41, 0)
+	b.Write(0xFF0F, 0)
 	tick(b, 144*456)
 	// VBlank IF should be set, STAT IF should not
 	if (b.Read(0xFF0F) & 0x01) == 0 {
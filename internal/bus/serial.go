@@ -0,0 +1,145 @@
+package bus
+
+import "io"
+
+// SerialDevice is a peripheral plugged into the serial port: another Game Boy, a
+// printer, a network bridge or a test harness. The bus shifts bytes from a device in
+// bit by bit; devices that need every clock edge also implement SerialBitDevice.
+type SerialDevice interface {
+	// Ready reports whether the device is present and answering. While it is not, the
+	// port behaves as if nothing were plugged in: transfers read $FF.
+	Ready() bool
+	// Exchange is called when the Game Boy starts a transfer on its internal clock with
+	// the byte in SB; it returns the byte shifted in over the next eight clock edges.
+	Exchange(out byte) (in byte)
+	// Poll is called while a transfer waits for an external clock and must not block. A
+	// device driving the clock calls receive with its byte and gets the old SB back.
+	Poll(receive func(in byte) (out byte))
+}
+
+// SerialBitDevice is a SerialDevice that trades one bit on every edge of the Game
+// Boy's internal clock instead of a byte when the transfer starts.
+type SerialBitDevice interface {
+	SerialDevice
+	ExchangeBit(out byte) (in byte)
+}
+
+// SetSerialDevice plugs d into the serial port, or unplugs the current device with nil.
+func (b *Bus) SetSerialDevice(d SerialDevice) {
+	if p, ok := b.serial.(peer); ok {
+		p.b.serial = nil
+	}
+	b.serial = d
+	if b.serialReceive == nil {
+		b.serialReceive = b.SerialReceive
+	}
+}
+
+// SerialDevice returns what is plugged into the serial port, or nil.
+func (b *Bus) SerialDevice() SerialDevice { return b.serial }
+
+// SetSerialWriter plugs in a SerialWriter sending every byte to w.
+func (b *Bus) SetSerialWriter(w io.Writer) { b.SetSerialDevice(SerialWriter{W: w}) }
+
+// ConnectSerial plugs a link cable between b and other, or unplugs b's device when
+// other is nil. The side using the internal clock drives the bits for both.
+func (b *Bus) ConnectSerial(other *Bus) {
+	if other == nil {
+		b.SetSerialDevice(nil)
+		return
+	}
+	b.SetSerialDevice(peer{other})
+	other.SetSerialDevice(peer{b})
+}
+
+// serialStart begins a transfer; on the internal clock it fetches the device's byte.
+func (b *Bus) serialStart() {
+	b.serialBits = 0
+	if b.sc&0x01 == 0 {
+		return
+	}
+	b.serialIn = 0xFF
+	if d := b.serial; d != nil && d.Ready() {
+		if _, bits := d.(SerialBitDevice); !bits {
+			b.serialIn = d.Exchange(b.sb)
+		}
+	}
+}
+
+// serialClock shifts one bit out of SB on the internal clock and one bit in from the
+// device, which reads as 1 with nothing plugged in.
+func (b *Bus) serialClock() {
+	in := b.serialIn >> 7
+	b.serialIn = b.serialIn<<1 | 1
+	if d, ok := b.serial.(SerialBitDevice); ok && d.Ready() {
+		in = d.ExchangeBit(b.sb >> 7)
+	}
+	b.serialShift(in)
+}
+
+// SerialReceive completes a transfer clocked by the device: if one is waiting for an
+// external clock, SB takes in and the old SB is returned; otherwise the device
+// reads $FF.
+func (b *Bus) SerialReceive(in byte) byte {
+	if b.sc&0x81 != 0x80 {
+		return 0xFF
+	}
+	out := b.sb
+	b.sb = in
+	b.serialBits = 0
+	b.sc &^= 0x80
+	b.ifReg |= 1 << 3
+	return out
+}
+
+// serialClockIn is the partner's clock edge: with a transfer waiting for an external
+// clock it shifts bit in and returns the bit shifted out; otherwise it returns 1.
+func (b *Bus) serialClockIn(bit byte) byte {
+	if b.sc&0x81 != 0x80 {
+		return 1
+	}
+	out := b.sb >> 7
+	b.serialShift(bit)
+	return out
+}
+
+func (b *Bus) serialShift(in byte) {
+	b.sb = b.sb<<1 | in
+	b.serialBits++
+	if b.serialBits == 8 {
+		b.serialBits = 0
+		b.sc &^= 0x80
+		b.ifReg |= 1 << 3
+	}
+}
+
+// peer is the far end of a link cable: the other Game Boy's serial port.
+type peer struct{ b *Bus }
+
+func (p peer) Ready() bool { return true }
+
+func (p peer) Exchange(out byte) (in byte) {
+	for i := 0; i < 8; i++ {
+		in = in<<1 | p.b.serialClockIn(out>>7)
+		out <<= 1
+	}
+	return in
+}
+
+// Poll does nothing: the other side clocks through ExchangeBit on this side's peer.
+func (p peer) Poll(func(byte) byte) {}
+
+func (p peer) ExchangeBit(out byte) byte { return p.b.serialClockIn(out) }
+
+// SerialWriter is a SerialDevice that writes every byte the Game Boy sends to W, the
+// way test ROMs report their results. Nothing answers, so transfers read $FF.
+type SerialWriter struct{ W io.Writer }
+
+func (s SerialWriter) Ready() bool { return true }
+
+func (s SerialWriter) Exchange(out byte) byte {
+	_, _ = s.W.Write([]byte{out})
+	return 0xFF
+}
+
+func (s SerialWriter) Poll(func(byte) byte) {}
@@ -0,0 +1,344 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/gob"
+	"image"
+	"math"
+)
+
+// PocketCamera implements the Game Boy Camera mapper (cart type 0xFC) with its
+// Mitsubishi M64282FP sensor. Instead of a real sensor the image comes from a CameraSource.
+// - 0000-1FFF: RAM write enable (0x0A); RAM reads need no enable
+// - 2000-3FFF: ROM bank (6 bits)
+// - 4000-5FFF: RAM bank (0..15), or the sensor registers when bit4 is set
+// - A000-BFFF: RAM, or registers A000-A07F (mirrored) in register mode
+// Sensor registers:
+// - A000: bit0 start capture / busy; bits 1-2 stored (only readable register)
+// - A001: bit7 N (edge only, also skips 512 cycles of capture time), bits 5-6 VH (edge direction), bits 0-4 gain
+// - A002/A003: exposure time, high byte first
+// - A004: bits 4-6 edge ratio, bit3 invert, bits 0-2 output reference voltage
+// - A005: bit5 offset sign, bits 0-4 offset magnitude
+// - A006-A035: 4x4 dither matrix, three thresholds per pixel
+// A capture writes the 128x112 picture as 16x14 2bpp tiles to RAM bank 0 at A100.
+type PocketCamera struct {
+	rom []byte
+	ram [128 * 1024]byte
+
+	ramEnabled bool
+	romBank    byte // 6 bits
+	ramBank    byte // 0..15
+	regMode    bool // bit4 of the 4000-5FFF register
+
+	regs    [0x36]byte
+	busy    int // T-cycles left until the running capture completes
+	pending [camWidth * camHeight]byte
+
+	source CameraSource
+}
+
+const (
+	camWidth  = 128
+	camHeight = 112
+	// camImageOffset is where the tile data of a finished capture lands in RAM bank 0.
+	camImageOffset = 0x0100
+)
+
+// camEdgeRatio maps the A004 E bits to the edge enhancement factor.
+var camEdgeRatio = [8]float64{0.50, 0.75, 1.00, 1.25, 2.00, 3.00, 4.00, 5.00}
+
+func NewPocketCamera(rom []byte) *PocketCamera {
+	return &PocketCamera{rom: rom, romBank: 1}
+}
+
+// SetCameraSource plugs in the image source the sensor reads from. nil leaves the sensor dark.
+func (m *PocketCamera) SetCameraSource(src CameraSource) { m.source = src }
+
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *PocketCamera) ROMBank() int { return mirrorBank(m.rom, int(m.romBank)) }
+
+func (m *PocketCamera) Read(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		if int(addr) < len(m.rom) {
+			return m.rom[addr]
+		}
+		return 0xFF
+	case addr < 0x8000:
+		off := int(m.romBank)*0x4000 + int(addr-0x4000)
+		if len(m.rom) > 0 {
+			off %= len(m.rom)
+		}
+		if off < len(m.rom) {
+			return m.rom[off]
+		}
+		return 0xFF
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if m.regMode {
+			if addr&0x7F == 0 {
+				v := m.regs[0] & 0x06
+				if m.busy > 0 {
+					v |= 0x01
+				}
+				return v
+			}
+			return 0x00
+		}
+		// The sensor owns the RAM while a capture is running
+		if m.busy > 0 {
+			return 0x00
+		}
+		return m.ram[int(m.ramBank)*0x2000+int(addr-0xA000)]
+	default:
+		return 0xFF
+	}
+}
+
+func (m *PocketCamera) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = (value & 0x0F) == 0x0A
+	case addr < 0x4000:
+		m.romBank = value & 0x3F
+	case addr < 0x6000:
+		m.regMode = (value & 0x10) != 0
+		m.ramBank = value & 0x0F
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if m.regMode {
+			m.writeReg(byte(addr&0x7F), value)
+			return
+		}
+		if !m.ramEnabled || m.busy > 0 {
+			return
+		}
+		m.ram[int(m.ramBank)*0x2000+int(addr-0xA000)] = value
+	}
+}
+
+func (m *PocketCamera) writeReg(reg byte, value byte) {
+	if int(reg) >= len(m.regs) {
+		return
+	}
+	if reg != 0 {
+		m.regs[reg] = value
+		return
+	}
+	m.regs[0] = value & 0x07
+	switch {
+	case value&0x01 != 0 && m.busy == 0:
+		m.startCapture()
+	case value&0x01 == 0:
+		m.busy = 0 // writing 0 aborts a running capture
+	}
+}
+
+// startCapture samples the source and processes it with the current registers; the
+// result becomes visible in RAM once the exposure time has elapsed (see Tick).
+func (m *PocketCamera) startCapture() {
+	m.process(m.sensorFrame())
+	// The documented duration is in M-cycles (1 MiHz); busy counts the T-cycles Tick receives
+	exposure := int(m.regs[2])<<8 | int(m.regs[3])
+	mcycles := 32446 + 16*exposure
+	if m.regs[1]&0x80 == 0 {
+		mcycles += 512
+	}
+	m.busy = 4 * mcycles
+}
+
+// Tick advances a running capture by the given number of CPU T-cycles.
+func (m *PocketCamera) Tick(cycles int) {
+	if m.busy <= 0 {
+		return
+	}
+	m.busy -= cycles
+	if m.busy <= 0 {
+		m.busy = 0
+		m.regs[0] &^= 0x01
+		m.storeTiles()
+	}
+}
+
+// sensorFrame returns the source image as 8-bit luminance (0 dark, 255 bright) at 128x112.
+func (m *PocketCamera) sensorFrame() []byte {
+	out := make([]byte, camWidth*camHeight)
+	if m.source == nil {
+		return out
+	}
+	img := m.source.Frame()
+	if img == nil {
+		return out
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return out
+	}
+	// Nearest-neighbour resample of the full source frame
+	for y := 0; y < camHeight; y++ {
+		sy := b.Min.Y + y*b.Dy()/camHeight
+		for x := 0; x < camWidth; x++ {
+			sx := b.Min.X + x*b.Dx()/camWidth
+			r, g, bl, _ := img.At(sx, sy).RGBA()
+			// ITU-R 601 luma on 16-bit channels
+			out[y*camWidth+x] = byte((299*r + 587*g + 114*bl) / 1000 >> 8)
+		}
+	}
+	return out
+}
+
+// process runs the sensor pipeline on a luminance frame: exposure, edge enhancement or
+// extraction, inversion and offset, then the dither matrix quantizes each pixel to a
+// 2-bit shade (0 white .. 3 black) in m.pending. Exposure 0x0300 at gain 0 is unity.
+func (m *PocketCamera) process(lum []byte) {
+	exposure := float64(int(m.regs[2])<<8 | int(m.regs[3]))
+	scale := exposure / 0x0300 * camGain(m.regs[1]&0x1F)
+	sig := make([]float64, len(lum))
+	for i, v := range lum {
+		sig[i] = float64(v) * scale
+	}
+
+	vh := (m.regs[1] >> 5) & 0x03
+	edgeOnly := m.regs[1]&0x80 != 0
+	alpha := camEdgeRatio[(m.regs[4]>>4)&0x07]
+	invert := m.regs[4]&0x08 != 0
+	offset := float64(m.regs[5] & 0x1F)
+	if m.regs[5]&0x20 == 0 {
+		offset = -offset
+	}
+
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		} else if x >= camWidth {
+			x = camWidth - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= camHeight {
+			y = camHeight - 1
+		}
+		return sig[y*camWidth+x]
+	}
+
+	for y := 0; y < camHeight; y++ {
+		for x := 0; x < camWidth; x++ {
+			p := at(x, y)
+			var edge float64
+			switch vh {
+			case 1: // horizontal
+				edge = 2*p - at(x-1, y) - at(x+1, y)
+			case 2: // vertical
+				edge = 2*p - at(x, y-1) - at(x, y+1)
+			case 3: // 2-D
+				edge = 4*p - at(x-1, y) - at(x+1, y) - at(x, y-1) - at(x, y+1)
+			}
+			v := p + alpha*edge
+			if edgeOnly && vh != 0 {
+				v = 128 + alpha*edge
+			}
+			if invert {
+				v = 255 - v
+			}
+			v += offset
+			if v < 0 {
+				v = 0
+			} else if v > 255 {
+				v = 255
+			}
+			m.pending[y*camWidth+x] = m.dither(byte(v), x, y)
+		}
+	}
+}
+
+// camGain returns the analog gain for the A001 G bits relative to G=0. The sensor spans
+// 14.0 dB (G=0) to 57.5 dB (G=31) in equal steps.
+func camGain(g byte) float64 {
+	db := float64(g) * (57.5 - 14.0) / 31
+	return math.Pow(10, db/20)
+}
+
+// dither compares a pixel with the three thresholds of its matrix cell.
+func (m *PocketCamera) dither(v byte, x, y int) byte {
+	base := 6 + ((y&3)*4+(x&3))*3
+	switch {
+	case v < m.regs[base]:
+		return 3
+	case v < m.regs[base+1]:
+		return 2
+	case v < m.regs[base+2]:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// storeTiles writes m.pending to RAM bank 0 as 2bpp tiles in row-major tile order.
+func (m *PocketCamera) storeTiles() {
+	for y := 0; y < camHeight; y++ {
+		for tx := 0; tx < camWidth/8; tx++ {
+			var lo, hi byte
+			for px := 0; px < 8; px++ {
+				c := m.pending[y*camWidth+tx*8+px]
+				lo |= (c & 1) << (7 - px)
+				hi |= (c >> 1) << (7 - px)
+			}
+			off := camImageOffset + ((y/8)*(camWidth/8)+tx)*16 + (y&7)*2
+			m.ram[off] = lo
+			m.ram[off+1] = hi
+		}
+	}
+}
+
+// BatteryBacked implementation: the full 128 KiB of photo RAM
+func (m *PocketCamera) SaveRAM() []byte {
+	out := make([]byte, len(m.ram))
+	copy(out, m.ram[:])
+	return out
+}
+
+func (m *PocketCamera) LoadRAM(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	copy(m.ram[:], data)
+}
+
+// SaveState/LoadState for save states
+type pocketCameraState struct {
+	RAM        []byte
+	RamEnabled bool
+	RomBank    byte
+	RamBank    byte
+	RegMode    bool
+	Regs       [0x36]byte
+	Busy       int
+	Pending    []byte
+}
+
+func (m *PocketCamera) SaveState() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	s := pocketCameraState{
+		RAM: m.ram[:], RamEnabled: m.ramEnabled, RomBank: m.romBank, RamBank: m.ramBank,
+		RegMode: m.regMode, Regs: m.regs, Busy: m.busy, Pending: m.pending[:],
+	}
+	_ = enc.Encode(s)
+	return buf.Bytes()
+}
+
+func (m *PocketCamera) LoadState(data []byte) {
+	var s pocketCameraState
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&s); err != nil {
+		return
+	}
+	copy(m.ram[:], s.RAM)
+	m.ramEnabled, m.romBank, m.ramBank, m.regMode = s.RamEnabled, s.RomBank, s.RamBank, s.RegMode
+	m.regs, m.busy = s.Regs, s.Busy
+	copy(m.pending[:], s.Pending)
+}
+
+// CameraSource feeds the PocketCamera sensor. Frame is called once per capture; the
+// image is resampled to the sensor's 128x112 output.
+type CameraSource interface {
+	Frame() image.Image
+}
@@ -0,0 +1,84 @@
+package cart
+
+import (
+	"errors"
+	"image"
+	_ "image/jpeg" // register decoders for camera source files
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StillImage is a CameraSource that shows the same picture on every capture.
+type StillImage struct {
+	Image image.Image
+}
+
+func (s *StillImage) Frame() image.Image { return s.Image }
+
+// FrameSequence is a CameraSource that steps through image files, one per capture,
+// wrapping around after the last. Files are decoded on demand.
+type FrameSequence struct {
+	paths []string
+	next  int
+}
+
+func (s *FrameSequence) Frame() image.Image {
+	if len(s.paths) == 0 {
+		return nil
+	}
+	p := s.paths[s.next]
+	s.next = (s.next + 1) % len(s.paths)
+	img, err := decodeImageFile(p)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// OpenCameraSource builds a CameraSource from a PNG/JPEG file or from a directory of them
+// (taken in file name order).
+func OpenCameraSource(path string) (CameraSource, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		img, err := decodeImageFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &StillImage{Image: img}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".png", ".jpg", ".jpeg":
+			paths = append(paths, filepath.Join(path, e.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no PNG or JPEG frames in " + path)
+	}
+	sort.Strings(paths)
+	return &FrameSequence{paths: paths}, nil
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
@@ -0,0 +1,109 @@
+package cart
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// camSetup selects register mode, programs unity exposure and a flat dither matrix
+// with thresholds 0x40/0x80/0xC0.
+func camSetup(m *PocketCamera) {
+	m.Write(0x4000, 0x10)
+	m.Write(0xA001, 0x00)
+	m.Write(0xA002, 0x03)
+	m.Write(0xA003, 0x00)
+	for i := 0; i < 16; i++ {
+		m.Write(0xA006+uint16(i*3), 0x40)
+		m.Write(0xA007+uint16(i*3), 0x80)
+		m.Write(0xA008+uint16(i*3), 0xC0)
+	}
+}
+
+func TestPocketCamera_CaptureTimingAndTiles(t *testing.T) {
+	m := NewPocketCamera(make([]byte, 0x8000))
+	// Left half black, right half white
+	img := image.NewGray(image.Rect(0, 0, 128, 112))
+	for y := 0; y < 112; y++ {
+		for x := 64; x < 128; x++ {
+			img.SetGray(x, y, color.Gray{Y: 0xFF})
+		}
+	}
+	m.SetCameraSource(&StillImage{Image: img})
+	camSetup(m)
+	m.Write(0xA000, 0x01)
+	if got := m.Read(0xA000); got&0x01 == 0 {
+		t.Fatalf("A000 not busy after start: %02X", got)
+	}
+	// Exposure 0x0300 with N=0: 32446 + 512 + 16*0x300 M-cycles, ticked in T-cycles
+	want := 4 * (32446 + 512 + 16*0x300)
+	m.Tick(want - 1)
+	if got := m.Read(0xA000); got&0x01 == 0 {
+		t.Fatalf("capture finished early")
+	}
+	m.Tick(1)
+	if got := m.Read(0xA000); got != 0x00 {
+		t.Fatalf("A000 after capture got %02X want 00", got)
+	}
+	// Back to RAM bank 0: first tile black (both planes set), tile 8 white
+	m.Write(0x4000, 0x00)
+	if lo, hi := m.Read(0xA100), m.Read(0xA101); lo != 0xFF || hi != 0xFF {
+		t.Fatalf("tile0 row0 got %02X %02X want FF FF", lo, hi)
+	}
+	if lo, hi := m.Read(0xA100+8*16), m.Read(0xA101+8*16); lo != 0x00 || hi != 0x00 {
+		t.Fatalf("tile8 row0 got %02X %02X want 00 00", lo, hi)
+	}
+}
+
+func TestPocketCamera_DitherAndInvert(t *testing.T) {
+	m := NewPocketCamera(make([]byte, 0x8000))
+	camSetup(m)
+	lum := make([]byte, camWidth*camHeight)
+	for i := range lum {
+		lum[i] = 0x90
+	}
+	m.process(lum)
+	if got := m.pending[0]; got != 1 {
+		t.Fatalf("0x90 shade got %d want 1", got)
+	}
+	// Invert: 255-0x90 = 0x6F -> shade 2
+	m.Write(0xA004, 0x08)
+	m.process(lum)
+	if got := m.pending[0]; got != 2 {
+		t.Fatalf("inverted shade got %d want 2", got)
+	}
+	// Doubling exposure saturates to white
+	m.Write(0xA004, 0x00)
+	m.Write(0xA002, 0x06)
+	m.process(lum)
+	if got := m.pending[0]; got != 0 {
+		t.Fatalf("overexposed shade got %d want 0", got)
+	}
+	// So does raising the gain at unity exposure
+	m.Write(0xA002, 0x03)
+	m.Write(0xA001, 0x04)
+	m.process(lum)
+	if got := m.pending[0]; got != 0 {
+		t.Fatalf("high gain shade got %d want 0", got)
+	}
+}
+
+func TestPocketCamera_RAMLockedWhileBusy(t *testing.T) {
+	m := NewPocketCamera(make([]byte, 0x8000))
+	m.Write(0x0000, 0x0A)
+	m.Write(0x4000, 0x01)
+	m.Write(0xA000, 0x42)
+	camSetup(m)
+	m.Write(0xA000, 0x01)
+	m.Write(0x4000, 0x01)
+	if got := m.Read(0xA000); got != 0x00 {
+		t.Fatalf("RAM read during capture got %02X want 00", got)
+	}
+	m.Tick(1 << 20)
+	if got := m.Read(0xA000); got != 0x42 {
+		t.Fatalf("RAM read after capture got %02X want 42", got)
+	}
+	if sav := m.SaveRAM(); len(sav) != 128*1024 || sav[0x2000] != 0x42 {
+		t.Fatalf("SaveRAM len=%d [2000]=%02X", len(sav), sav[0x2000])
+	}
+}
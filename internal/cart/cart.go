@@ -19,6 +19,27 @@ type BatteryBacked interface {
 	LoadRAM(data []byte)
 }
 
+// Clocked is an optional interface for cartridges with hardware that runs off the CPU clock.
+// The Bus calls Tick with the number of elapsed CPU cycles.
+type Clocked interface {
+	Tick(cycles int)
+}
+
+// BankedROM is an optional interface reporting the ROM bank mapped at 0x4000-0x7FFF.
+// Debugging tools use it to qualify addresses in the switchable area.
+type BankedROM interface {
+	ROMBank() int
+}
+
+// mirrorBank folds a bank number onto the banks actually present, as the unused
+// upper bank lines make the ROM repeat.
+func mirrorBank(rom []byte, bank int) int {
+	if n := len(rom) / 0x4000; n > 0 {
+		return bank % n
+	}
+	return bank
+}
+
 // NewCartridge picks an implementation based on the ROM header.
 func NewCartridge(rom []byte) Cartridge {
 	h, err := ParseHeader(rom)
@@ -27,14 +48,37 @@ func NewCartridge(rom []byte) Cartridge {
 	}
 	switch h.CartType {
 	case 0x00:
+		if ramSize, ok := IsMMM01(rom); ok {
+			return NewMMM01(rom, ramSize)
+		}
 		return NewROMOnly(rom)
 	case 0x01, 0x02, 0x03: // MBC1 variants (RAM, RAM+BAT are transparent here)
+		if IsMBC1Multicart(rom) {
+			return NewMBC1M(rom, h.RAMSizeBytes)
+		}
 		return NewMBC1(rom, h.RAMSizeBytes)
+	case 0x05, 0x06: // MBC2 (built-in 512x4-bit RAM; 0x06 has battery)
+		return NewMBC2(rom)
+	case 0x0B, 0x0C, 0x0D: // MMM01 dumped with the menu first
+		return NewMMM01(menuLast(rom), h.RAMSizeBytes)
 	case 0x0F, 0x10, 0x11, 0x12, 0x13: // MBC3 variants (RTC not implemented here)
 		return NewMBC3(rom, h.RAMSizeBytes)
 	case 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E: // MBC5 variants
 		return NewMBC5(rom, h.RAMSizeBytes)
+	case 0x22: // MBC7 (accelerometer + 93LC56 EEPROM)
+		return NewMBC7(rom)
+	case 0xFC: // Pocket Camera (M64282FP sensor)
+		return NewPocketCamera(rom)
+	case 0xFE: // HuC3 (RTC + IR)
+		return NewHuC3(rom, h.RAMSizeBytes)
+	case 0xFF: // HuC1 (RAM+BAT, IR)
+		return NewHuC1(rom, h.RAMSizeBytes)
 	default:
+		// MMM01 dumps carry the menu (and the MMM01 header) in the last 32 KiB,
+		// so the header at 0x0100 belongs to the first game
+		if ramSize, ok := IsMMM01(rom); ok {
+			return NewMMM01(rom, ramSize)
+		}
 		// Fallback to ROM-only for unknown types to allow some homebrew/tests to run
 		return NewROMOnly(rom)
 	}
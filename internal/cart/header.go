@@ -78,6 +78,19 @@ func ParseHeader(rom []byte) (*Header, error) {
 	return h, nil
 }
 
+// hasNintendoLogo reports whether a header region (starting at 0x0000 of a bank) carries the boot logo.
+func hasNintendoLogo(rom []byte) bool {
+	if len(rom) < 0x0104+len(nintendoLogo) {
+		return false
+	}
+	for i, b := range nintendoLogo {
+		if rom[0x0104+i] != b {
+			return false
+		}
+	}
+	return true
+}
+
 func HeaderChecksumOK(rom []byte) bool {
 	if len(rom) < 0x014E {
 		return false
@@ -145,10 +158,20 @@ func cartTypeString(code byte) string {
 		return "MBC1 (variants)"
 	case 0x05, 0x06:
 		return "MBC2 (variants)"
+	case 0x0B, 0x0C, 0x0D:
+		return "MMM01 (variants)"
 	case 0x0F, 0x10, 0x11, 0x12, 0x13:
 		return "MBC3 (variants)"
 	case 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E:
 		return "MBC5 (variants)"
+	case 0x22:
+		return "MBC7 (accelerometer)"
+	case 0xFC:
+		return "POCKET CAMERA"
+	case 0xFE:
+		return "HuC3"
+	case 0xFF:
+		return "HuC1"
 	default:
 		return "Other/unknown"
 	}
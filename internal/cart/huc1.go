@@ -0,0 +1,143 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// HuC1 implements Hudson's HuC1 mapper (RAM+battery and an IR LED/receiver).
+// - 0000-1FFF: 0x0E selects IR mode for A000-BFFF; any other value selects RAM
+// - 2000-3FFF: ROM bank (6 bits, 0 maps to 1)
+// - 4000-5FFF: RAM bank (2 bits)
+// - A000-BFFF: RAM, or in IR mode: read 0xC0 | received light (bit0), write bit0 = LED on
+type HuC1 struct {
+	rom []byte
+	ram []byte
+
+	irMode  bool
+	romBank byte // 6 bits (1..63)
+	ramBank byte // 0..3
+
+	irLED     bool
+	irReceive bool
+}
+
+func NewHuC1(rom []byte, ramSize int) *HuC1 {
+	m := &HuC1{rom: rom, romBank: 1}
+	if ramSize > 0 {
+		m.ram = make([]byte, ramSize)
+	}
+	return m
+}
+
+// IRLED reports whether the cartridge is currently driving its IR LED.
+func (m *HuC1) IRLED() bool { return m.irLED }
+
+// SetIRReceive sets whether light is seen by the IR receiver.
+func (m *HuC1) SetIRReceive(on bool) { m.irReceive = on }
+
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *HuC1) ROMBank() int { return mirrorBank(m.rom, int(m.romBank)) }
+
+func (m *HuC1) Read(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		if int(addr) < len(m.rom) {
+			return m.rom[addr]
+		}
+		return 0xFF
+	case addr < 0x8000:
+		off := int(m.romBank)*0x4000 + int(addr-0x4000)
+		if len(m.rom) > 0 {
+			off %= len(m.rom)
+		}
+		if off < len(m.rom) {
+			return m.rom[off]
+		}
+		return 0xFF
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if m.irMode {
+			if m.irReceive {
+				return 0xC1
+			}
+			return 0xC0
+		}
+		if len(m.ram) == 0 {
+			return 0xFF
+		}
+		off := (int(m.ramBank)*0x2000 + int(addr-0xA000)) % len(m.ram)
+		return m.ram[off]
+	default:
+		return 0xFF
+	}
+}
+
+func (m *HuC1) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.irMode = value == 0x0E
+	case addr < 0x4000:
+		m.romBank = value & 0x3F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr < 0x6000:
+		m.ramBank = value & 0x03
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if m.irMode {
+			m.irLED = (value & 0x01) != 0
+			return
+		}
+		if len(m.ram) == 0 {
+			return
+		}
+		off := (int(m.ramBank)*0x2000 + int(addr-0xA000)) % len(m.ram)
+		m.ram[off] = value
+	}
+}
+
+// BatteryBacked implementation
+func (m *HuC1) SaveRAM() []byte {
+	if len(m.ram) == 0 {
+		return nil
+	}
+	out := make([]byte, len(m.ram))
+	copy(out, m.ram)
+	return out
+}
+
+func (m *HuC1) LoadRAM(data []byte) {
+	if len(m.ram) == 0 || len(data) == 0 {
+		return
+	}
+	copy(m.ram, data)
+}
+
+// SaveState/LoadState for save states
+type huc1State struct {
+	RAM     []byte
+	IRMode  bool
+	RomBank byte
+	RamBank byte
+	IRLED   bool
+}
+
+func (m *HuC1) SaveState() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	s := huc1State{RAM: append([]byte(nil), m.ram...), IRMode: m.irMode, RomBank: m.romBank, RamBank: m.ramBank, IRLED: m.irLED}
+	_ = enc.Encode(s)
+	return buf.Bytes()
+}
+
+func (m *HuC1) LoadState(data []byte) {
+	var s huc1State
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&s); err != nil {
+		return
+	}
+	if len(m.ram) > 0 && len(s.RAM) > 0 {
+		copy(m.ram, s.RAM)
+	}
+	m.irMode, m.romBank, m.ramBank, m.irLED = s.IRMode, s.RomBank, s.RamBank, s.IRLED
+}
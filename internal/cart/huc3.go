@@ -0,0 +1,298 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+)
+
+// HuC3 implements Hudson's HuC3 mapper: RAM banking, an IR port and a command-driven RTC with alarm.
+// - 0000-1FFF: A000-BFFF mode (low nibble): 0x0 RAM read-only, 0xA RAM, 0xB RTC command, 0xC RTC response, 0xD semaphore, 0xE IR
+// - 2000-3FFF: ROM bank (7 bits)
+// - 4000-5FFF: RAM bank (2 bits)
+// The RTC is a small controller with 256 nibbles of memory. Command bytes written in mode 0xB carry
+// the command in bits 4-6 and an argument in bits 0-3:
+// - 0x1: read mem[addr] into the response, addr++
+// - 0x3: write the argument to mem[addr], addr++
+// - 0x4/0x5: set the low/high nibble of addr
+// - 0x6: extended; arg 0 copies the clock to mem[0..5], arg 1 sets the clock from mem[0..5], arg 2 reports ready
+// The clock counts minutes of the day (mem[0..2]) and days (mem[3..5]), 12 bits each. The alarm lives in
+// mem[0x58..0x5A] (minutes), mem[0x5B..0x5D] (days) and mem[0x5E] bit0 (enable).
+// Commands complete immediately, so the semaphore always reads ready.
+type HuC3 struct {
+	rom []byte
+	ram []byte
+
+	mode    byte
+	romBank byte // 7 bits
+	ramBank byte // 0..3
+
+	irLED     bool
+	irReceive bool
+
+	// RTC controller
+	rtcMem       [256]byte // nibbles
+	rtcAddr      byte
+	rtcCmd       byte // last command (3 bits)
+	rtcResult    byte // last response nibble
+	rtcMinutes   uint16
+	rtcDays      uint16
+	rtcSeconds   byte // sub-minute remainder
+	alarmRinging bool
+
+	lastRTCWallSec int64
+}
+
+const (
+	huc3MinutesPerDay = 24 * 60
+	huc3AlarmBase     = 0x58
+)
+
+func NewHuC3(rom []byte, ramSize int) *HuC3 {
+	m := &HuC3{rom: rom, romBank: 1}
+	if ramSize > 0 {
+		m.ram = make([]byte, ramSize)
+	}
+	m.lastRTCWallSec = nowUnix()
+	return m
+}
+
+// IRLED reports whether the cartridge is currently driving its IR LED.
+func (m *HuC3) IRLED() bool { return m.irLED }
+
+// SetIRReceive sets whether light is seen by the IR receiver.
+func (m *HuC3) SetIRReceive(on bool) { m.irReceive = on }
+
+// AlarmRinging reports whether the clock has reached the enabled alarm time.
+// It stays set until the game disables the alarm.
+func (m *HuC3) AlarmRinging() bool {
+	m.updateRTC()
+	return m.alarmRinging
+}
+
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *HuC3) ROMBank() int { return mirrorBank(m.rom, int(m.romBank)) }
+
+func (m *HuC3) Read(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		if int(addr) < len(m.rom) {
+			return m.rom[addr]
+		}
+		return 0xFF
+	case addr < 0x8000:
+		off := int(m.romBank)*0x4000 + int(addr-0x4000)
+		if len(m.rom) > 0 {
+			off %= len(m.rom)
+		}
+		if off < len(m.rom) {
+			return m.rom[off]
+		}
+		return 0xFF
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		switch m.mode {
+		case 0x0, 0xA:
+			if len(m.ram) == 0 {
+				return 0xFF
+			}
+			return m.ram[(int(m.ramBank)*0x2000+int(addr-0xA000))%len(m.ram)]
+		case 0xC:
+			return 0x80 | m.rtcCmd<<4 | m.rtcResult
+		case 0xD:
+			return 0xFF // ready
+		case 0xE:
+			if m.irReceive {
+				return 0xC1
+			}
+			return 0xC0
+		}
+		return 0xFF
+	default:
+		return 0xFF
+	}
+}
+
+func (m *HuC3) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.mode = value & 0x0F
+	case addr < 0x4000:
+		m.romBank = value & 0x7F
+	case addr < 0x6000:
+		m.ramBank = value & 0x03
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		switch m.mode {
+		case 0xA:
+			if len(m.ram) > 0 {
+				m.ram[(int(m.ramBank)*0x2000+int(addr-0xA000))%len(m.ram)] = value
+			}
+		case 0xB:
+			m.rtcCommand((value>>4)&0x07, value&0x0F)
+		case 0xE:
+			m.irLED = (value & 0x01) != 0
+		}
+	}
+}
+
+func (m *HuC3) rtcCommand(cmd, arg byte) {
+	m.updateRTC()
+	m.rtcCmd = cmd
+	switch cmd {
+	case 0x1:
+		m.rtcResult = m.rtcMem[m.rtcAddr] & 0x0F
+		m.rtcAddr++
+	case 0x3:
+		m.rtcMem[m.rtcAddr] = arg
+		if m.rtcAddr == huc3AlarmBase+6 && (arg&0x01) == 0 {
+			m.alarmRinging = false
+		}
+		m.rtcAddr++
+	case 0x4:
+		m.rtcAddr = (m.rtcAddr & 0xF0) | arg
+	case 0x5:
+		m.rtcAddr = (m.rtcAddr & 0x0F) | arg<<4
+	case 0x6:
+		switch arg {
+		case 0x0:
+			m.putNibbles(0, m.rtcMinutes)
+			m.putNibbles(3, m.rtcDays)
+		case 0x1:
+			m.rtcMinutes = m.getNibbles(0) % huc3MinutesPerDay
+			m.rtcDays = m.getNibbles(3)
+			m.rtcSeconds = 0
+		case 0x2:
+			m.rtcResult = 0x1
+		}
+	}
+}
+
+// putNibbles/getNibbles store a 12-bit value in three consecutive nibbles, low first.
+func (m *HuC3) putNibbles(at int, v uint16) {
+	for i := 0; i < 3; i++ {
+		m.rtcMem[at+i] = byte(v>>(4*i)) & 0x0F
+	}
+}
+
+func (m *HuC3) getNibbles(at int) uint16 {
+	var v uint16
+	for i := 0; i < 3; i++ {
+		v |= uint16(m.rtcMem[at+i]&0x0F) << (4 * i)
+	}
+	return v
+}
+
+func (m *HuC3) updateRTC() {
+	now := nowUnix()
+	if now <= m.lastRTCWallSec {
+		return
+	}
+	delta := now - m.lastRTCWallSec
+	m.lastRTCWallSec = now
+	before := int64(m.rtcDays)*huc3MinutesPerDay + int64(m.rtcMinutes)
+	total := int64(m.rtcSeconds) + delta
+	m.rtcSeconds = byte(total % 60)
+	mins := int64(m.rtcMinutes) + total/60
+	m.rtcMinutes = uint16(mins % huc3MinutesPerDay)
+	m.rtcDays = uint16((int64(m.rtcDays) + mins/huc3MinutesPerDay) & 0x0FFF)
+	after := before + total/60
+	if (m.rtcMem[huc3AlarmBase+6] & 0x01) != 0 {
+		alarm := int64(m.getNibbles(huc3AlarmBase+3))*huc3MinutesPerDay + int64(m.getNibbles(huc3AlarmBase))
+		if alarm > before && alarm <= after {
+			m.alarmRinging = true
+		}
+	}
+}
+
+// BatteryBacked implementation: RAM followed by an RTC footer
+// ("HUC3", version, minutes, days, seconds, wall-clock seconds, 256 controller nibbles).
+const huc3FooterLen = 4 + 1 + 2 + 2 + 1 + 8 + 256
+
+func (m *HuC3) SaveRAM() []byte {
+	m.updateRTC()
+	base := len(m.ram)
+	out := make([]byte, base+huc3FooterLen)
+	copy(out, m.ram)
+	f := out[base:]
+	copy(f[0:4], []byte{'H', 'U', 'C', '3'})
+	f[4] = 1 // version
+	binary.LittleEndian.PutUint16(f[5:], m.rtcMinutes)
+	binary.LittleEndian.PutUint16(f[7:], m.rtcDays)
+	f[9] = m.rtcSeconds
+	binary.LittleEndian.PutUint64(f[10:], uint64(m.lastRTCWallSec))
+	copy(f[18:], m.rtcMem[:])
+	return out
+}
+
+func (m *HuC3) LoadRAM(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if len(m.ram) > 0 {
+		n := len(data)
+		if n > len(m.ram) {
+			n = len(m.ram)
+		}
+		copy(m.ram, data[:n])
+	}
+	if len(data) < len(m.ram)+huc3FooterLen {
+		return
+	}
+	f := data[len(m.ram):]
+	if string(f[0:4]) != "HUC3" || f[4] != 1 {
+		return
+	}
+	m.rtcMinutes = binary.LittleEndian.Uint16(f[5:]) % huc3MinutesPerDay
+	m.rtcDays = binary.LittleEndian.Uint16(f[7:]) & 0x0FFF
+	m.rtcSeconds = f[9] % 60
+	m.lastRTCWallSec = int64(binary.LittleEndian.Uint64(f[10:]))
+	copy(m.rtcMem[:], f[18:18+256])
+	// Catch up on the time that passed while the emulator was closed
+	m.updateRTC()
+}
+
+// SaveState/LoadState for save states
+type huc3State struct {
+	RAM          []byte
+	Mode         byte
+	RomBank      byte
+	RamBank      byte
+	IRLED        bool
+	RTCMem       [256]byte
+	RTCAddr      byte
+	RTCCmd       byte
+	RTCResult    byte
+	RTCMinutes   uint16
+	RTCDays      uint16
+	RTCSeconds   byte
+	AlarmRinging bool
+	LastWall     int64
+}
+
+func (m *HuC3) SaveState() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	s := huc3State{
+		RAM:  append([]byte(nil), m.ram...),
+		Mode: m.mode, RomBank: m.romBank, RamBank: m.ramBank, IRLED: m.irLED,
+		RTCMem: m.rtcMem, RTCAddr: m.rtcAddr, RTCCmd: m.rtcCmd, RTCResult: m.rtcResult,
+		RTCMinutes: m.rtcMinutes, RTCDays: m.rtcDays, RTCSeconds: m.rtcSeconds,
+		AlarmRinging: m.alarmRinging, LastWall: m.lastRTCWallSec,
+	}
+	_ = enc.Encode(s)
+	return buf.Bytes()
+}
+
+func (m *HuC3) LoadState(data []byte) {
+	var s huc3State
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&s); err != nil {
+		return
+	}
+	if len(m.ram) > 0 && len(s.RAM) > 0 {
+		copy(m.ram, s.RAM)
+	}
+	m.mode, m.romBank, m.ramBank, m.irLED = s.Mode, s.RomBank, s.RamBank, s.IRLED
+	m.rtcMem, m.rtcAddr, m.rtcCmd, m.rtcResult = s.RTCMem, s.RTCAddr, s.RTCCmd, s.RTCResult
+	m.rtcMinutes, m.rtcDays, m.rtcSeconds = s.RTCMinutes, s.RTCDays, s.RTCSeconds
+	m.alarmRinging, m.lastRTCWallSec = s.AlarmRinging, s.LastWall
+}
@@ -0,0 +1,119 @@
+package cart
+
+import "testing"
+
+func TestHuC1_IRModeReplacesRAM(t *testing.T) {
+	rom := make([]byte, 128*1024)
+	for bank := 0; bank < 8; bank++ {
+		rom[bank*0x4000] = byte(bank)
+	}
+	m := NewHuC1(rom, 0x8000)
+	m.Write(0x2000, 0x03)
+	if got := m.Read(0x4000); got != 0x03 {
+		t.Fatalf("bank3 read got %02X want 03", got)
+	}
+	m.Write(0x4000, 0x01)
+	m.Write(0xA000, 0x5A)
+	// IR mode: reads the receiver, writes drive the LED, RAM untouched
+	m.Write(0x0000, 0x0E)
+	if got := m.Read(0xA000); got != 0xC0 {
+		t.Fatalf("IR read without light got %02X want C0", got)
+	}
+	m.SetIRReceive(true)
+	if got := m.Read(0xA000); got != 0xC1 {
+		t.Fatalf("IR read with light got %02X want C1", got)
+	}
+	m.Write(0xA000, 0x01)
+	if !m.IRLED() {
+		t.Fatalf("IR LED not on after write")
+	}
+	m.Write(0x0000, 0x00)
+	if got := m.Read(0xA000); got != 0x5A {
+		t.Fatalf("RAM bank1 read got %02X want 5A", got)
+	}
+	if sav := m.SaveRAM(); len(sav) != 0x8000 || sav[0x2000] != 0x5A {
+		t.Fatalf("SaveRAM len=%d [2000]=%02X", len(sav), sav[0x2000])
+	}
+}
+
+// huc3Cmd writes one RTC command byte in mode 0xB and returns the mode 0xC response.
+func huc3Cmd(m *HuC3, cmd, arg byte) byte {
+	m.Write(0x0000, 0x0B)
+	m.Write(0xA000, cmd<<4|arg)
+	m.Write(0x0000, 0x0C)
+	return m.Read(0xA000)
+}
+
+func TestHuC3_RTCCommandsAndAlarm(t *testing.T) {
+	prevNow := nowUnix
+	nowVal := int64(1000)
+	nowUnix = func() int64 { return nowVal }
+	defer func() { nowUnix = prevNow }()
+
+	m := NewHuC3(make([]byte, 0x8000), 0x2000)
+	// Set the clock to day 2, 23:59 (1439 = 0x59F minutes) via mem[0..5] and extended cmd 1
+	huc3Cmd(m, 0x4, 0x0)
+	huc3Cmd(m, 0x5, 0x0)
+	for _, n := range []byte{0xF, 0x9, 0x5, 0x2, 0x0, 0x0} {
+		huc3Cmd(m, 0x3, n)
+	}
+	huc3Cmd(m, 0x6, 0x1)
+	// Alarm at day 3, 00:01 and enable it
+	huc3Cmd(m, 0x4, 0x8)
+	huc3Cmd(m, 0x5, 0x5)
+	for _, n := range []byte{0x1, 0x0, 0x0, 0x3, 0x0, 0x0, 0x1} {
+		huc3Cmd(m, 0x3, n)
+	}
+	if m.AlarmRinging() {
+		t.Fatalf("alarm ringing before its time")
+	}
+	nowVal += 2 * 60
+	// Latch the clock and read it back: 00:01 on day 3
+	huc3Cmd(m, 0x6, 0x0)
+	huc3Cmd(m, 0x4, 0x0)
+	huc3Cmd(m, 0x5, 0x0)
+	var got []byte
+	for i := 0; i < 6; i++ {
+		got = append(got, huc3Cmd(m, 0x1, 0)&0x0F)
+	}
+	if want := []byte{0x1, 0x0, 0x0, 0x3, 0x0, 0x0}; string(got) != string(want) {
+		t.Fatalf("latched clock nibbles %v want %v", got, want)
+	}
+	if r := huc3Cmd(m, 0x1, 0); r&0xF0 != 0x90 {
+		t.Fatalf("response high nibble got %02X want 9x", r)
+	}
+	if !m.AlarmRinging() {
+		t.Fatalf("alarm did not ring")
+	}
+	m.Write(0x0000, 0x0D)
+	if got := m.Read(0xA000) & 0x01; got != 1 {
+		t.Fatalf("semaphore not ready")
+	}
+}
+
+func TestHuC3_RTCPersistsInSave(t *testing.T) {
+	prevNow := nowUnix
+	nowVal := int64(5000)
+	nowUnix = func() int64 { return nowVal }
+	defer func() { nowUnix = prevNow }()
+
+	m := NewHuC3(make([]byte, 0x8000), 0x2000)
+	m.Write(0x0000, 0x0A)
+	m.Write(0xA123, 0x77)
+	m.rtcMinutes, m.rtcDays = 100, 7
+	sav := m.SaveRAM()
+	if len(sav) != 0x2000+huc3FooterLen || sav[0x123] != 0x77 {
+		t.Fatalf("SaveRAM len=%d", len(sav))
+	}
+	// One hour later a fresh cart picks up where the save left off
+	nowVal += 3600
+	n := NewHuC3(make([]byte, 0x8000), 0x2000)
+	n.LoadRAM(sav)
+	if n.rtcMinutes != 160 || n.rtcDays != 7 {
+		t.Fatalf("restored clock min=%d days=%d want 160 7", n.rtcMinutes, n.rtcDays)
+	}
+	n.Write(0x0000, 0x00)
+	if got := n.Read(0xA123); got != 0x77 {
+		t.Fatalf("restored RAM got %02X want 77", got)
+	}
+}
@@ -7,10 +7,14 @@ import (
 
 // MBC1 implements basic MBC1 ROM/RAM banking.
 // Supports ROM banking up to 2MB and RAM up to 32KB. Battery/RTC not handled here.
+// Multicart (MBC1M) boards wire only 4 bits of the low bank register, so the two high bits
+// select a 256 KiB game instead of a 512 KiB region.
 type MBC1 struct {
 	rom []byte
 	ram []byte
 
+	multicart bool // MBC1M: high bits shift by 4 instead of 5
+
 	romBankLow5       byte // lower 5 bits of ROM bank number (0->1 remapped)
 	ramBankOrRomHigh2 byte // either RAM bank (mode1) or ROM bank high bits (mode0)
 	ramEnabled        bool
@@ -27,6 +31,39 @@ func NewMBC1(rom []byte, ramSize int) *MBC1 {
 	return m
 }
 
+// NewMBC1M creates an MBC1 wired as a multicart (MBC1M).
+func NewMBC1M(rom []byte, ramSize int) *MBC1 {
+	m := NewMBC1(rom, ramSize)
+	m.multicart = true
+	return m
+}
+
+// IsMBC1Multicart reports whether an MBC1 ROM looks like an MBC1M compilation: a 1 MiB image
+// whose games each start with their own Nintendo logo at a 256 KiB boundary.
+func IsMBC1Multicart(rom []byte) bool {
+	if len(rom) != 1024*1024 {
+		return false
+	}
+	logos := 0
+	for base := 0x40000; base < len(rom); base += 0x40000 {
+		if hasNintendoLogo(rom[base:]) {
+			logos++
+		}
+	}
+	return logos >= 2
+}
+
+// bankShift is where the two high bank bits land in the ROM bank number.
+func (m *MBC1) bankShift() byte {
+	if m.multicart {
+		return 4
+	}
+	return 5
+}
+
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *MBC1) ROMBank() int { return mirrorBank(m.rom, int(m.effectiveROMBank())) }
+
 func (m *MBC1) Read(addr uint16) byte {
 	switch {
 	case addr < 0x4000:
@@ -39,7 +76,7 @@ func (m *MBC1) Read(addr uint16) byte {
 			return 0xFF
 		}
 		// mode 1: apply high bits to bank 0 region
-		bank := int((m.ramBankOrRomHigh2 & 0x03) << 5)
+		bank := int((m.ramBankOrRomHigh2 & 0x03) << m.bankShift())
 		off := bank*0x4000 + int(addr)
 		if off < len(m.rom) {
 			return m.rom[off]
@@ -106,6 +143,10 @@ func (m *MBC1) Write(addr uint16, value byte) {
 func (m *MBC1) effectiveROMBank() byte {
 	// Combine high 2 bits (when in ROM banking mode) with low5; ensure bank != 0, 0x20, 0x40, 0x60 remapped per spec
 	high := m.ramBankOrRomHigh2 & 0x03
+	if m.multicart {
+		// Bit 4 of the low register is not connected; writing 0x10 selects the game's bank 0
+		return (m.romBankLow5 & 0x0F) | (high << 4)
+	}
 	bank := m.romBankLow5 | (high << 5)
 	// Avoid forbidden banks when low5 is zero (already remapped to 1). Additional remap not strictly necessary here.
 	return bank
@@ -0,0 +1,113 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// MBC2 implements the MBC2 mapper with its built-in 512x4-bit RAM.
+// - 0000-3FFF: address bit 8 clear -> RAM enable (0x0A in low nibble); set -> ROM bank (4 bits, 0 maps to 1)
+// - 4000-7FFF: switchable ROM bank (1..15)
+// - A000-BFFF: 512 half-bytes, echoed every 0x200 bytes; upper nibble reads as 1s
+type MBC2 struct {
+	rom []byte
+	ram [512]byte // only the low nibble of each byte is used
+
+	ramEnabled bool
+	romBank    byte // 4 bits (1..15)
+}
+
+func NewMBC2(rom []byte) *MBC2 {
+	return &MBC2{rom: rom, romBank: 1}
+}
+
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *MBC2) ROMBank() int { return mirrorBank(m.rom, int(m.romBank)) }
+
+func (m *MBC2) Read(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		if int(addr) < len(m.rom) {
+			return m.rom[addr]
+		}
+		return 0xFF
+	case addr < 0x8000:
+		off := int(m.romBank)*0x4000 + int(addr-0x4000)
+		if len(m.rom) > 0 {
+			off %= len(m.rom)
+		}
+		if off < len(m.rom) {
+			return m.rom[off]
+		}
+		return 0xFF
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled {
+			return 0xFF
+		}
+		return 0xF0 | m.ram[addr&0x01FF]
+	default:
+		return 0xFF
+	}
+}
+
+func (m *MBC2) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x4000:
+		if (addr & 0x0100) == 0 {
+			m.ramEnabled = (value & 0x0F) == 0x0A
+			return
+		}
+		m.romBank = value & 0x0F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled {
+			return
+		}
+		m.ram[addr&0x01FF] = value & 0x0F
+	}
+}
+
+// BatteryBacked implementation: 512 bytes, one nibble per byte
+func (m *MBC2) SaveRAM() []byte {
+	out := make([]byte, len(m.ram))
+	copy(out, m.ram[:])
+	return out
+}
+
+func (m *MBC2) LoadRAM(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	n := copy(m.ram[:], data)
+	for i := 0; i < n; i++ {
+		m.ram[i] &= 0x0F
+	}
+}
+
+// SaveState/LoadState for save states
+type mbc2State struct {
+	RAM        [512]byte
+	RamEnabled bool
+	RomBank    byte
+}
+
+func (m *MBC2) SaveState() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	s := mbc2State{RAM: m.ram, RamEnabled: m.ramEnabled, RomBank: m.romBank}
+	_ = enc.Encode(s)
+	return buf.Bytes()
+}
+
+func (m *MBC2) LoadState(data []byte) {
+	var s mbc2State
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&s); err != nil {
+		return
+	}
+	m.ram = s.RAM
+	m.ramEnabled = s.RamEnabled
+	m.romBank = s.RomBank
+}
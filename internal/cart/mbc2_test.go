@@ -0,0 +1,71 @@
+package cart
+
+import "testing"
+
+func TestMBC2_RegisterSelectByAddressBit8(t *testing.T) {
+	rom := make([]byte, 256*1024)
+	for bank := 0; bank < 16; bank++ {
+		rom[bank*0x4000] = byte(bank)
+	}
+	m := NewMBC2(rom)
+	if got := m.Read(0x4000); got != 0x01 {
+		t.Fatalf("default bank got %02X want 01", got)
+	}
+	// bit8 set -> ROM bank
+	m.Write(0x2100, 0x05)
+	if got := m.Read(0x4000); got != 0x05 {
+		t.Fatalf("bank5 read got %02X want 05", got)
+	}
+	// Only 4 bits are used and 0 maps to 1
+	m.Write(0x0100, 0x30)
+	if got := m.Read(0x4000); got != 0x01 {
+		t.Fatalf("bank 0x30 got %02X want 01", got)
+	}
+	// bit8 clear -> RAM enable, bank unchanged
+	m.Write(0x2100, 0x0F)
+	m.Write(0x2000, 0x0A)
+	if got := m.Read(0x4000); got != 0x0F {
+		t.Fatalf("RAM enable write changed bank: %02X", got)
+	}
+	if !m.ramEnabled {
+		t.Fatalf("RAM not enabled by write with address bit8 clear")
+	}
+}
+
+func TestMBC2_NibbleRAMEchoAndBattery(t *testing.T) {
+	m := NewMBC2(make([]byte, 0x8000))
+	m.Write(0xA000, 0x05)
+	if got := m.Read(0xA000); got != 0xFF {
+		t.Fatalf("disabled RAM read got %02X want FF", got)
+	}
+	m.Write(0x0000, 0x0A)
+	m.Write(0xA010, 0xAB)
+	if got := m.Read(0xA010); got != 0xFB {
+		t.Fatalf("nibble read got %02X want FB", got)
+	}
+	// 512 bytes echo across A000-BFFF
+	if got := m.Read(0xA210); got != 0xFB {
+		t.Fatalf("echo A210 got %02X want FB", got)
+	}
+	if got := m.Read(0xBE10); got != 0xFB {
+		t.Fatalf("echo BE10 got %02X want FB", got)
+	}
+	sav := m.SaveRAM()
+	if len(sav) != 512 || sav[0x10] != 0x0B {
+		t.Fatalf("SaveRAM len=%d [10]=%02X", len(sav), sav[0x10])
+	}
+	n := NewMBC2(make([]byte, 0x8000))
+	n.LoadRAM(sav)
+	n.Write(0x0000, 0x0A)
+	if got := n.Read(0xA010); got != 0xFB {
+		t.Fatalf("LoadRAM read got %02X want FB", got)
+	}
+	// Save state round-trip keeps bank and RAM
+	m.Write(0x2100, 0x02)
+	st := m.SaveState()
+	o := NewMBC2(make([]byte, 0x8000))
+	o.LoadState(st)
+	if o.romBank != 2 || !o.ramEnabled || o.Read(0xA010) != 0xFB {
+		t.Fatalf("LoadState bank=%d en=%v", o.romBank, o.ramEnabled)
+	}
+}
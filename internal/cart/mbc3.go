@@ -57,6 +57,9 @@ func NewMBC3(rom []byte, ramSize int) *MBC3 {
 	return m
 }
 
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *MBC3) ROMBank() int { return mirrorBank(m.rom, int(m.romBank&0x7F)) }
+
 func (m *MBC3) Read(addr uint16) byte {
 	m.updateRTC()
 	switch {
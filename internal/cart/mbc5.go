@@ -24,6 +24,9 @@ func NewMBC5(rom []byte, ramSize int) *MBC5 {
 	return m
 }
 
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *MBC5) ROMBank() int { return mirrorBank(m.rom, int(m.romBank)) }
+
 func (m *MBC5) Read(addr uint16) byte {
 	switch {
 	case addr < 0x4000:
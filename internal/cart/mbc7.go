@@ -0,0 +1,373 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// MBC7 implements the MBC7 mapper with its 2-axis accelerometer and 93LC56 serial EEPROM.
+// - 0000-1FFF: RAM enable 1 (0x0A)
+// - 2000-3FFF: ROM bank (7 bits)
+// - 4000-5FFF: RAM enable 2 (0x40); both enables are needed to reach the registers
+// - A000-AFFF: registers selected by address bits 4-7 (see readReg/writeReg)
+// - B000-BFFF: open bus (0xFF)
+// The accelerometer reports 0x81D0 at rest on each axis and moves by about 0x70 per g.
+type MBC7 struct {
+	rom []byte
+
+	ramEnable1 bool
+	ramEnable2 bool
+	romBank    byte
+
+	// Accelerometer: live tilt (in g) and the values latched by the 0x55/0xAA sequence
+	tiltX, tiltY float64
+	latchX       uint16
+	latchY       uint16
+	latchArmed   bool
+
+	eeprom eeprom93LC56
+}
+
+const (
+	mbc7AccelCenter = 0x81D0
+	mbc7AccelPerG   = 0x70
+)
+
+func NewMBC7(rom []byte) *MBC7 {
+	m := &MBC7{rom: rom, romBank: 1, latchX: 0x8000, latchY: 0x8000}
+	m.eeprom.reset()
+	for i := range m.eeprom.words {
+		m.eeprom.words[i] = 0xFFFF
+	}
+	return m
+}
+
+// SetTilt feeds the accelerometer in units of g, clamped to [-1, 1].
+// Positive x tilts the cartridge right, positive y tilts it towards the player.
+func (m *MBC7) SetTilt(x, y float64) {
+	m.tiltX = clampTilt(x)
+	m.tiltY = clampTilt(y)
+}
+
+func clampTilt(v float64) float64 {
+	if v < -1 {
+		return -1
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *MBC7) ROMBank() int { return mirrorBank(m.rom, int(m.romBank)) }
+
+func (m *MBC7) Read(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		if int(addr) < len(m.rom) {
+			return m.rom[addr]
+		}
+		return 0xFF
+	case addr < 0x8000:
+		off := int(m.romBank)*0x4000 + int(addr-0x4000)
+		if len(m.rom) > 0 {
+			off %= len(m.rom)
+		}
+		if off < len(m.rom) {
+			return m.rom[off]
+		}
+		return 0xFF
+	case addr >= 0xA000 && addr <= 0xAFFF:
+		if !m.ramEnable1 || !m.ramEnable2 {
+			return 0xFF
+		}
+		return m.readReg(byte(addr>>4) & 0x0F)
+	default:
+		return 0xFF
+	}
+}
+
+func (m *MBC7) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnable1 = value == 0x0A
+		if !m.ramEnable1 {
+			m.ramEnable2 = false
+		}
+	case addr < 0x4000:
+		m.romBank = value & 0x7F
+	case addr < 0x6000:
+		m.ramEnable2 = m.ramEnable1 && value == 0x40
+	case addr >= 0xA000 && addr <= 0xAFFF:
+		if !m.ramEnable1 || !m.ramEnable2 {
+			return
+		}
+		m.writeReg(byte(addr>>4)&0x0F, value)
+	}
+}
+
+// readReg returns register Ax0x..AxFx.
+func (m *MBC7) readReg(reg byte) byte {
+	switch reg {
+	case 0x2:
+		return byte(m.latchX)
+	case 0x3:
+		return byte(m.latchX >> 8)
+	case 0x4:
+		return byte(m.latchY)
+	case 0x5:
+		return byte(m.latchY >> 8)
+	case 0x6:
+		return 0x00 // would-be Z axis
+	case 0x8:
+		return m.eeprom.read()
+	default:
+		return 0xFF
+	}
+}
+
+// writeReg handles the latch sequence (0x55 to Ax0x erases, 0xAA to Ax1x latches)
+// and the EEPROM pins at Ax8x.
+func (m *MBC7) writeReg(reg byte, value byte) {
+	switch reg {
+	case 0x0:
+		if value == 0x55 {
+			m.latchX, m.latchY = 0x8000, 0x8000
+			m.latchArmed = true
+		}
+	case 0x1:
+		if value == 0xAA && m.latchArmed {
+			m.latchX = accelValue(m.tiltX)
+			m.latchY = accelValue(m.tiltY)
+			m.latchArmed = false
+		}
+	case 0x8:
+		m.eeprom.write(value)
+	}
+}
+
+func accelValue(g float64) uint16 {
+	return uint16(int(mbc7AccelCenter) + int(g*mbc7AccelPerG))
+}
+
+// BatteryBacked implementation: the EEPROM contents, 128 words little-endian
+func (m *MBC7) SaveRAM() []byte {
+	out := make([]byte, len(m.eeprom.words)*2)
+	for i, w := range m.eeprom.words {
+		out[i*2] = byte(w)
+		out[i*2+1] = byte(w >> 8)
+	}
+	return out
+}
+
+func (m *MBC7) LoadRAM(data []byte) {
+	for i := range m.eeprom.words {
+		if i*2+1 >= len(data) {
+			return
+		}
+		m.eeprom.words[i] = uint16(data[i*2]) | uint16(data[i*2+1])<<8
+	}
+}
+
+// SaveState/LoadState for save states
+type mbc7State struct {
+	RamEnable1, RamEnable2 bool
+	RomBank                byte
+	LatchX, LatchY         uint16
+	LatchArmed             bool
+	EEPROM                 eeprom93LC56State
+}
+
+func (m *MBC7) SaveState() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	s := mbc7State{
+		RamEnable1: m.ramEnable1, RamEnable2: m.ramEnable2, RomBank: m.romBank,
+		LatchX: m.latchX, LatchY: m.latchY, LatchArmed: m.latchArmed,
+		EEPROM: m.eeprom.state(),
+	}
+	_ = enc.Encode(s)
+	return buf.Bytes()
+}
+
+func (m *MBC7) LoadState(data []byte) {
+	var s mbc7State
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&s); err != nil {
+		return
+	}
+	m.ramEnable1, m.ramEnable2, m.romBank = s.RamEnable1, s.RamEnable2, s.RomBank
+	m.latchX, m.latchY, m.latchArmed = s.LatchX, s.LatchY, s.LatchArmed
+	m.eeprom.setState(s.EEPROM)
+}
+
+// eeprom93LC56 models the 93LC56 Microwire EEPROM in x16 organisation (128 words).
+// The MBC7 register exposes its pins: bit7 CS, bit6 CLK, bit1 DI, bit0 DO.
+// Commands are a start bit, a 2-bit opcode and 8 address bits (A7 unused), clocked in
+// on rising CLK edges while CS is high:
+// - 10 READ: a dummy 0 then 16 data bits, continuing with the next word while clocked
+// - 01 WRITE / 11 ERASE: need EWEN; 16 data bits follow WRITE
+// - 00 with address 11xxxxxx EWEN, 00xxxxxx EWDS, 10xxxxxx ERAL, 01xxxxxx WRAL (+16 data bits)
+// Writes complete instantly, so DO reads back ready (1) afterwards.
+type eeprom93LC56 struct {
+	words [128]uint16
+
+	cs, clk bool
+	di, do  byte
+
+	writeEnabled bool
+	mode         byte   // eepromIdle, eepromCommand, eepromRead, eepromWrite, eepromWriteAll
+	shift        uint16 // command or data bits shifted in so far
+	bits         int
+	addr         byte
+	readWord     uint16
+}
+
+const (
+	eepromIdle byte = iota
+	eepromCommand
+	eepromRead
+	eepromWrite
+	eepromWriteAll
+)
+
+func (e *eeprom93LC56) reset() {
+	e.mode = eepromIdle
+	e.shift, e.bits = 0, 0
+	e.do = 1
+}
+
+func (e *eeprom93LC56) read() byte {
+	var v byte
+	if e.cs {
+		v |= 0x80
+	}
+	if e.clk {
+		v |= 0x40
+	}
+	return v | e.di<<1 | e.do
+}
+
+func (e *eeprom93LC56) write(value byte) {
+	cs := (value & 0x80) != 0
+	clk := (value & 0x40) != 0
+	e.di = (value >> 1) & 1
+	if !cs {
+		if e.cs {
+			e.reset()
+		}
+		e.cs, e.clk = false, clk
+		return
+	}
+	rising := e.cs && !e.clk && clk
+	e.cs, e.clk = true, clk
+	if rising {
+		e.clock(e.di)
+	}
+}
+
+// clock shifts one DI bit in (or one DO bit out while reading).
+func (e *eeprom93LC56) clock(bit byte) {
+	switch e.mode {
+	case eepromIdle:
+		if bit == 1 { // leading zeros before the start bit are ignored
+			e.mode = eepromCommand
+			e.shift, e.bits = 1, 1
+		}
+	case eepromCommand:
+		e.shift = e.shift<<1 | uint16(bit)
+		e.bits++
+		if e.bits == 11 {
+			e.command(byte(e.shift>>8)&0x03, byte(e.shift))
+		}
+	case eepromRead:
+		e.do = byte(e.readWord>>15) & 1
+		e.readWord <<= 1
+		e.bits++
+		if e.bits == 16 {
+			e.addr = (e.addr + 1) & 0x7F
+			e.readWord = e.words[e.addr]
+			e.bits = 0
+		}
+	case eepromWrite, eepromWriteAll:
+		e.shift = e.shift<<1 | uint16(bit)
+		e.bits++
+		if e.bits < 16 {
+			return
+		}
+		if e.writeEnabled {
+			if e.mode == eepromWrite {
+				e.words[e.addr] = e.shift
+			} else {
+				for i := range e.words {
+					e.words[i] = e.shift
+				}
+			}
+		}
+		e.mode = eepromIdle
+		e.do = 1
+	}
+}
+
+func (e *eeprom93LC56) command(op, addr byte) {
+	e.addr = addr & 0x7F
+	e.shift, e.bits = 0, 0
+	e.mode = eepromIdle
+	switch op {
+	case 0x2: // READ
+		e.mode = eepromRead
+		e.readWord = e.words[e.addr]
+		e.do = 0
+	case 0x1: // WRITE
+		e.mode = eepromWrite
+	case 0x3: // ERASE
+		if e.writeEnabled {
+			e.words[e.addr] = 0xFFFF
+		}
+		e.do = 1
+	case 0x0:
+		switch addr >> 6 {
+		case 0x0: // EWDS
+			e.writeEnabled = false
+		case 0x1: // WRAL
+			e.mode = eepromWriteAll
+		case 0x2: // ERAL
+			if e.writeEnabled {
+				for i := range e.words {
+					e.words[i] = 0xFFFF
+				}
+			}
+			e.do = 1
+		case 0x3: // EWEN
+			e.writeEnabled = true
+		}
+	}
+}
+
+type eeprom93LC56State struct {
+	Words        [128]uint16
+	CS, CLK      bool
+	DI, DO       byte
+	WriteEnabled bool
+	Mode         byte
+	Shift        uint16
+	Bits         int
+	Addr         byte
+	ReadWord     uint16
+}
+
+func (e *eeprom93LC56) state() eeprom93LC56State {
+	return eeprom93LC56State{
+		Words: e.words, CS: e.cs, CLK: e.clk, DI: e.di, DO: e.do,
+		WriteEnabled: e.writeEnabled, Mode: e.mode, Shift: e.shift, Bits: e.bits,
+		Addr: e.addr, ReadWord: e.readWord,
+	}
+}
+
+func (e *eeprom93LC56) setState(s eeprom93LC56State) {
+	e.words = s.Words
+	e.cs, e.clk, e.di, e.do = s.CS, s.CLK, s.DI, s.DO
+	e.writeEnabled, e.mode, e.shift, e.bits = s.WriteEnabled, s.Mode, s.Shift, s.Bits
+	e.addr, e.readWord = s.Addr, s.ReadWord
+}
@@ -0,0 +1,98 @@
+package cart
+
+import "testing"
+
+func newEnabledMBC7() *MBC7 {
+	m := NewMBC7(make([]byte, 0x8000))
+	m.Write(0x0000, 0x0A)
+	m.Write(0x4000, 0x40)
+	return m
+}
+
+func TestMBC7_AccelerometerLatch(t *testing.T) {
+	m := NewMBC7(make([]byte, 0x8000))
+	if got := m.Read(0xA020); got != 0xFF {
+		t.Fatalf("disabled register read got %02X want FF", got)
+	}
+	m.Write(0x0000, 0x0A)
+	m.Write(0x4000, 0x40)
+	m.SetTilt(1, -0.5)
+	// Latch needs 0x55 to Ax0x first
+	m.Write(0xA010, 0xAA)
+	if got := m.Read(0xA030); got != 0x80 {
+		t.Fatalf("unarmed latch X hi got %02X want 80", got)
+	}
+	m.Write(0xA000, 0x55)
+	m.Write(0xA010, 0xAA)
+	x := uint16(m.Read(0xA020)) | uint16(m.Read(0xA030))<<8
+	y := uint16(m.Read(0xA040)) | uint16(m.Read(0xA050))<<8
+	if x != 0x81D0+0x70 || y != 0x81D0-0x38 {
+		t.Fatalf("latched x=%04X y=%04X want %04X %04X", x, y, 0x81D0+0x70, 0x81D0-0x38)
+	}
+	// Values hold until the next latch sequence
+	m.SetTilt(0, 0)
+	if got := m.Read(0xA020); got != 0x40 {
+		t.Fatalf("latched X changed without relatch: %02X", got)
+	}
+}
+
+// eepromSend clocks bits into the EEPROM, MSB first, and returns DO sampled after each edge.
+func eepromSend(m *MBC7, v uint32, n int) uint32 {
+	var out uint32
+	for i := n - 1; i >= 0; i-- {
+		di := byte(v>>uint(i)&1) << 1
+		m.Write(0xA080, 0x80|di)
+		m.Write(0xA080, 0xC0|di)
+		out = out<<1 | uint32(m.Read(0xA080)&1)
+	}
+	return out
+}
+
+func eepromDeselect(m *MBC7) { m.Write(0xA080, 0x00) }
+
+func TestMBC7_EEPROMWriteReadAndBattery(t *testing.T) {
+	m := newEnabledMBC7()
+	// WRITE without EWEN is ignored
+	eepromSend(m, 0b1_01_00000101, 11)
+	eepromSend(m, 0x1234, 16)
+	eepromDeselect(m)
+	if m.eeprom.words[5] != 0xFFFF {
+		t.Fatalf("write without EWEN stored %04X", m.eeprom.words[5])
+	}
+	eepromSend(m, 0b1_00_11000000, 11) // EWEN
+	eepromDeselect(m)
+	eepromSend(m, 0b1_01_00000101, 11)
+	eepromSend(m, 0x1234, 16)
+	eepromDeselect(m)
+	eepromSend(m, 0b1_01_00000110, 11)
+	eepromSend(m, 0xBEEF, 16)
+	eepromDeselect(m)
+	// READ: dummy 0 already on DO, then 16 data bits; reading continues into the next word
+	eepromSend(m, 0b1_10_00000101, 11)
+	if got := m.Read(0xA080) & 1; got != 0 {
+		t.Fatalf("READ dummy bit got %d want 0", got)
+	}
+	if got := eepromSend(m, 0, 16); got != 0x1234 {
+		t.Fatalf("READ word 5 got %04X want 1234", got)
+	}
+	if got := eepromSend(m, 0, 16); got != 0xBEEF {
+		t.Fatalf("sequential READ word 6 got %04X want BEEF", got)
+	}
+	eepromDeselect(m)
+
+	sav := m.SaveRAM()
+	if len(sav) != 256 || sav[10] != 0x34 || sav[11] != 0x12 {
+		t.Fatalf("SaveRAM len=%d [10]=%02X [11]=%02X", len(sav), sav[10], sav[11])
+	}
+	n := newEnabledMBC7()
+	n.LoadRAM(sav)
+	eepromSend(n, 0b1_10_00000110, 11)
+	if got := eepromSend(n, 0, 16); got != 0xBEEF {
+		t.Fatalf("READ after LoadRAM got %04X want BEEF", got)
+	}
+	o := NewMBC7(make([]byte, 0x8000))
+	o.LoadState(m.SaveState())
+	if o.eeprom.words[5] != 0x1234 || !o.eeprom.writeEnabled || !o.ramEnable2 {
+		t.Fatalf("LoadState word5=%04X ewen=%v en2=%v", o.eeprom.words[5], o.eeprom.writeEnabled, o.ramEnable2)
+	}
+}
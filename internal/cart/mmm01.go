@@ -0,0 +1,238 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// MMM01 implements the MMM01 multicart mapper. It powers up "unmapped" with the last 32 KiB of
+// the ROM (the menu) at 0000-7FFF. The menu programs the outer bank bits and masks, then sets the
+// map-enable bit; from then on the selected game sees an MBC1-like mapper confined to its slice.
+// - 0000-1FFF: bits 0-3 RAM enable (0x0A); unmapped only: bits 4-5 RAM bank mask, bit 6 map enable
+// - 2000-3FFF: bits 0-4 ROM bank low; unmapped only: bits 5-6 ROM bank mid
+// - 4000-5FFF: bits 0-1 RAM bank low; unmapped only: bits 2-3 RAM bank high, bits 4-5 ROM bank high, bit 6 lock MBC1 mode
+// - 6000-7FFF: bit 0 MBC1 mode; unmapped only: bits 2-5 ROM bank mask (fixes bank bits 1-4), bit 6 multiplex
+// With multiplex set, ROM bank mid and RAM bank low swap registers.
+type MMM01 struct {
+	rom []byte
+	ram []byte
+
+	mapped     bool
+	ramEnabled bool
+
+	romLow  byte // 5 bits
+	romMid  byte // 2 bits
+	romHigh byte // 2 bits
+	ramLow  byte // 2 bits
+	ramHigh byte // 2 bits
+
+	romMask    byte // 4 bits: bank bits 1-4 frozen once mapped
+	ramMask    byte // 2 bits: RAM bank low bits frozen once mapped
+	mode       byte // MBC1 banking mode
+	modeLocked bool
+	multiplex  bool
+}
+
+func NewMMM01(rom []byte, ramSize int) *MMM01 {
+	m := &MMM01{rom: rom}
+	if ramSize > 0 {
+		m.ram = make([]byte, ramSize)
+	}
+	return m
+}
+
+// IsMMM01 reports whether the menu header in the last 32 KiB of the ROM declares an MMM01 cart.
+// Dumps keep the menu at the end, so the header at 0x0100 belongs to the first game. The trailing
+// header must carry the Nintendo logo and a valid checksum, so ordinary code that happens to hold
+// 0x0B-0x0D at that offset is not mistaken for a menu.
+func IsMMM01(rom []byte) (ramSize int, ok bool) {
+	if len(rom) < 0x10000 {
+		return 0, false
+	}
+	menu := rom[len(rom)-0x8000:]
+	if !hasNintendoLogo(menu) || !HeaderChecksumOK(menu) {
+		return 0, false
+	}
+	h, err := ParseHeader(menu)
+	if err != nil || h.CartType < 0x0B || h.CartType > 0x0D {
+		return 0, false
+	}
+	return h.RAMSizeBytes, true
+}
+
+// menuLast moves a menu found in the first 32 KiB to the end, the layout MMM01 expects
+// (the mapper powers up showing the last 32 KiB).
+func menuLast(rom []byte) []byte {
+	if len(rom) <= 0x8000 {
+		return rom
+	}
+	out := make([]byte, 0, len(rom))
+	out = append(out, rom[0x8000:]...)
+	return append(out, rom[:0x8000]...)
+}
+
+// romWritable returns the ROM bank low bits the game may still change.
+func (m *MMM01) romWritable() byte {
+	if !m.mapped {
+		return 0x1F
+	}
+	return 0x1F &^ (m.romMask << 1)
+}
+
+func (m *MMM01) ramWritable() byte {
+	if !m.mapped {
+		return 0x03
+	}
+	return 0x03 &^ m.ramMask
+}
+
+// romBank returns the 9-bit bank for 0000-3FFF (upper=false) or 4000-7FFF (upper=true).
+func (m *MMM01) romBank(upper bool) int {
+	if !m.mapped {
+		// Unmapped: all bank lines high, so the last 32 KiB shows up
+		if upper {
+			return 0x1FF
+		}
+		return 0x1FE
+	}
+	mid, low := m.romMid, m.romLow
+	if m.multiplex {
+		mid = m.ramLow
+	}
+	if !upper {
+		return int(m.romHigh)<<7 | int(mid)<<5 | int(low&^m.romWritable())
+	}
+	if low&m.romWritable() == 0 {
+		low |= 0x01
+	}
+	return int(m.romHigh)<<7 | int(mid)<<5 | int(low)
+}
+
+func (m *MMM01) ramBank() int {
+	low := m.ramLow
+	if m.multiplex {
+		low = m.romMid
+	}
+	if m.mode == 0 {
+		low &^= m.ramWritable()
+	}
+	return int(m.ramHigh)<<2 | int(low)
+}
+
+func (m *MMM01) readROM(bank int, off uint16) byte {
+	if len(m.rom) == 0 {
+		return 0xFF
+	}
+	return m.rom[(bank*0x4000+int(off))%len(m.rom)]
+}
+
+// ROMBank reports the bank mapped at 4000-7FFF.
+func (m *MMM01) ROMBank() int { return mirrorBank(m.rom, m.romBank(true)) }
+
+func (m *MMM01) Read(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		return m.readROM(m.romBank(false), addr)
+	case addr < 0x8000:
+		return m.readROM(m.romBank(true), addr-0x4000)
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled || len(m.ram) == 0 {
+			return 0xFF
+		}
+		return m.ram[(m.ramBank()*0x2000+int(addr-0xA000))%len(m.ram)]
+	default:
+		return 0xFF
+	}
+}
+
+func (m *MMM01) Write(addr uint16, value byte) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = (value & 0x0F) == 0x0A
+		if !m.mapped {
+			m.ramMask = (value >> 4) & 0x03
+			m.mapped = (value & 0x40) != 0
+		}
+	case addr < 0x4000:
+		w := m.romWritable()
+		m.romLow = m.romLow&^w | value&w
+		if !m.mapped {
+			m.romMid = (value >> 5) & 0x03
+		}
+	case addr < 0x6000:
+		w := m.ramWritable()
+		m.ramLow = m.ramLow&^w | value&w
+		if !m.mapped {
+			m.ramHigh = (value >> 2) & 0x03
+			m.romHigh = (value >> 4) & 0x03
+			m.modeLocked = (value & 0x40) != 0
+		}
+	case addr < 0x8000:
+		if !m.modeLocked {
+			m.mode = value & 0x01
+		}
+		if !m.mapped {
+			m.romMask = (value >> 2) & 0x0F
+			m.multiplex = (value & 0x40) != 0
+		}
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled || len(m.ram) == 0 {
+			return
+		}
+		m.ram[(m.ramBank()*0x2000+int(addr-0xA000))%len(m.ram)] = value
+	}
+}
+
+// BatteryBacked implementation
+func (m *MMM01) SaveRAM() []byte {
+	if len(m.ram) == 0 {
+		return nil
+	}
+	out := make([]byte, len(m.ram))
+	copy(out, m.ram)
+	return out
+}
+
+func (m *MMM01) LoadRAM(data []byte) {
+	if len(m.ram) == 0 || len(data) == 0 {
+		return
+	}
+	copy(m.ram, data)
+}
+
+// SaveState/LoadState for save states
+type mmm01State struct {
+	RAM                     []byte
+	Mapped, RamEnabled      bool
+	RomLow, RomMid, RomHigh byte
+	RamLow, RamHigh         byte
+	RomMask, RamMask, Mode  byte
+	ModeLocked, Multiplex   bool
+}
+
+func (m *MMM01) SaveState() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	s := mmm01State{
+		RAM:    append([]byte(nil), m.ram...),
+		Mapped: m.mapped, RamEnabled: m.ramEnabled,
+		RomLow: m.romLow, RomMid: m.romMid, RomHigh: m.romHigh, RamLow: m.ramLow, RamHigh: m.ramHigh,
+		RomMask: m.romMask, RamMask: m.ramMask, Mode: m.mode, ModeLocked: m.modeLocked, Multiplex: m.multiplex,
+	}
+	_ = enc.Encode(s)
+	return buf.Bytes()
+}
+
+func (m *MMM01) LoadState(data []byte) {
+	var s mmm01State
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&s); err != nil {
+		return
+	}
+	if len(m.ram) > 0 && len(s.RAM) > 0 {
+		copy(m.ram, s.RAM)
+	}
+	m.mapped, m.ramEnabled = s.Mapped, s.RamEnabled
+	m.romLow, m.romMid, m.romHigh, m.ramLow, m.ramHigh = s.RomLow, s.RomMid, s.RomHigh, s.RamLow, s.RamHigh
+	m.romMask, m.ramMask, m.mode, m.modeLocked, m.multiplex = s.RomMask, s.RamMask, s.Mode, s.ModeLocked, s.Multiplex
+}
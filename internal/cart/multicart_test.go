@@ -0,0 +1,141 @@
+package cart
+
+import "testing"
+
+// multicartROM builds a 1 MiB image with the bank number at the start of every bank and
+// a Nintendo logo at each 256 KiB game boundary.
+func multicartROM() []byte {
+	rom := make([]byte, 1024*1024)
+	for bank := 0; bank < 64; bank++ {
+		rom[bank*0x4000] = byte(bank)
+	}
+	for base := 0; base < len(rom); base += 0x40000 {
+		copy(rom[base+0x0104:], nintendoLogo[:])
+	}
+	rom[0x0147] = 0x01
+	rom[0x0148] = 0x05
+	return rom
+}
+
+func TestMBC1M_DetectionAndBanking(t *testing.T) {
+	rom := multicartROM()
+	if !IsMBC1Multicart(rom) {
+		t.Fatalf("multicart not detected")
+	}
+	plain := make([]byte, 1024*1024)
+	copy(plain[0x0104:], nintendoLogo[:])
+	if IsMBC1Multicart(plain) {
+		t.Fatalf("plain 1 MiB MBC1 detected as multicart")
+	}
+	c := NewCartridge(rom)
+	m, ok := c.(*MBC1)
+	if !ok || !m.multicart {
+		t.Fatalf("NewCartridge returned %T, want MBC1M", c)
+	}
+	// High bits select the 256 KiB game (shift 4), bit 4 of the low register is ignored
+	m.Write(0x4000, 0x02)
+	m.Write(0x2000, 0x03)
+	if got := m.Read(0x4000); got != 0x23 {
+		t.Fatalf("game 2 bank 3 got %02X want 23", got)
+	}
+	m.Write(0x2000, 0x10)
+	if got := m.Read(0x4000); got != 0x20 {
+		t.Fatalf("low 0x10 got %02X want 20 (game's bank 0)", got)
+	}
+	// Mode 1 maps the game's first bank at 0000
+	m.Write(0x6000, 0x01)
+	if got := m.Read(0x0000); got != 0x20 {
+		t.Fatalf("mode1 bank0 area got %02X want 20", got)
+	}
+}
+
+func TestMMM01_UnmappedMenuThenMappedGame(t *testing.T) {
+	// 512 KiB: games in the first banks, menu (with the MMM01 header) in the last 32 KiB
+	rom := make([]byte, 512*1024)
+	for bank := 0; bank < 32; bank++ {
+		rom[bank*0x4000] = byte(bank)
+	}
+	menu := len(rom) - 0x8000
+	writeMMM01Header(rom[menu:], 0x0B)
+	if _, ok := IsMMM01(rom); !ok {
+		t.Fatalf("MMM01 menu header not detected")
+	}
+	c := NewCartridge(rom)
+	m, ok := c.(*MMM01)
+	if !ok {
+		t.Fatalf("NewCartridge returned %T, want MMM01", c)
+	}
+	if got := m.Read(0x0000); got != 30 {
+		t.Fatalf("unmapped bank0 area got %02X want 1E", got)
+	}
+	if got := m.Read(0x4000); got != 31 {
+		t.Fatalf("unmapped upper area got %02X want 1F", got)
+	}
+	// Menu selects a 128 KiB game at bank 8: base bank 8 via low bits, freeze bits 3-4 (mask 0b1100)
+	m.Write(0x2000, 0x08)
+	m.Write(0x6000, 0x0C<<2)
+	m.Write(0x0000, 0x40) // map
+	if got := m.Read(0x0000); got != 8 {
+		t.Fatalf("mapped bank0 area got %02X want 08", got)
+	}
+	if got := m.Read(0x4000); got != 9 {
+		t.Fatalf("mapped default bank got %02X want 09", got)
+	}
+	// The game can only change bits 0-2; bit 4 stays frozen
+	m.Write(0x2000, 0x13)
+	if got := m.Read(0x4000); got != 0x0B {
+		t.Fatalf("mapped bank 3 got %02X want 0B", got)
+	}
+	// Unmapped-only registers are ignored once mapped
+	m.Write(0x0000, 0x00)
+	if !m.mapped {
+		t.Fatalf("map enable cleared after mapping")
+	}
+}
+
+// writeMMM01Header puts a logo, cart type and valid header checksum into a 32 KiB menu image.
+func writeMMM01Header(menu []byte, cartType byte) {
+	copy(menu[0x0104:], nintendoLogo[:])
+	menu[0x0147] = cartType
+	var sum byte
+	for addr := 0x0134; addr <= 0x014C; addr++ {
+		sum = sum - menu[addr] - 1
+	}
+	menu[0x014D] = sum
+}
+
+func TestMMM01_DetectionNeedsValidMenuHeader(t *testing.T) {
+	// MBC5 game whose last bank happens to hold 0x0C at the header's cart type offset
+	rom := make([]byte, 512*1024)
+	copy(rom[0x0104:], nintendoLogo[:])
+	rom[0x0147] = 0x19
+	rom[len(rom)-0x8000+0x0147] = 0x0C
+	if _, ok := IsMMM01(rom); ok {
+		t.Fatalf("stray 0x0C byte detected as MMM01")
+	}
+	c := NewCartridge(rom)
+	if _, ok := c.(*MBC5); !ok {
+		t.Fatalf("NewCartridge returned %T, want MBC5", c)
+	}
+	// A real MMM01 header behind a known MBC type in bank 0 is ignored too
+	writeMMM01Header(rom[len(rom)-0x8000:], 0x0B)
+	if _, ok := NewCartridge(rom).(*MBC5); !ok {
+		t.Fatalf("MBC5 bank-0 type overridden by trailing MMM01 header")
+	}
+}
+
+func TestMMM01_MenuFirstDump(t *testing.T) {
+	rom := make([]byte, 512*1024)
+	for bank := 0; bank < 32; bank++ {
+		rom[bank*0x4000] = byte(bank)
+	}
+	writeMMM01Header(rom, 0x0B)
+	m, ok := NewCartridge(rom).(*MMM01)
+	if !ok {
+		t.Fatalf("menu-first MMM01 dump not detected")
+	}
+	// The menu (banks 0-1 of the dump) shows up unmapped
+	if got := m.Read(0x4000); got != 1 {
+		t.Fatalf("unmapped upper area got %02X want 01", got)
+	}
+}
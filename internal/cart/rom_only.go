@@ -9,6 +9,9 @@ func NewROMOnly(rom []byte) *ROMOnly {
 	return &ROMOnly{rom: rom}
 }
 
+// ROMBank reports the bank at 4000-7FFF, which is always bank 1 without an MBC.
+func (c *ROMOnly) ROMBank() int { return 1 }
+
 func (c *ROMOnly) Read(addr uint16) byte {
 	switch {
 	case addr < 0x8000: // ROM fixed area
@@ -28,9 +28,35 @@ type CPU struct {
 	haltDupActive bool
 	haltDup       byte
 
-	bus *bus.Bus
+	// ticked counts the cycles of the current Step already passed to the bus by memory
+	// accesses and internal cycles; Step ticks the rest when the instruction ends.
+	ticked int
+
+	bus    *bus.Bus
+	hook   Hook
+	tracer Tracer
+}
+
+// Hook is consulted before every instruction fetch, after interrupts and HALT have been
+// handled, so PC is the address of the instruction about to run. Returning false holds
+// the CPU: Step returns 0 cycles without executing anything. A Hook that also has a
+// BeginStep(c *CPU) method sees every Step first, before any memory access.
+type Hook interface {
+	BeforeStep(c *CPU) bool
 }
 
+// SetHook installs a Hook (e.g. a debugger); nil removes it.
+func (c *CPU) SetHook(h Hook) { c.hook = h }
+
+// Tracer observes every instruction right before it executes, after the Hook let it run.
+// Interrupt dispatch and HALT cycles are not instructions and are not traced.
+type Tracer interface {
+	Trace(c *CPU)
+}
+
+// SetTracer installs a Tracer (e.g. a trace log writer); nil removes it.
+func (c *CPU) SetTracer(t Tracer) { c.tracer = t }
+
 // New creates a CPU with default post-boot-like state (simplified).
 func New(b *bus.Bus) *CPU {
 	return &CPU{bus: b, SP: 0xFFFE, PC: 0x0000}
@@ -160,8 +186,31 @@ func (c *CPU) cp8(a, b byte) (z, n, h, cy bool) {
 	return
 }
 
-func (c *CPU) read8(addr uint16) byte     { return c.bus.Read(addr) }
-func (c *CPU) write8(addr uint16, v byte) { c.bus.Write(addr, v) }
+// tick advances the rest of the machine by one M-cycle (4 CPU cycles) in the middle of
+// an instruction, so timers, PPU and DMA see each access at the right time.
+func (c *CPU) tick() {
+	if c.bus == nil {
+		return
+	}
+	c.bus.SetCPUHalted(false)
+	c.bus.Tick(4)
+	c.ticked += 4
+}
+
+// read8 and write8 are the CPU's memory cycles: each takes one M-cycle and accesses
+// memory at its end.
+func (c *CPU) read8(addr uint16) byte {
+	c.tick()
+	return c.bus.Read(addr)
+}
+
+func (c *CPU) write8(addr uint16, v byte) {
+	c.tick()
+	c.bus.Write(addr, v)
+}
+
+// idle is an internal M-cycle without a memory access, e.g. before a push.
+func (c *CPU) idle() { c.tick() }
 
 func (c *CPU) fetch8() byte {
 	if c.haltDupActive {
@@ -169,9 +218,11 @@ func (c *CPU) fetch8() byte {
 		b := c.haltDup
 		c.haltDupActive = false
 		c.PC++
+		c.tick() // the re-read byte still costs a memory cycle
 		return b
 	}
-	b := c.read8(c.PC)
+	c.tick()
+	b := c.bus.Fetch(c.PC)
 	c.PC++
 	if c.haltBug {
 		// Schedule duplication of this byte on the next fetch
@@ -208,9 +259,13 @@ func (c *CPU) setDE(v uint16) { c.D = byte(v >> 8); c.E = byte(v) }
 func (c *CPU) getHL() uint16  { return uint16(c.H)<<8 | uint16(c.L) }
 func (c *CPU) setHL(v uint16) { c.H = byte(v >> 8); c.L = byte(v) }
 
+// push16 writes the high byte first, as the hardware does. Callers spend the internal
+// cycle that precedes it (idle) themselves, since interrupt dispatch spends two.
 func (c *CPU) push16(v uint16) {
-	c.SP -= 2
-	c.write16(c.SP, v)
+	c.SP--
+	c.write8(c.SP, byte(v>>8))
+	c.SP--
+	c.write8(c.SP, byte(v))
 }
 
 func (c *CPU) pop16() uint16 {
@@ -219,77 +274,95 @@ func (c *CPU) pop16() uint16 {
 	return v
 }
 
-// Step executes one instruction and returns an approximate cycle count for the implemented subset.
+// pendingInterrupts returns the requested interrupts that are also enabled.
+func (c *CPU) pendingInterrupts() byte {
+	return c.bus.Peek(0xFFFF) & c.bus.Peek(0xFF0F) & 0x1F
+}
+
+// dispatchInterrupt jumps to the highest-priority pending interrupt in 5 M-cycles: two
+// internal cycles, the PC pushes and the jump. The handler is chosen only after the
+// high byte of PC is pushed, so a push that lands on IE (SP=0000) can change it; when
+// nothing is pending any more the interrupt is cancelled and execution goes to $0000.
+func (c *CPU) dispatchInterrupt() int {
+	c.IME = false
+	c.eiPending = false
+	c.idle()
+	c.idle()
+	c.SP--
+	c.write8(c.SP, byte(c.PC>>8))
+	pending := c.pendingInterrupts()
+	c.SP--
+	c.write8(c.SP, byte(c.PC))
+	c.PC = 0x0000
+	// priority order VBlank(0), LCD STAT(1), Timer(2), Serial(3), Joypad(4)
+	for bit := uint(0); bit < 5; bit++ {
+		if pending&(1<<bit) != 0 {
+			// acknowledge: clear IF bit
+			c.bus.Poke(0xFF0F, c.bus.Peek(0xFF0F)&^(1<<bit)&0x1F)
+			c.PC = 0x40 + uint16(bit)*8
+			break
+		}
+	}
+	return 20
+}
+
+// Step executes one instruction and returns its cycle count. Memory accesses tick the
+// bus as they happen; internal cycles not spent yet are ticked when the step ends.
 func (c *CPU) Step() (cycles int) {
-	// Advance timers on return with the cycles consumed in this step
+	c.ticked = 0
 	defer func() {
-		if c.bus != nil && cycles > 0 {
-			c.bus.Tick(cycles)
+		if cycles < c.ticked {
+			cycles = c.ticked // never report less time than the accesses took
+		}
+		if c.bus != nil && cycles > c.ticked {
+			c.bus.SetCPUHalted(c.halted)
+			c.bus.Tick(cycles - c.ticked)
 		}
 	}()
-
-	// Apply EI delayed enable from the previous instruction
-	if c.eiPending {
-		c.IME = true
-		c.eiPending = false
+	if h, ok := c.hook.(interface{ BeginStep(c *CPU) }); ok {
+		h.BeginStep(c)
 	}
 
-	// Interrupt servicing helper
-	serviceInterrupt := func() int {
-		ie := c.bus.Read(0xFFFF)
-		ifReg := c.bus.Read(0xFF0F) & 0x1F
-		pending := ie & ifReg
-		if pending == 0 {
-			return 0
+	// A CGB VRAM DMA (GDMA or an HBlank block) halts the CPU while it owns the bus
+	if c.bus != nil {
+		if s := c.bus.TakeStall(); s > 0 {
+			return s
 		}
-		// priority order VBlank(0), LCD STAT(1), Timer(2), Serial(3), Joypad(4)
-		var bit uint
-		for bit = 0; bit < 5; bit++ {
-			if (pending & (1 << bit)) != 0 {
-				break
-			}
-		}
-		// acknowledge: clear IF bit
-		c.bus.Write(0xFF0F, (ifReg&^(1<<bit))&0x1F)
-		// push PC and jump
-		c.halted = false
-		c.IME = false
-		c.push16(c.PC)
-		c.PC = 0x40 + uint16(bit)*8
-		return 20
 	}
 
-	// HALT behavior: if IME and an interrupt is pending, service it; else sleep
+	// HALT: sleep until an enabled interrupt is requested, whatever IME says. Waking
+	// up takes one M-cycle before the interrupt is dispatched or execution resumes.
 	if c.halted {
-		if c.IME {
-			if cyc := serviceInterrupt(); cyc != 0 {
-				return cyc
-			}
-			// Remain halted until an interrupt occurs
-			return 4
-		} else {
-			// pending interrupt without IME -> remain running
-			ifReg := c.bus.Read(0xFF0F) & 0x1F
-			ie := c.bus.Read(0xFFFF)
-			if (ifReg & ie) != 0 {
-				c.halted = false
-			} else {
-				return 4
-			}
-		}
+		c.halted = c.pendingInterrupts() == 0
+		return 4
 	}
 
-	// If IME and an interrupt is pending, service before executing opcode
-	if c.IME {
-		if cyc := serviceInterrupt(); cyc != 0 {
-			return cyc
-		}
+	// Interrupts are taken between instructions. IME is still clear here for the
+	// instruction right after EI, so that one always runs first.
+	if c.IME && c.pendingInterrupts() != 0 {
+		return c.dispatchInterrupt()
+	}
+
+	if c.hook != nil && !c.hook.BeforeStep(c) {
+		return 0
+	}
+	// EI takes effect after the following instruction, which may still be a DI
+	if c.eiPending {
+		c.IME = true
+		c.eiPending = false
+	}
+	if c.tracer != nil {
+		c.tracer.Trace(c)
 	}
 
 	op := c.fetch8()
 	switch op {
 	case 0x10: // STOP (DMG: 2-byte instruction; second byte is padding). Simplify behavior.
-		_ = c.fetch8() // consume padding byte (usually 0x00)
+		c.PC++ // skip the padding byte (usually 0x00); it is never read, so it costs no cycle
+		// CGB: STOP with KEY1 armed performs the speed switch
+		if n := c.bus.SpeedSwitch(); n > 0 {
+			return n
+		}
 		return 4
 	case 0x00: // NOP
 		return 4
@@ -939,6 +1012,7 @@ func (c *CPU) Step() (cycles int) {
 	// CALL/RET
 	case 0xCD: // CALL a16
 		addr := c.fetch16()
+		c.idle()
 		c.push16(c.PC)
 		c.PC = addr
 		return 24
@@ -952,34 +1026,42 @@ func (c *CPU) Step() (cycles int) {
 
 	// RST t
 	case 0xC7:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x00
 		return 16
 	case 0xCF:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x08
 		return 16
 	case 0xD7:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x10
 		return 16
 	case 0xDF:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x18
 		return 16
 	case 0xE7:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x20
 		return 16
 	case 0xEF:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x28
 		return 16
 	case 0xF7:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x30
 		return 16
 	case 0xFF:
+		c.idle()
 		c.push16(c.PC)
 		c.PC = 0x38
 		return 16
@@ -988,6 +1070,7 @@ func (c *CPU) Step() (cycles int) {
 	case 0xC4: // NZ
 		addr := c.fetch16()
 		if (c.F & flagZ) == 0 {
+			c.idle()
 			c.push16(c.PC)
 			c.PC = addr
 			return 24
@@ -996,6 +1079,7 @@ func (c *CPU) Step() (cycles int) {
 	case 0xCC: // Z
 		addr := c.fetch16()
 		if (c.F & flagZ) != 0 {
+			c.idle()
 			c.push16(c.PC)
 			c.PC = addr
 			return 24
@@ -1004,6 +1088,7 @@ func (c *CPU) Step() (cycles int) {
 	case 0xD4: // NC
 		addr := c.fetch16()
 		if (c.F & flagC) == 0 {
+			c.idle()
 			c.push16(c.PC)
 			c.PC = addr
 			return 24
@@ -1012,6 +1097,7 @@ func (c *CPU) Step() (cycles int) {
 	case 0xDC: // C
 		addr := c.fetch16()
 		if (c.F & flagC) != 0 {
+			c.idle()
 			c.push16(c.PC)
 			c.PC = addr
 			return 24
@@ -1020,24 +1106,28 @@ func (c *CPU) Step() (cycles int) {
 
 	// RET cc
 	case 0xC0:
+		c.idle() // condition check
 		if (c.F & flagZ) == 0 {
 			c.PC = c.pop16()
 			return 20
 		}
 		return 8
 	case 0xC8:
+		c.idle() // condition check
 		if (c.F & flagZ) != 0 {
 			c.PC = c.pop16()
 			return 20
 		}
 		return 8
 	case 0xD0:
+		c.idle() // condition check
 		if (c.F & flagC) == 0 {
 			c.PC = c.pop16()
 			return 20
 		}
 		return 8
 	case 0xD8:
+		c.idle() // condition check
 		if (c.F & flagC) != 0 {
 			c.PC = c.pop16()
 			return 20
@@ -1299,15 +1389,19 @@ func (c *CPU) Step() (cycles int) {
 
 	// PUSH/POP
 	case 0xF5: // PUSH AF
+		c.idle()
 		c.push16(c.getAF())
 		return 16
 	case 0xC5: // PUSH BC
+		c.idle()
 		c.push16(c.getBC())
 		return 16
 	case 0xD5: // PUSH DE
+		c.idle()
 		c.push16(c.getDE())
 		return 16
 	case 0xE5: // PUSH HL
+		c.idle()
 		c.push16(c.getHL())
 		return 16
 	case 0xF1: // POP AF
@@ -1326,8 +1420,8 @@ func (c *CPU) Step() (cycles int) {
 	case 0x76: // HALT
 		// If interrupts are disabled but a request is pending and enabled, trigger HALT bug
 		if !c.IME {
-			ifReg := c.bus.Read(0xFF0F) & 0x1F
-			ie := c.bus.Read(0xFFFF)
+			ifReg := c.bus.Peek(0xFF0F) & 0x1F
+			ie := c.bus.Peek(0xFFFF)
 			if (ifReg & ie) != 0 {
 				c.haltBug = true
 				c.halted = false
@@ -226,7 +226,7 @@ func TestCPU_DAA_AddAndSub(t *testing.T) {
 }
 
 func TestCPU_EI_DelayedEnable(t *testing.T) {
-	// Program: EI; NOP; then an interrupt should be serviced on the NEXT instruction boundary
+	// Program: EI; NOP; the interrupt is serviced after the NOP, not before it
 	rom := make([]byte, 0x8000)
 	rom[0x0000] = 0xFB // EI
 	rom[0x0001] = 0x00 // NOP
@@ -241,7 +241,10 @@ func TestCPU_EI_DelayedEnable(t *testing.T) {
 	if c.IME {
 		t.Fatalf("IME should not be enabled immediately after EI")
 	}
-	// Step NOP: EI should take effect before executing NOP and interrupt serviced
+	// Step NOP: it still runs, and EI takes effect after it
+	if cyc := c.Step(); c.PC != 0x0002 || cyc != 4 || !c.IME {
+		t.Fatalf("NOP after EI: PC=%04X cyc=%d IME=%t", c.PC, cyc, c.IME)
+	}
 	cyc := c.Step()
 	if c.PC != 0x0040 || cyc != 20 {
 		t.Fatalf("interrupt not serviced after EI delay; PC=%04X cyc=%d", c.PC, cyc)
@@ -269,6 +272,26 @@ func TestCPU_STOP_ConsumesPadding(t *testing.T) {
 	}
 }
 
+func TestCPU_STOP_SpeedSwitch(t *testing.T) {
+	// Program: STOP 00; NOP with KEY1 armed on CGB -> switches to double speed
+	rom := make([]byte, 0x8000)
+	rom[0x0000] = 0x10 // STOP
+	rom[0x0001] = 0x00 // padding
+	b := bus.New(rom)
+	b.SetCGBMode(true)
+	b.Write(0xFF4D, 0x01)
+	c := New(b)
+	if cycles := c.Step(); cycles <= 4 {
+		t.Fatalf("speed switch STOP cycles got %d want >4", cycles)
+	}
+	if c.PC != 0x0002 {
+		t.Fatalf("PC after STOP got %04X want 0002", c.PC)
+	}
+	if !b.DoubleSpeed() || b.Read(0xFF4D) != 0xFE {
+		t.Fatalf("speed switch not applied: KEY1=%02X", b.Read(0xFF4D))
+	}
+}
+
 func TestCPU_HALT_Bug_DoubleFetch(t *testing.T) {
 	// Arrange a pending interrupt with IME=0, execute HALT, then ensure next opcode byte is double-read
 	rom := make([]byte, 0x8000)
@@ -716,3 +739,117 @@ func TestCPU_LD_r_from_HL_CyclesAndBehavior(t *testing.T) {
 		t.Fatalf("LD A,(HL) cyc=%d A=%02X", cyc, c.A)
 	}
 }
+
+// Memory accesses tick the bus as they happen, so a read late in an instruction sees
+// timer edges from earlier cycles of the same instruction.
+func TestCPU_MemoryReadTimedWithinInstruction(t *testing.T) {
+	run := func(nops int) byte {
+		code := make([]byte, 0, nops+2)
+		for i := 0; i < nops; i++ {
+			code = append(code, 0x00) // NOP
+		}
+		code = append(code, 0xF0, 0x05) // LDH A,(TIMA)
+		c := newCPUWithROM(code)
+		b := c.Bus()
+		b.Write(0xFF07, 0x05) // timer on, TIMA every 16 cycles
+		b.Write(0xFF04, 0x00) // DIV=0
+		b.Write(0xFF05, 0x00) // TIMA=0
+		for i := 0; i <= nops; i++ {
+			c.Step()
+		}
+		return c.A
+	}
+	// The read is the third M-cycle: at cycle 12 without NOPs, at cycle 16 after one
+	if got := run(0); got != 0 {
+		t.Fatalf("TIMA read at cycle 12 = %d, want 0", got)
+	}
+	if got := run(1); got != 1 {
+		t.Fatalf("TIMA read at cycle 16 = %d, want 1", got)
+	}
+}
+
+func TestCPU_PushWritesHighByteFirst(t *testing.T) {
+	c := newCPUWithROM([]byte{0x01, 0x34, 0x12, 0xC5}) // LD BC,$1234; PUSH BC
+	c.SP = 0xD000
+	var writes []uint16
+	c.Bus().SetWatchHook(func(addr uint16, v byte, write bool) {
+		if write {
+			writes = append(writes, addr)
+		}
+	})
+	c.Step()
+	if cyc := c.Step(); cyc != 16 {
+		t.Fatalf("PUSH cycles = %d, want 16", cyc)
+	}
+	if len(writes) != 2 || writes[0] != 0xCFFF || writes[1] != 0xCFFE {
+		t.Fatalf("push writes = %04X, want [CFFF CFFE]", writes)
+	}
+	if c.Bus().Read(0xCFFE) != 0x34 || c.Bus().Read(0xCFFF) != 0x12 {
+		t.Fatalf("pushed bytes wrong")
+	}
+}
+
+func TestCPU_EI_DI_AllowsNoInterrupt(t *testing.T) {
+	c := newCPUWithROM([]byte{0xFB, 0xF3, 0x00}) // EI; DI; NOP
+	b := c.Bus()
+	b.Write(0xFFFF, 0x01)
+	b.Write(0xFF0F, 0x01)
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	if c.PC != 0x0003 || c.IME {
+		t.Fatalf("EI;DI let an interrupt in: PC=%04X IME=%t", c.PC, c.IME)
+	}
+}
+
+// Pushing PC's high byte to IE (SP=0000) before the handler is chosen can cancel the
+// interrupt, which then jumps to $0000 (mooneye ie_push).
+func TestCPU_InterruptDispatch_IEPushCancels(t *testing.T) {
+	c := newCPUWithROM(nil)
+	b := c.Bus()
+	c.PC = 0x0200 // high byte 02 clears IE bit 0
+	c.SP = 0x0000
+	c.IME = true
+	b.Write(0xFFFF, 0x01)
+	b.Write(0xFF0F, 0x01)
+	if cyc := c.Step(); cyc != 20 {
+		t.Fatalf("dispatch cycles = %d, want 20", cyc)
+	}
+	if c.PC != 0x0000 || c.SP != 0xFFFE || c.IME {
+		t.Fatalf("cancelled dispatch: PC=%04X SP=%04X IME=%t", c.PC, c.SP, c.IME)
+	}
+	if b.Read(0xFF0F)&0x01 == 0 {
+		t.Fatalf("IF bit acknowledged although the interrupt was cancelled")
+	}
+
+	// Same push, but the new IE still enables a (lower-priority) timer interrupt
+	c = newCPUWithROM(nil)
+	b = c.Bus()
+	c.PC = 0x0400 // high byte 04 enables only the timer
+	c.SP = 0x0000
+	c.IME = true
+	b.Write(0xFFFF, 0x01)
+	b.Write(0xFF0F, 0x05)
+	c.Step()
+	if c.PC != 0x0050 || b.Read(0xFF0F)&0x1F != 0x01 {
+		t.Fatalf("redirected dispatch: PC=%04X IF=%02X", c.PC, b.Read(0xFF0F))
+	}
+}
+
+func TestCPU_HaltExitTakesOneCycle(t *testing.T) {
+	c := newCPUWithROM([]byte{0x76, 0x00}) // HALT; NOP
+	b := c.Bus()
+	c.IME = true
+	b.Write(0xFFFF, 0x04)
+	c.Step() // HALT
+	if cyc := c.Step(); cyc != 4 || c.PC != 0x0001 {
+		t.Fatalf("halted step: cyc=%d PC=%04X", cyc, c.PC)
+	}
+	b.Write(0xFF0F, 0x04)
+	if cyc := c.Step(); cyc != 4 || c.PC != 0x0001 {
+		t.Fatalf("wake-up step: cyc=%d PC=%04X, want 4 cycles before dispatch", cyc, c.PC)
+	}
+	if cyc := c.Step(); cyc != 20 || c.PC != 0x0050 {
+		t.Fatalf("dispatch after HALT: cyc=%d PC=%04X", cyc, c.PC)
+	}
+}
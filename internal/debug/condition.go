@@ -0,0 +1,177 @@
+package debug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+)
+
+// Compare tests one register against a value, e.g. A==$10 or HL>=0xC000.
+type Compare struct {
+	Reg   string // A F B C D E H L AF BC DE HL SP PC
+	Op    string // == != < <= > >=
+	Value uint16
+}
+
+// Condition is a conjunction of comparisons; an empty Condition always holds.
+type Condition []Compare
+
+// ParseCondition parses comparisons joined by "&&", e.g. "A==$10 && HL!=0".
+// Values are decimal, or hex with a "$" or "0x" prefix. An empty string yields nil.
+func ParseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var cond Condition
+	for _, part := range strings.Split(s, "&&") {
+		cmp, err := parseCompare(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		cond = append(cond, cmp)
+	}
+	return cond, nil
+}
+
+// Operators in match order: two-character forms first.
+var compareOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseCompare(s string) (Compare, error) {
+	for _, op := range compareOps {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+		reg := strings.ToUpper(strings.TrimSpace(s[:i]))
+		if !isRegister(reg) {
+			return Compare{}, fmt.Errorf("debug: unknown register %q in %q", reg, s)
+		}
+		v, err := ParseValue(s[i+len(op):])
+		if err != nil {
+			return Compare{}, err
+		}
+		return Compare{Reg: reg, Op: op, Value: v}, nil
+	}
+	return Compare{}, fmt.Errorf("debug: no comparison operator in %q", s)
+}
+
+// ParseValue parses a 16-bit number: decimal, or hex with a "$" or "0x" prefix.
+func ParseValue(s string) (uint16, error) {
+	s = strings.TrimSpace(s)
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "$"):
+		s, base = s[1:], 16
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		s, base = s[2:], 16
+	}
+	v, err := strconv.ParseUint(s, base, 16)
+	if err != nil {
+		return 0, fmt.Errorf("debug: bad value %q", s)
+	}
+	return uint16(v), nil
+}
+
+// ParseLocation parses "addr" or "bank:addr" (bank in hex, as in RGBDS symbol files)
+// and returns the address and bank, AnyBank when none is given.
+func ParseLocation(s string) (uint16, int, error) {
+	bank := AnyBank
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		b, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s[:i]), "$"), 16, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("debug: bad bank in %q", s)
+		}
+		bank = int(b)
+		s = s[i+1:]
+	}
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "$") && !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		s = "$" + s // addresses are hex by default
+	}
+	addr, err := ParseValue(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return addr, bank, nil
+}
+
+func isRegister(r string) bool {
+	switch r {
+	case "A", "F", "B", "C", "D", "E", "H", "L", "AF", "BC", "DE", "HL", "SP", "PC":
+		return true
+	}
+	return false
+}
+
+// register returns the current value of a register named as in Compare.Reg.
+func register(c *cpu.CPU, r string) uint16 {
+	pair := func(hi, lo byte) uint16 { return uint16(hi)<<8 | uint16(lo) }
+	switch r {
+	case "A":
+		return uint16(c.A)
+	case "F":
+		return uint16(c.F & 0xF0)
+	case "B":
+		return uint16(c.B)
+	case "C":
+		return uint16(c.C)
+	case "D":
+		return uint16(c.D)
+	case "E":
+		return uint16(c.E)
+	case "H":
+		return uint16(c.H)
+	case "L":
+		return uint16(c.L)
+	case "AF":
+		return pair(c.A, c.F&0xF0)
+	case "BC":
+		return pair(c.B, c.C)
+	case "DE":
+		return pair(c.D, c.E)
+	case "HL":
+		return pair(c.H, c.L)
+	case "SP":
+		return c.SP
+	case "PC":
+		return c.PC
+	}
+	return 0
+}
+
+// Eval reports whether every comparison holds for the CPU's registers.
+func (cond Condition) Eval(c *cpu.CPU) bool {
+	for _, cmp := range cond {
+		v := register(c, cmp.Reg)
+		var ok bool
+		switch cmp.Op {
+		case "==":
+			ok = v == cmp.Value
+		case "!=":
+			ok = v != cmp.Value
+		case "<":
+			ok = v < cmp.Value
+		case "<=":
+			ok = v <= cmp.Value
+		case ">":
+			ok = v > cmp.Value
+		case ">=":
+			ok = v >= cmp.Value
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (cond Condition) String() string {
+	parts := make([]string, len(cond))
+	for i, cmp := range cond {
+		parts[i] = fmt.Sprintf("%s%s$%X", cmp.Reg, cmp.Op, cmp.Value)
+	}
+	return strings.Join(parts, " && ")
+}
@@ -0,0 +1,443 @@
+// Package debug implements an interactive debugger on top of the CPU and bus hooks:
+// PC breakpoints (optionally qualified by ROM bank and a register condition),
+// read/write/execute watchpoints on address ranges, and step into/over/out.
+// A front end (UI or CLI) drives it through a Machine; while it is paused the CPU
+// executes nothing and Machine.StepFrame returns immediately.
+package debug
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/bus"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cart"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/disasm"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/symbols"
+)
+
+// AnyBank matches a breakpoint regardless of the mapped ROM bank.
+const AnyBank = -1
+
+// Breakpoint stops execution before the instruction at Addr runs.
+// - Bank qualifies addresses in 0000-7FFF: 0 for the fixed bank, the mapped bank for 4000-7FFF
+// - Cond, when non-empty, must hold as well
+type Breakpoint struct {
+	ID      int
+	Addr    uint16
+	Bank    int
+	Cond    Condition
+	Enabled bool
+	Hits    int
+}
+
+// WatchKind selects which accesses a Watchpoint reacts to; kinds can be combined.
+type WatchKind byte
+
+const (
+	WatchRead WatchKind = 1 << iota
+	WatchWrite
+	WatchExec
+)
+
+// Watchpoint stops execution on an access to Start..End (inclusive). Read and write
+// watchpoints stop after the accessing instruction; execute watchpoints before it.
+type Watchpoint struct {
+	ID         int
+	Start, End uint16
+	Kind       WatchKind
+	Enabled    bool
+	Hits       int
+}
+
+// StopReason says why the debugger paused.
+type StopReason int
+
+const (
+	StopNone StopReason = iota
+	StopPause
+	StopBreakpoint
+	StopWatchpoint
+	StopStep
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopPause:
+		return "pause"
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopWatchpoint:
+		return "watchpoint"
+	case StopStep:
+		return "step"
+	default:
+		return "none"
+	}
+}
+
+// Stop describes the most recent pause. PC is where execution resumes, except for read
+// and write watchpoints where it is the instruction that made the access. Access, Addr
+// and Value are set for watchpoints.
+type Stop struct {
+	Reason     StopReason
+	PC         uint16
+	Bank       int
+	Breakpoint *Breakpoint
+	Watchpoint *Watchpoint
+	Access     WatchKind
+	Addr       uint16
+	Value      byte
+}
+
+// runMode is what the debugger does while the CPU runs.
+type runMode int
+
+const (
+	modeRun runMode = iota
+	modeStepInto
+	modeStepOver
+	modeStepOut
+)
+
+// Debugger implements cpu.Hook. Use Attach to connect it to a CPU and bus.
+type Debugger struct {
+	cpu *cpu.CPU
+	bus *bus.Bus
+
+	breakpoints []*Breakpoint
+	watchpoints []*Watchpoint
+	nextID      int
+	syms        *symbols.Table // optional; lets Resolve accept labels
+
+	paused bool
+	stop   Stop
+	mode   runMode
+	// resumed skips the stop checks for the first instruction after a resume, so
+	// continuing from a breakpoint does not hit it again immediately. Read and write
+	// watchpoints stop between instructions that were not checked yet, so they clear
+	// stoppedAt to keep the checks.
+	resumed   bool
+	stoppedAt bool
+	ran       bool // an instruction has executed since the last resume
+	// step over: break at retAddr once SP is back at or above retSP
+	retAddr uint16
+	retSP   uint16
+	// step out: break after a return that lifts SP above outSP
+	outSP   uint16
+	lastRet bool
+	// instrPC is the address of the instruction in flight, reported by watchpoint stops
+	instrPC uint16
+
+	// OnStop, when set, is called each time the debugger pauses.
+	OnStop func(Stop)
+}
+
+// New returns a detached, running debugger.
+func New() *Debugger { return &Debugger{} }
+
+// Attach hooks the debugger into c and b, replacing any previous attachment.
+// Breakpoints and watchpoints are kept.
+func (d *Debugger) Attach(c *cpu.CPU, b *bus.Bus) {
+	d.Detach()
+	d.cpu, d.bus = c, b
+	if c != nil {
+		c.SetHook(d)
+	}
+	d.updateWatchHook()
+}
+
+// Detach removes the debugger's hooks.
+func (d *Debugger) Detach() {
+	if d.cpu != nil {
+		d.cpu.SetHook(nil)
+	}
+	if d.bus != nil {
+		d.bus.SetWatchHook(nil)
+	}
+	d.cpu, d.bus = nil, nil
+}
+
+// SetSymbols gives the debugger a symbol table for Resolve and Describe; nil clears it.
+func (d *Debugger) SetSymbols(t *symbols.Table) { d.syms = t }
+
+// Symbols returns the symbol table set with SetSymbols.
+func (d *Debugger) Symbols() *symbols.Table { return d.syms }
+
+// Resolve is ResolveLocation with the debugger's symbols.
+func (d *Debugger) Resolve(spec string) (uint16, int, error) { return ResolveLocation(d.syms, spec) }
+
+// ResolveLocation turns a location into an address and bank: a label ("Main.loop"), a
+// label with an offset ("Main+$10"), or anything ParseLocation accepts. t may be nil.
+func ResolveLocation(t *symbols.Table, spec string) (uint16, int, error) {
+	spec = strings.TrimSpace(spec)
+	name, off := spec, ""
+	if i := strings.IndexByte(spec, '+'); i > 0 {
+		name, off = strings.TrimSpace(spec[:i]), spec[i+1:]
+	}
+	if s, ok := t.Find(name); ok {
+		addr := s.Addr
+		if off != "" {
+			n, err := ParseValue(off)
+			if err != nil {
+				return 0, 0, err
+			}
+			addr += n
+		}
+		bank := s.Bank
+		if addr >= 0x8000 {
+			bank = AnyBank // RAM symbols: the bank is not a ROM bank
+		}
+		return addr, bank, nil
+	}
+	return ParseLocation(spec)
+}
+
+// Describe names an address for display, e.g. "Main+$3", using the ROM bank currently
+// mapped at 4000-7FFF.
+func (d *Debugger) Describe(addr uint16) string {
+	if d.syms == nil {
+		return fmt.Sprintf("$%04X", addr)
+	}
+	return d.syms.Describe(d.bank(addr), addr)
+}
+
+// AddBreakpoint adds an enabled breakpoint. bank is AnyBank or a ROM bank number;
+// cond may be nil.
+func (d *Debugger) AddBreakpoint(addr uint16, bank int, cond Condition) *Breakpoint {
+	d.nextID++
+	bp := &Breakpoint{ID: d.nextID, Addr: addr, Bank: bank, Cond: cond, Enabled: true}
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp
+}
+
+// AddWatchpoint adds an enabled watchpoint on start..end (inclusive).
+func (d *Debugger) AddWatchpoint(start, end uint16, kind WatchKind) *Watchpoint {
+	if end < start {
+		start, end = end, start
+	}
+	d.nextID++
+	wp := &Watchpoint{ID: d.nextID, Start: start, End: end, Kind: kind, Enabled: true}
+	d.watchpoints = append(d.watchpoints, wp)
+	d.updateWatchHook()
+	return wp
+}
+
+// Remove deletes the breakpoint or watchpoint with the given ID.
+func (d *Debugger) Remove(id int) bool {
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	for i, wp := range d.watchpoints {
+		if wp.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			d.updateWatchHook()
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns the breakpoints in the order they were added.
+func (d *Debugger) Breakpoints() []*Breakpoint { return d.breakpoints }
+
+// Watchpoints returns the watchpoints in the order they were added.
+func (d *Debugger) Watchpoints() []*Watchpoint { return d.watchpoints }
+
+// updateWatchHook installs the bus hook only while a read or write watchpoint exists,
+// keeping memory accesses free of overhead otherwise.
+func (d *Debugger) updateWatchHook() {
+	if d.bus == nil {
+		return
+	}
+	for _, wp := range d.watchpoints {
+		if wp.Kind&(WatchRead|WatchWrite) != 0 {
+			d.bus.SetWatchHook(d.onAccess)
+			return
+		}
+	}
+	d.bus.SetWatchHook(nil)
+}
+
+// Paused reports whether the CPU is being held.
+func (d *Debugger) Paused() bool { return d.paused }
+
+// LastStop describes the most recent pause.
+func (d *Debugger) LastStop() Stop { return d.stop }
+
+// Pause holds the CPU before its next instruction.
+func (d *Debugger) Pause() {
+	if d.paused {
+		return
+	}
+	d.halt(Stop{Reason: StopPause, PC: d.pc(), Bank: d.bank(d.pc())})
+}
+
+// Continue resumes free running until a breakpoint or watchpoint hits.
+func (d *Debugger) Continue() { d.resume(modeRun) }
+
+// StepInto runs one instruction, entering calls and interrupt handlers.
+func (d *Debugger) StepInto() { d.resume(modeStepInto) }
+
+// StepOver runs one instruction, treating CALL and RST as a single step.
+func (d *Debugger) StepOver() {
+	if d.cpu == nil || d.bus == nil {
+		return
+	}
+	switch in := disasm.Decode(d.bus.Peek, d.cpu.PC); in.Kind {
+	case disasm.Call, disasm.CondCall:
+		d.retAddr = in.Next()
+		d.retSP = d.cpu.SP
+		d.resume(modeStepOver)
+	default:
+		d.resume(modeStepInto)
+	}
+}
+
+// StepOut runs until the current function returns to its caller.
+func (d *Debugger) StepOut() {
+	if d.cpu == nil {
+		return
+	}
+	d.outSP = d.cpu.SP
+	d.lastRet = false
+	d.resume(modeStepOut)
+}
+
+func (d *Debugger) resume(mode runMode) {
+	d.mode = mode
+	d.paused = false
+	d.resumed = d.stoppedAt
+	d.ran = false
+}
+
+func (d *Debugger) halt(s Stop) {
+	d.paused = true
+	d.stoppedAt = true
+	d.mode = modeRun
+	d.stop = s
+	if d.OnStop != nil {
+		d.OnStop(s)
+	}
+}
+
+// BeforeStep implements cpu.Hook.
+func (d *Debugger) BeforeStep(c *cpu.CPU) bool {
+	if d.paused {
+		return false
+	}
+	pc := c.PC
+	if d.resumed {
+		// Execute the instruction we were paused at without re-checking it
+		d.resumed = false
+		d.begin(pc)
+		return true
+	}
+	switch d.mode {
+	case modeStepInto:
+		if !d.ran {
+			break
+		}
+		d.halt(Stop{Reason: StopStep, PC: pc, Bank: d.bank(pc)})
+		return false
+	case modeStepOver:
+		if pc == d.retAddr && c.SP >= d.retSP {
+			d.halt(Stop{Reason: StopStep, PC: pc, Bank: d.bank(pc)})
+			return false
+		}
+	case modeStepOut:
+		if d.lastRet && c.SP > d.outSP {
+			d.halt(Stop{Reason: StopStep, PC: pc, Bank: d.bank(pc)})
+			return false
+		}
+	}
+	for _, bp := range d.breakpoints {
+		if !bp.Enabled || bp.Addr != pc || !d.bankMatches(bp.Bank, pc) || !bp.Cond.Eval(c) {
+			continue
+		}
+		bp.Hits++
+		d.halt(Stop{Reason: StopBreakpoint, PC: pc, Bank: d.bank(pc), Breakpoint: bp})
+		return false
+	}
+	for _, wp := range d.watchpoints {
+		if !wp.Enabled || wp.Kind&WatchExec == 0 || pc < wp.Start || pc > wp.End {
+			continue
+		}
+		wp.Hits++
+		d.halt(Stop{Reason: StopWatchpoint, PC: pc, Bank: d.bank(pc), Watchpoint: wp, Access: WatchExec, Addr: pc})
+		return false
+	}
+	d.begin(pc)
+	return true
+}
+
+// BeginStep records PC before the CPU touches memory, so accesses made while
+// dispatching an interrupt report the address execution continues from.
+func (d *Debugger) BeginStep(c *cpu.CPU) { d.instrPC = c.PC }
+
+// begin records the instruction about to run and, for StepOut, whether it is a return.
+func (d *Debugger) begin(pc uint16) {
+	d.instrPC = pc
+	d.ran = true
+	if d.mode == modeStepOut {
+		k := disasm.Decode(d.bus.Peek, pc).Kind
+		d.lastRet = k == disasm.Return || k == disasm.CondReturn
+	}
+}
+
+// onAccess is the bus watch hook. The first matching access pauses the debugger;
+// the instruction in flight still completes.
+func (d *Debugger) onAccess(addr uint16, v byte, write bool) {
+	if d.paused {
+		return
+	}
+	kind := WatchRead
+	if write {
+		kind = WatchWrite
+	}
+	for _, wp := range d.watchpoints {
+		if !wp.Enabled || wp.Kind&kind == 0 || addr < wp.Start || addr > wp.End {
+			continue
+		}
+		wp.Hits++
+		pc := d.instrPC
+		d.halt(Stop{Reason: StopWatchpoint, PC: pc, Bank: d.bank(pc), Watchpoint: wp, Access: kind, Addr: addr, Value: v})
+		d.stoppedAt = false
+		return
+	}
+}
+
+func (d *Debugger) pc() uint16 {
+	if d.cpu == nil {
+		return 0
+	}
+	return d.cpu.PC
+}
+
+// bank returns the ROM bank that addr falls in, or AnyBank outside ROM.
+func (d *Debugger) bank(addr uint16) int {
+	switch {
+	case addr < 0x4000:
+		return 0
+	case addr < 0x8000:
+		if d.bus != nil {
+			if br, ok := d.bus.Cart().(cart.BankedROM); ok {
+				return br.ROMBank()
+			}
+		}
+		return 1
+	default:
+		return AnyBank
+	}
+}
+
+func (d *Debugger) bankMatches(want int, addr uint16) bool {
+	if want == AnyBank {
+		return true
+	}
+	have := d.bank(addr)
+	return have == AnyBank || have == want
+}
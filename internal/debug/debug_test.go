@@ -0,0 +1,212 @@
+package debug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/bus"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/symbols"
+)
+
+// newTestCPU loads a small program at 0x0100:
+//
+//	0100 LD A,$10
+//	0102 CALL $0110
+//	0105 LD ($C000),A
+//	0108 NOP
+//	0109 JR $0109
+//	0110 INC A
+//	0111 RET
+func newTestCPU(t *testing.T) (*cpu.CPU, *Debugger) {
+	t.Helper()
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{0x3E, 0x10, 0xCD, 0x10, 0x01, 0xEA, 0x00, 0xC0, 0x00, 0x18, 0xFE})
+	copy(rom[0x110:], []byte{0x3C, 0xC9})
+	b := bus.New(rom)
+	c := cpu.New(b)
+	c.SetPC(0x0100)
+	d := New()
+	d.Attach(c, b)
+	return c, d
+}
+
+// run steps the CPU until the debugger pauses, failing after a bounded number of steps.
+func run(t *testing.T, c *cpu.CPU, d *Debugger) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if d.Paused() {
+			return
+		}
+		c.Step()
+	}
+	t.Fatalf("debugger never paused (PC=%04X)", c.PC)
+}
+
+func TestBreakpoint_HitAndContinue(t *testing.T) {
+	c, d := newTestCPU(t)
+	bp := d.AddBreakpoint(0x0105, AnyBank, nil)
+	run(t, c, d)
+	if s := d.LastStop(); s.Reason != StopBreakpoint || s.Breakpoint != bp || c.PC != 0x0105 || c.A != 0x11 {
+		t.Fatalf("stop=%+v PC=%04X A=%02X", s, c.PC, c.A)
+	}
+	if n := c.Step(); n != 0 || c.PC != 0x0105 {
+		t.Fatalf("paused CPU ran: cycles=%d PC=%04X", n, c.PC)
+	}
+	d.Continue()
+	c.Step()
+	if c.PC != 0x0108 || d.Paused() {
+		t.Fatalf("continue: PC=%04X paused=%v", c.PC, d.Paused())
+	}
+	if bp.Hits != 1 {
+		t.Fatalf("hits got %d want 1", bp.Hits)
+	}
+}
+
+func TestBreakpoint_ConditionAndBank(t *testing.T) {
+	c, d := newTestCPU(t)
+	never, err := ParseCondition("A==$11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.AddBreakpoint(0x0110, AnyBank, never)
+	d.AddBreakpoint(0x0105, 1, nil) // 0105 is in bank 0
+	hit, _ := ParseCondition("A == 0x11 && SP<$FFFE")
+	want := d.AddBreakpoint(0x0111, 0, hit)
+	for i := 0; i < 10 && !d.Paused(); i++ {
+		c.Step()
+	}
+	if s := d.LastStop(); s.Breakpoint != want {
+		t.Fatalf("stopped at %+v, want breakpoint %d", s, want.ID)
+	}
+	// At 0110 A was still $10; INC A made the condition at 0111 true
+	if c.A != 0x11 {
+		t.Fatalf("A got %02X", c.A)
+	}
+}
+
+func TestWatchpoint_WriteAndExec(t *testing.T) {
+	c, d := newTestCPU(t)
+	wp := d.AddWatchpoint(0xC000, 0xC0FF, WatchWrite)
+	run(t, c, d)
+	s := d.LastStop()
+	if s.Reason != StopWatchpoint || s.Watchpoint != wp || s.Addr != 0xC000 || s.Value != 0x11 || s.Access != WatchWrite || s.PC != 0x0105 {
+		t.Fatalf("write stop %+v", s)
+	}
+	if c.PC != 0x0108 {
+		t.Fatalf("instruction did not complete: PC=%04X", c.PC)
+	}
+
+	c, d = newTestCPU(t)
+	d.AddWatchpoint(0x0110, 0x0111, WatchExec)
+	run(t, c, d)
+	if s := d.LastStop(); s.Reason != StopWatchpoint || c.PC != 0x0110 {
+		t.Fatalf("exec stop %+v PC=%04X", s, c.PC)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	c, d := newTestCPU(t)
+	d.AddBreakpoint(0x0102, AnyBank, nil)
+	run(t, c, d)
+
+	d.StepOver()
+	run(t, c, d)
+	if c.PC != 0x0105 || c.A != 0x11 || d.LastStop().Reason != StopStep {
+		t.Fatalf("step over: PC=%04X A=%02X stop=%+v", c.PC, c.A, d.LastStop())
+	}
+
+	c, d = newTestCPU(t)
+	d.AddBreakpoint(0x0102, AnyBank, nil)
+	run(t, c, d)
+	d.StepInto()
+	run(t, c, d)
+	if c.PC != 0x0110 {
+		t.Fatalf("step into: PC=%04X", c.PC)
+	}
+	d.StepOut()
+	run(t, c, d)
+	if c.PC != 0x0105 || c.SP != 0xFFFE {
+		t.Fatalf("step out: PC=%04X SP=%04X", c.PC, c.SP)
+	}
+}
+
+func TestResolve_Labels(t *testing.T) {
+	c, d := newTestCPU(t)
+	tab, err := symbols.ParseSym(strings.NewReader("00:0110 Increment\n00:C000 wValue\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetSymbols(tab)
+	addr, bank, err := d.Resolve("Increment+1")
+	if err != nil || addr != 0x0111 || bank != 0 {
+		t.Fatalf("Resolve got %04X bank %d err %v", addr, bank, err)
+	}
+	if addr, bank, _ := d.Resolve("wValue"); addr != 0xC000 || bank != AnyBank {
+		t.Fatalf("RAM label got %04X bank %d", addr, bank)
+	}
+	d.AddBreakpoint(addr, bank, nil)
+	run(t, c, d)
+	if got := d.Describe(c.PC); got != "Increment+$1" {
+		t.Fatalf("Describe got %q", got)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	addr, bank, err := ParseLocation("03:4A2F")
+	if err != nil || addr != 0x4A2F || bank != 3 {
+		t.Fatalf("got %04X bank %d err %v", addr, bank, err)
+	}
+	addr, bank, err = ParseLocation("$0150")
+	if err != nil || addr != 0x0150 || bank != AnyBank {
+		t.Fatalf("got %04X bank %d err %v", addr, bank, err)
+	}
+	if _, err := ParseCondition("Q==1"); err == nil {
+		t.Fatalf("unknown register accepted")
+	}
+}
+
+func TestWatchpoint_ReadIgnoresCPUInternals(t *testing.T) {
+	// Interrupt polling and acknowledging touch IE/IF but are not data reads
+	c, d := newTestCPU(t)
+	c.IME = true
+	c.Bus().Write(0xFFFF, 0x01)
+	c.Bus().Write(0xFF0F, 0x01)
+	wp := d.AddWatchpoint(0xFFFF, 0xFFFF, WatchRead)
+	d.AddWatchpoint(0xFF0F, 0xFF0F, WatchRead|WatchWrite)
+	c.Step() // dispatch to $0040
+	if d.Paused() || c.PC != 0x0040 {
+		t.Fatalf("paused=%v stop=%+v PC=%04X", d.Paused(), d.LastStop(), c.PC)
+	}
+	// A program read of IE stops, reporting the instruction that made it
+	c.Bus().Write(0xC000, 0xFA) // LD A,[$FFFF] at $C000
+	c.Bus().Write(0xC001, 0xFF)
+	c.Bus().Write(0xC002, 0xFF)
+	c.SetPC(0xC000)
+	c.Step()
+	if s := d.LastStop(); !d.Paused() || s.Watchpoint != wp || s.PC != 0xC000 || s.Addr != 0xFFFF {
+		t.Fatalf("stop %+v", s)
+	}
+
+	// Instruction fetches are execution: a read watchpoint on code never fires
+	c, d = newTestCPU(t)
+	d.AddWatchpoint(0x0100, 0x0111, WatchRead)
+	for i := 0; i < 20; i++ {
+		c.Step()
+	}
+	if d.Paused() {
+		t.Fatalf("read watchpoint fired on a fetch: %+v", d.LastStop())
+	}
+}
+
+func TestWatchpoint_InterruptPushReportsPC(t *testing.T) {
+	c, d := newTestCPU(t)
+	c.IME = true
+	c.Bus().Write(0xFFFF, 0x01)
+	c.Bus().Write(0xFF0F, 0x01)
+	d.AddWatchpoint(0xFFFC, 0xFFFD, WatchWrite)
+	c.Step()
+	if s := d.LastStop(); !d.Paused() || s.PC != 0x0100 {
+		t.Fatalf("stop %+v, want PC 0100", s)
+	}
+}
@@ -0,0 +1,216 @@
+// Package disasm decodes SM83 machine code into RGBDS syntax. It covers every base and
+// CB-prefixed opcode; the eleven unused opcodes decode as one-byte "db" directives.
+// Operands that are addresses can be replaced by labels from a symbol table.
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reader returns the byte at an address, e.g. bus.Peek or a ROM bank slice.
+type Reader func(addr uint16) byte
+
+// Labels resolves addresses to names; *symbols.Table implements it.
+type Labels interface {
+	Lookup(bank int, addr uint16) (string, bool)
+}
+
+// Kind classifies an instruction's effect on control flow.
+type Kind int
+
+const (
+	Normal     Kind = iota
+	Jump            // jp/jr to a fixed target
+	CondJump        // conditional jp/jr
+	JumpHL          // jp hl
+	Call            // call or rst
+	CondCall        // conditional call
+	Return          // ret or reti
+	CondReturn      // conditional ret
+	Invalid         // unused opcode
+)
+
+// Instruction is one decoded instruction.
+type Instruction struct {
+	Addr   uint16
+	Bytes  []byte
+	Kind   Kind
+	Target uint16 // address operand: jump/call destination or memory address
+	// HasTarget reports whether Target is meaningful
+	HasTarget bool
+
+	tmpl string // mnemonic with one {operand} placeholder
+	val  uint16 // raw operand value
+}
+
+// Len returns the instruction length in bytes.
+func (in Instruction) Len() int { return len(in.Bytes) }
+
+// Next returns the address after the instruction.
+func (in Instruction) Next() uint16 { return in.Addr + uint16(len(in.Bytes)) }
+
+// String renders the instruction with numeric operands.
+func (in Instruction) String() string { return in.Format(nil, AnyBank) }
+
+// AnyBank formats without knowing the mapped ROM bank.
+const AnyBank = -1
+
+// Format renders the instruction, naming address operands through labels when they
+// resolve. bank is the ROM bank the instruction lives in, used for targets in 4000-7FFF.
+func (in Instruction) Format(labels Labels, bank int) string {
+	i := strings.IndexByte(in.tmpl, '{')
+	if i < 0 {
+		return in.tmpl
+	}
+	j := strings.IndexByte(in.tmpl, '}')
+	name := func(addr uint16, fallback string) string {
+		if labels != nil {
+			b := bank
+			if addr < 0x4000 {
+				b = 0
+			}
+			if s, ok := labels.Lookup(b, addr); ok {
+				return s
+			}
+		}
+		return fallback
+	}
+	var op string
+	switch in.tmpl[i+1 : j] {
+	case "n8":
+		op = fmt.Sprintf("$%02X", in.val)
+	case "n16":
+		// Immediates are often addresses too; only replace exact symbol matches
+		op = name(in.val, fmt.Sprintf("$%04X", in.val))
+	case "a16", "a8", "r8":
+		op = name(in.Target, fmt.Sprintf("$%04X", in.Target))
+	case "e8":
+		op = fmt.Sprintf("%d", int8(in.val))
+	case "spe8":
+		if e := int8(in.val); e < 0 {
+			op = fmt.Sprintf(" - %d", -int(e))
+		} else {
+			op = fmt.Sprintf(" + %d", e)
+		}
+	case "db":
+		op = fmt.Sprintf("$%02X", in.val)
+	}
+	return in.tmpl[:i] + op + in.tmpl[j+1:]
+}
+
+// Decode reads one instruction at addr.
+func Decode(read Reader, addr uint16) Instruction {
+	op := read(addr)
+	in := Instruction{Addr: addr}
+	if op == 0xCB {
+		cb := read(addr + 1)
+		in.Bytes = []byte{op, cb}
+		in.tmpl = cbMnemonic(cb)
+		return in
+	}
+	e := base[op]
+	in.tmpl, in.Kind = e.text, e.kind
+	switch {
+	case e.kind == Invalid:
+		in.Bytes = []byte{op}
+		in.val = uint16(op)
+		return in
+	case strings.Contains(e.text, "{n16}"), strings.Contains(e.text, "{a16}"):
+		lo, hi := read(addr+1), read(addr+2)
+		in.Bytes = []byte{op, lo, hi}
+		in.val = uint16(hi)<<8 | uint16(lo)
+	case strings.Contains(e.text, "{"), op == 0x10:
+		n := read(addr + 1)
+		in.Bytes = []byte{op, n}
+		in.val = uint16(n)
+	default:
+		in.Bytes = []byte{op}
+	}
+	switch {
+	case strings.Contains(e.text, "{a16}"):
+		in.Target, in.HasTarget = in.val, true
+	case strings.Contains(e.text, "{a8}"):
+		in.Target, in.HasTarget = 0xFF00|in.val, true
+	case strings.Contains(e.text, "{r8}"):
+		in.Target, in.HasTarget = in.Next()+uint16(int8(in.val)), true
+	case e.kind == Call: // rst
+		in.Target, in.HasTarget = uint16(op&0x38), true
+	}
+	return in
+}
+
+type entry struct {
+	text string
+	kind Kind
+}
+
+var regs8 = [8]string{"b", "c", "d", "e", "h", "l", "[hl]", "a"}
+
+// base holds the unprefixed opcodes; 40-BF and the unused opcodes are filled in by
+// init. CB is decoded separately.
+var base = [256]entry{
+	0x00: {"nop", Normal}, 0x01: {"ld bc, {n16}", Normal}, 0x02: {"ld [bc], a", Normal}, 0x03: {"inc bc", Normal},
+	0x04: {"inc b", Normal}, 0x05: {"dec b", Normal}, 0x06: {"ld b, {n8}", Normal}, 0x07: {"rlca", Normal},
+	0x08: {"ld [{a16}], sp", Normal}, 0x09: {"add hl, bc", Normal}, 0x0A: {"ld a, [bc]", Normal}, 0x0B: {"dec bc", Normal},
+	0x0C: {"inc c", Normal}, 0x0D: {"dec c", Normal}, 0x0E: {"ld c, {n8}", Normal}, 0x0F: {"rrca", Normal},
+	0x10: {"stop", Normal}, 0x11: {"ld de, {n16}", Normal}, 0x12: {"ld [de], a", Normal}, 0x13: {"inc de", Normal},
+	0x14: {"inc d", Normal}, 0x15: {"dec d", Normal}, 0x16: {"ld d, {n8}", Normal}, 0x17: {"rla", Normal},
+	0x18: {"jr {r8}", Jump}, 0x19: {"add hl, de", Normal}, 0x1A: {"ld a, [de]", Normal}, 0x1B: {"dec de", Normal},
+	0x1C: {"inc e", Normal}, 0x1D: {"dec e", Normal}, 0x1E: {"ld e, {n8}", Normal}, 0x1F: {"rra", Normal},
+	0x20: {"jr nz, {r8}", CondJump}, 0x21: {"ld hl, {n16}", Normal}, 0x22: {"ld [hl+], a", Normal}, 0x23: {"inc hl", Normal},
+	0x24: {"inc h", Normal}, 0x25: {"dec h", Normal}, 0x26: {"ld h, {n8}", Normal}, 0x27: {"daa", Normal},
+	0x28: {"jr z, {r8}", CondJump}, 0x29: {"add hl, hl", Normal}, 0x2A: {"ld a, [hl+]", Normal}, 0x2B: {"dec hl", Normal},
+	0x2C: {"inc l", Normal}, 0x2D: {"dec l", Normal}, 0x2E: {"ld l, {n8}", Normal}, 0x2F: {"cpl", Normal},
+	0x30: {"jr nc, {r8}", CondJump}, 0x31: {"ld sp, {n16}", Normal}, 0x32: {"ld [hl-], a", Normal}, 0x33: {"inc sp", Normal},
+	0x34: {"inc [hl]", Normal}, 0x35: {"dec [hl]", Normal}, 0x36: {"ld [hl], {n8}", Normal}, 0x37: {"scf", Normal},
+	0x38: {"jr c, {r8}", CondJump}, 0x39: {"add hl, sp", Normal}, 0x3A: {"ld a, [hl-]", Normal}, 0x3B: {"dec sp", Normal},
+	0x3C: {"inc a", Normal}, 0x3D: {"dec a", Normal}, 0x3E: {"ld a, {n8}", Normal}, 0x3F: {"ccf", Normal},
+
+	0xC0: {"ret nz", CondReturn}, 0xC1: {"pop bc", Normal}, 0xC2: {"jp nz, {a16}", CondJump}, 0xC3: {"jp {a16}", Jump},
+	0xC4: {"call nz, {a16}", CondCall}, 0xC5: {"push bc", Normal}, 0xC6: {"add a, {n8}", Normal}, 0xC7: {"rst $00", Call},
+	0xC8: {"ret z", CondReturn}, 0xC9: {"ret", Return}, 0xCA: {"jp z, {a16}", CondJump},
+	0xCC: {"call z, {a16}", CondCall}, 0xCD: {"call {a16}", Call}, 0xCE: {"adc a, {n8}", Normal}, 0xCF: {"rst $08", Call},
+	0xD0: {"ret nc", CondReturn}, 0xD1: {"pop de", Normal}, 0xD2: {"jp nc, {a16}", CondJump},
+	0xD4: {"call nc, {a16}", CondCall}, 0xD5: {"push de", Normal}, 0xD6: {"sub {n8}", Normal}, 0xD7: {"rst $10", Call},
+	0xD8: {"ret c", CondReturn}, 0xD9: {"reti", Return}, 0xDA: {"jp c, {a16}", CondJump},
+	0xDC: {"call c, {a16}", CondCall}, 0xDE: {"sbc a, {n8}", Normal}, 0xDF: {"rst $18", Call},
+	0xE0: {"ldh [{a8}], a", Normal}, 0xE1: {"pop hl", Normal}, 0xE2: {"ldh [c], a", Normal},
+	0xE5: {"push hl", Normal}, 0xE6: {"and {n8}", Normal}, 0xE7: {"rst $20", Call},
+	0xE8: {"add sp, {e8}", Normal}, 0xE9: {"jp hl", JumpHL}, 0xEA: {"ld [{a16}], a", Normal},
+	0xEE: {"xor {n8}", Normal}, 0xEF: {"rst $28", Call},
+	0xF0: {"ldh a, [{a8}]", Normal}, 0xF1: {"pop af", Normal}, 0xF2: {"ldh a, [c]", Normal}, 0xF3: {"di", Normal},
+	0xF5: {"push af", Normal}, 0xF6: {"or {n8}", Normal}, 0xF7: {"rst $30", Call},
+	0xF8: {"ld hl, sp{spe8}", Normal}, 0xF9: {"ld sp, hl", Normal}, 0xFA: {"ld a, [{a16}]", Normal}, 0xFB: {"ei", Normal},
+	0xFE: {"cp {n8}", Normal}, 0xFF: {"rst $38", Call},
+}
+
+func init() {
+	for op := 0x40; op < 0x80; op++ {
+		base[op] = entry{fmt.Sprintf("ld %s, %s", regs8[(op>>3)&7], regs8[op&7]), Normal}
+	}
+	base[0x76] = entry{"halt", Normal}
+	alu := [8]string{"add a, ", "adc a, ", "sub ", "sbc a, ", "and ", "xor ", "or ", "cp "}
+	for op := 0x80; op < 0xC0; op++ {
+		base[op] = entry{alu[(op>>3)&7] + regs8[op&7], Normal}
+	}
+	for _, op := range []byte{0xD3, 0xDB, 0xDD, 0xE3, 0xE4, 0xEB, 0xEC, 0xED, 0xF4, 0xFC, 0xFD} {
+		base[op] = entry{"db {db}", Invalid}
+	}
+}
+
+func cbMnemonic(cb byte) string {
+	r := regs8[cb&7]
+	n := (cb >> 3) & 7
+	switch cb >> 6 {
+	case 0:
+		rot := [8]string{"rlc", "rrc", "rl", "rr", "sla", "sra", "swap", "srl"}
+		return rot[n] + " " + r
+	case 1:
+		return fmt.Sprintf("bit %d, %s", n, r)
+	case 2:
+		return fmt.Sprintf("res %d, %s", n, r)
+	default:
+		return fmt.Sprintf("set %d, %s", n, r)
+	}
+}
@@ -0,0 +1,135 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func reader(code ...byte) Reader {
+	return func(addr uint16) byte {
+		if int(addr) < len(code) {
+			return code[addr]
+		}
+		return 0
+	}
+}
+
+type labelMap map[uint16]string
+
+func (l labelMap) Lookup(bank int, addr uint16) (string, bool) {
+	s, ok := l[addr]
+	return s, ok
+}
+
+func TestDecode_Formats(t *testing.T) {
+	cases := []struct {
+		code []byte
+		want string
+		n    int
+	}{
+		{[]byte{0x00}, "nop", 1},
+		{[]byte{0x01, 0x34, 0x12}, "ld bc, $1234", 3},
+		{[]byte{0x08, 0x00, 0xC0}, "ld [$C000], sp", 3},
+		{[]byte{0x10, 0x00}, "stop", 2},
+		{[]byte{0x18, 0xFE}, "jr $0000", 2},
+		{[]byte{0x22}, "ld [hl+], a", 1},
+		{[]byte{0x3A}, "ld a, [hl-]", 1},
+		{[]byte{0x46}, "ld b, [hl]", 1},
+		{[]byte{0x76}, "halt", 1},
+		{[]byte{0x8E}, "adc a, [hl]", 1},
+		{[]byte{0x97}, "sub a", 1},
+		{[]byte{0xE0, 0x44}, "ldh [$FF44], a", 2},
+		{[]byte{0xF2}, "ldh a, [c]", 1},
+		{[]byte{0xE8, 0xFD}, "add sp, -3", 2},
+		{[]byte{0xF8, 0x05}, "ld hl, sp + 5", 2},
+		{[]byte{0xF8, 0x80}, "ld hl, sp - 128", 2},
+		{[]byte{0xFF}, "rst $38", 1},
+		{[]byte{0xD3}, "db $D3", 1},
+		{[]byte{0xCB, 0x37}, "swap a", 2},
+		{[]byte{0xCB, 0x7E}, "bit 7, [hl]", 2},
+		{[]byte{0xCB, 0xC1}, "set 0, c", 2},
+	}
+	for _, tc := range cases {
+		in := Decode(reader(tc.code...), 0)
+		if got := in.String(); got != tc.want || in.Len() != tc.n {
+			t.Errorf("% X: got %q len %d, want %q len %d", tc.code, got, in.Len(), tc.want, tc.n)
+		}
+	}
+}
+
+// TestDecode_AllOpcodes checks that every opcode decodes to the length the CPU consumes.
+func TestDecode_AllOpcodes(t *testing.T) {
+	two := map[byte]bool{0x10: true, 0xCB: true, 0xE0: true, 0xE8: true, 0xF0: true, 0xF8: true}
+	three := map[byte]bool{0x01: true, 0x08: true, 0x11: true, 0x21: true, 0x31: true, 0xEA: true, 0xFA: true}
+	for op := 0; op < 256; op++ {
+		b := byte(op)
+		want := 1
+		switch {
+		case two[b], b < 0x40 && b&7 == 6, b < 0x40 && b&0xE7 == 0x20, b == 0x18,
+			b >= 0xC0 && b&7 == 6:
+			want = 2
+		case three[b], b >= 0xC0 && (b&0xE7 == 0xC2 || b&0xE7 == 0xC4 || b == 0xC3 || b == 0xCD):
+			want = 3
+		}
+		in := Decode(reader(b, 0x12, 0x34), 0)
+		if in.Len() != want || in.String() == "" || strings.Contains(in.String(), "{") {
+			t.Errorf("%02X: %q len %d want len %d", b, in.String(), in.Len(), want)
+		}
+		cb := Decode(reader(0xCB, b), 0)
+		if cb.Len() != 2 || cb.String() == "" {
+			t.Errorf("CB %02X: %q len %d", b, cb.String(), cb.Len())
+		}
+	}
+}
+
+func TestDecode_KindsAndLabels(t *testing.T) {
+	labels := labelMap{0x0150: "Main", 0xFF40: "rLCDC", 0x4000: "Bank1Start"}
+	in := Decode(reader(0xCD, 0x50, 0x01), 0)
+	if in.Kind != Call || in.Target != 0x0150 || in.Format(labels, 1) != "call Main" {
+		t.Fatalf("call: %+v %q", in, in.Format(labels, 1))
+	}
+	in = Decode(reader(0xE0, 0x40), 0)
+	if got := in.Format(labels, 1); got != "ldh [rLCDC], a" {
+		t.Fatalf("ldh label got %q", got)
+	}
+	if k := Decode(reader(0xC8), 0).Kind; k != CondReturn {
+		t.Fatalf("ret z kind %d", k)
+	}
+	if k := Decode(reader(0xE9), 0).Kind; k != JumpHL {
+		t.Fatalf("jp hl kind %d", k)
+	}
+}
+
+func TestWriteBank_CoverageAndLabels(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x150:], []byte{0x3E, 0x01, 0xC9, 0xAA, 0xBB, 0x00})
+	cov := &Coverage{}
+	cov.Add(0, 0x150)
+	cov.Add(0, 0x152)
+	cov.Add(0, 0x155)
+	var buf bytes.Buffer
+	err := WriteBank(&buf, rom, 0, 0x150, 0x155, Options{Labels: labelMap{0x150: "Main", 0x153: "Main.table"}, Coverage: cov})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`SECTION "ROM Bank $000", ROM0[$0150]`,
+		"Main:\n    ld a, $01",
+		"    ret ",
+		".table:\n    db $AA, $BB ",
+		"    nop ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("listing missing %q:\n%s", want, out)
+		}
+	}
+
+	var txt bytes.Buffer
+	cov.WriteTo(&txt)
+	back, err := ReadCoverage(&txt)
+	if err != nil || back.Len() != 3 || !back.Has(0, 0x155) {
+		t.Fatalf("coverage round trip: len %d err %v", back.Len(), err)
+	}
+}
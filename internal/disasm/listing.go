@@ -0,0 +1,176 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Coverage records which ROM addresses were executed as instruction starts, so a
+// listing can tell code from data. The text form has one "bank:addr" per line in hex,
+// the same notation as RGBDS symbol files.
+type Coverage struct {
+	m map[uint32]struct{}
+}
+
+func covKey(bank int, addr uint16) uint32 { return uint32(bank)<<16 | uint32(addr) }
+
+// Add marks an instruction start. Addresses in 0000-3FFF are always bank 0.
+func (c *Coverage) Add(bank int, addr uint16) {
+	if c.m == nil {
+		c.m = make(map[uint32]struct{})
+	}
+	if addr < 0x4000 {
+		bank = 0
+	}
+	c.m[covKey(bank, addr)] = struct{}{}
+}
+
+// Has reports whether an instruction started at addr in bank.
+func (c *Coverage) Has(bank int, addr uint16) bool {
+	if c == nil || c.m == nil {
+		return false
+	}
+	if addr < 0x4000 {
+		bank = 0
+	}
+	_, ok := c.m[covKey(bank, addr)]
+	return ok
+}
+
+// Len returns the number of recorded instruction starts.
+func (c *Coverage) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.m)
+}
+
+// WriteTo writes the coverage in its text form, ordered by bank and address.
+func (c *Coverage) WriteTo(w io.Writer) (int64, error) {
+	keys := make([]uint32, 0, len(c.m))
+	for k := range c.m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	bw := bufio.NewWriter(w)
+	var n int64
+	for _, k := range keys {
+		m, err := fmt.Fprintf(bw, "%02X:%04X\n", k>>16, k&0xFFFF)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ReadCoverage parses the text form written by WriteTo.
+func ReadCoverage(r io.Reader) (*Coverage, error) {
+	c := &Coverage{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		var bank int
+		var addr uint16
+		if _, err := fmt.Sscanf(line, "%x:%x", &bank, &addr); err != nil {
+			return nil, fmt.Errorf("coverage: line %d: %q", n, line)
+		}
+		c.Add(bank, addr)
+	}
+	return c, sc.Err()
+}
+
+// Options controls a listing.
+type Options struct {
+	Labels   Labels    // names for addresses and label lines; may be nil
+	Coverage *Coverage // when set, only covered instructions are code, the rest is data
+}
+
+// BankWindow returns the CPU address range a ROM bank is mapped at.
+func BankWindow(bank int) (start, end uint16) {
+	if bank == 0 {
+		return 0x0000, 0x3FFF
+	}
+	return 0x4000, 0x7FFF
+}
+
+// WriteBank disassembles start..end (inclusive, CPU addresses) of one ROM bank as an
+// RGBDS source section. Without coverage every byte is decoded as code (a linear
+// sweep); with it, bytes outside executed instructions become "db" lines.
+func WriteBank(w io.Writer, rom []byte, bank int, start, end uint16, opt Options) error {
+	lo, hi := BankWindow(bank)
+	if start < lo {
+		start = lo
+	}
+	if end > hi || end < start {
+		end = hi
+	}
+	offset := bank*0x4000 - int(lo)
+	read := func(addr uint16) byte {
+		if i := offset + int(addr); addr >= lo && addr <= hi && i < len(rom) {
+			return rom[i]
+		}
+		return 0xFF
+	}
+	bw := bufio.NewWriter(w)
+	if bank == 0 {
+		fmt.Fprintf(bw, "SECTION \"ROM Bank $000\", ROM0[$%04X]\n\n", start)
+	} else {
+		fmt.Fprintf(bw, "SECTION \"ROM Bank $%03X\", ROMX[$%04X], BANK[$%X]\n\n", bank, start, bank)
+	}
+	label := func(addr uint16) {
+		if opt.Labels == nil {
+			return
+		}
+		if name, ok := opt.Labels.Lookup(bank, addr); ok {
+			if strings.Contains(name, ".") {
+				fmt.Fprintf(bw, "%s:\n", name[strings.LastIndexByte(name, '.'):])
+			} else {
+				fmt.Fprintf(bw, "%s:\n", name)
+			}
+		}
+	}
+	hasLabel := func(addr uint16) bool {
+		if opt.Labels == nil {
+			return false
+		}
+		_, ok := opt.Labels.Lookup(bank, addr)
+		return ok
+	}
+	isCode := func(addr uint16) bool { return opt.Coverage == nil || opt.Coverage.Has(bank, addr) }
+
+	for addr := int(start); addr <= int(end); {
+		a := uint16(addr)
+		label(a)
+		if isCode(a) {
+			in := Decode(read, a)
+			if int(a)+in.Len()-1 <= int(end) {
+				comment := fmt.Sprintf("; $%04X:", a)
+				for _, b := range in.Bytes {
+					comment += fmt.Sprintf(" %02X", b)
+				}
+				fmt.Fprintf(bw, "    %-40s%s\n", in.Format(opt.Labels, bank), comment)
+				addr += in.Len()
+				continue
+			}
+		}
+		// Data run: up to 8 bytes, ending early at code or a label
+		var vals []string
+		for len(vals) < 8 && addr <= int(end) {
+			b := uint16(addr)
+			if len(vals) > 0 && (isCode(b) || hasLabel(b)) {
+				break
+			}
+			vals = append(vals, fmt.Sprintf("$%02X", read(b)))
+			addr++
+		}
+		fmt.Fprintf(bw, "    %-40s; $%04X\n", "db "+strings.Join(vals, ", "), a)
+	}
+	return bw.Flush()
+}
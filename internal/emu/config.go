@@ -1,10 +1,16 @@
 package emu
 
+import "io"
+
 // Config contains settings that affect emulation behavior.
 type Config struct {
-	Trace        bool // log CPU instructions
-	LimitFPS     bool // throttle to ~60 Hz (useful for headless test mode)
-	UseFetcherBG bool // render BG via fetcher/FIFO scanline path
-	UseCGBBG     bool // experimental: use CGB BG/window path with attributes
+	Trace        bool      // log CPU instructions in gameboy-doctor format (see package trace)
+	TraceOut     io.Writer // where the trace goes; nil means stdout
+	DoctorLY     bool      // pin LY to $90 as gameboy-doctor reference logs expect
+	LimitFPS     bool      // throttle to ~60 Hz (useful for headless test mode)
+	UseFetcherBG bool      // classic renderer, with BG via fetcher/FIFO scanline path (implies ClassicPPU)
+	UseCGBBG     bool      // experimental: use CGB BG/window path with attributes
+	ClassicPPU   bool      // draw frames from per-line register snapshots instead of the PPU's pixel FIFO
+	Model        Model     // hardware to emulate; ModelAuto picks DMG or CGB per game
 	// Later: fast-forward, GBC enable, debugger flags, etc.
 }
@@ -0,0 +1,129 @@
+package emu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cart"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/debug"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/disasm"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/symbols"
+)
+
+// Debugger returns the machine's debugger, attaching it on first use. Breakpoints and
+// watchpoints survive loading another ROM. While it is paused, StepFrame runs no CPU.
+func (m *Machine) Debugger() *debug.Debugger {
+	if m.dbg == nil {
+		m.dbg = debug.New()
+		if m.cpu != nil {
+			m.dbg.Attach(m.cpu, m.bus)
+		}
+		m.dbg.SetSymbols(m.syms)
+	}
+	return m.dbg
+}
+
+// LoadSymbols reads an RGBDS .sym or .map file for the loaded ROM. LoadROMFromFile
+// finds one next to the ROM by itself; LoadCartridge clears them.
+func (m *Machine) LoadSymbols(path string) error {
+	t, err := symbols.Load(path)
+	if err != nil {
+		return err
+	}
+	m.SetSymbols(t)
+	return nil
+}
+
+// SetSymbols replaces the symbol table; nil clears it.
+func (m *Machine) SetSymbols(t *symbols.Table) {
+	m.syms = t
+	if m.dbg != nil {
+		m.dbg.SetSymbols(t)
+	}
+}
+
+// Symbols returns the loaded symbol table, or nil.
+func (m *Machine) Symbols() *symbols.Table { return m.syms }
+
+// ROMBank returns the ROM bank currently mapped at 4000-7FFF.
+func (m *Machine) ROMBank() int {
+	if m.bus == nil {
+		return 1
+	}
+	if br, ok := m.bus.Cart().(cart.BankedROM); ok {
+		return br.ROMBank()
+	}
+	return 1
+}
+
+// DescribeAddr names an address for display: "Main+$3" with symbols loaded, else "$XXXX".
+// Addresses in 4000-7FFF are resolved in the currently mapped bank.
+func (m *Machine) DescribeAddr(addr uint16) string {
+	if m.syms == nil {
+		return fmt.Sprintf("$%04X", addr)
+	}
+	return m.syms.Describe(m.ROMBank(), addr)
+}
+
+// Disassemble returns n instructions starting at addr, one "addr  text" line each,
+// with labels from the symbol table.
+func (m *Machine) Disassemble(addr uint16, n int) []string {
+	if m.bus == nil {
+		return nil
+	}
+	var labels disasm.Labels
+	if m.syms != nil {
+		labels = m.syms
+	}
+	out := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		in := disasm.Decode(m.bus.Peek, addr)
+		line := fmt.Sprintf("%04X  %s", addr, in.Format(labels, m.ROMBank()))
+		if m.syms != nil {
+			if name, ok := m.syms.Lookup(m.ROMBank(), addr); ok {
+				line = name + ":\n" + line
+			}
+		}
+		out = append(out, line)
+		addr = in.Next()
+	}
+	return out
+}
+
+// CrashReport describes the CPU state for bug reports: registers, where PC is in the
+// symbol table, the code at PC and the top of the stack with return addresses named.
+func (m *Machine) CrashReport() string {
+	if m.cpu == nil || m.bus == nil {
+		return "no cartridge loaded"
+	}
+	c := m.cpu
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "PC=%04X (%02X:%s) SP=%04X\n", c.PC, m.bankOf(c.PC), m.DescribeAddr(c.PC), c.SP)
+	fmt.Fprintf(&sb, "A=%02X F=%02X B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X IME=%t IE=%02X IF=%02X\n",
+		c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L, c.IME, m.bus.Peek(0xFFFF), m.bus.Peek(0xFF0F))
+	sb.WriteString("code:\n")
+	for _, l := range m.Disassemble(c.PC, 6) {
+		sb.WriteString("  " + strings.ReplaceAll(l, "\n", "\n  ") + "\n")
+	}
+	sb.WriteString("stack:\n")
+	for i := uint16(0); i < 8; i++ {
+		sp := c.SP + 2*i
+		if sp < c.SP || sp >= 0xFFFF {
+			break
+		}
+		v := uint16(m.bus.Peek(sp)) | uint16(m.bus.Peek(sp+1))<<8
+		fmt.Fprintf(&sb, "  %04X: %04X %s\n", sp, v, m.DescribeAddr(v))
+	}
+	return sb.String()
+}
+
+func (m *Machine) bankOf(addr uint16) int {
+	switch {
+	case addr < 0x4000:
+		return 0
+	case addr < 0x8000:
+		return m.ROMBank()
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,50 @@
+package emu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMachine_SymbolsFoundNextToROM(t *testing.T) {
+	dir := t.TempDir()
+	rom := make([]byte, 0x8000)
+	// 0100: jp $0150; 0150: call $0160; 0153: jr $0153; 0160: ret
+	copy(rom[0x100:], []byte{0xC3, 0x50, 0x01})
+	copy(rom[0x150:], []byte{0xCD, 0x60, 0x01, 0x18, 0xFE})
+	rom[0x160] = 0xC9
+	romPath := filepath.Join(dir, "game.gb")
+	if err := os.WriteFile(romPath, rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sym := "00:0150 Main\n00:0160 Helper\n"
+	if err := os.WriteFile(filepath.Join(dir, "game.sym"), []byte(sym), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := New(Config{})
+	if err := m.LoadROMFromFile(romPath); err != nil {
+		t.Fatal(err)
+	}
+	if m.Symbols().Len() != 2 {
+		t.Fatalf("symbols not loaded: %d", m.Symbols().Len())
+	}
+	if got := m.DescribeAddr(0x0153); got != "Main+$3" {
+		t.Fatalf("DescribeAddr got %q", got)
+	}
+	addr, bank, err := m.Debugger().Resolve("Helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Debugger().AddBreakpoint(addr, bank, nil)
+	m.StepFrame()
+	if !m.Debugger().Paused() {
+		t.Fatalf("breakpoint on Helper not hit")
+	}
+	report := m.CrashReport()
+	for _, want := range []string{"PC=0160 (00:Helper)", "Helper:\n", "ret", "0153 Main+$3"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("crash report missing %q:\n%s", want, report)
+		}
+	}
+}
@@ -10,7 +10,13 @@ import (
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/bus"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cart"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/debug"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/patch"
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/ppu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/romfile"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/sgb"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/symbols"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/trace"
 )
 
 type Buttons struct {
@@ -39,8 +45,20 @@ type Machine struct {
 	// Selected compatibility palette ID (0 = default) when in cgbCompat.
 	// 0..len(cgbCompatSets)-1; out-of-range will wrap.
 	cgbCompatID int
+	// True while the real CGB boot ROM runs; when it unmaps itself its KEY0 choice
+	// decides between CGB mode and compatibility mode (see finishCGBBoot)
+	cgbBooting bool
 
 	romTitle string // decoded title from header (trimmed)
+
+	camera cart.CameraSource // image source for Pocket Camera carts; kept across ROM loads
+	serial bus.SerialDevice  // link partner, printer or serial sink; kept across ROM loads
+	ir     bus.IRDevice      // what faces the infrared port; kept across ROM loads
+
+	dbg  *debug.Debugger // created on first use by Debugger; re-attached on ROM load
+	syms *symbols.Table  // labels for the loaded ROM (see LoadSymbols)
+
+	sgbFB []byte // RGBA 256x224 SGB picture, allocated on first use
 }
 
 // ErrStateIncompatibleMode is returned when loading a DMG savestate into a different
@@ -86,7 +104,7 @@ func (m *Machine) LoadCartridge(rom []byte, boot []byte) error {
 	// (256 bytes) but the game is CGB-capable, ignore it; without a proper CGB boot ROM,
 	// start directly at $0100 with CGB post-boot semantics so the game detects CGB.
 	useBoot := len(boot) >= 0x100
-	if romHeader != nil && (romHeader.CGBFlag&0x80) != 0 && len(boot) == 0x100 {
+	if romHeader != nil && (romHeader.CGBFlag&0x80) != 0 && len(boot) == 0x100 && !m.dmgModel() {
 		useBoot = false
 	}
 	// Wire bus+cpu. For now, ROM-only cartridge via bus.New.
@@ -101,16 +119,23 @@ func (m *Machine) LoadCartridge(rom []byte, boot []byte) error {
 		c.PC = 0x0000
 		c.IME = false
 	} else {
-		// No boot ROM: initialize to DMG post-boot state
+		// No boot ROM: start at $0100; the model's registers are set once the mode is known
 		c.ResetNoBoot()
 		c.SetPC(0x0100)
-		// If the game is CGB-capable, set A=$11 so it detects CGB hardware per Pan Docs
-		if romHeader != nil && (romHeader.CGBFlag&0x80) != 0 {
-			c.A = 0x11
-		}
 	}
 	m.bus = b
 	m.cpu = c
+	m.applyTrace()
+	if m.camera != nil {
+		m.SetCameraSource(m.camera)
+	}
+	b.SetSerialDevice(m.serial)
+	b.SetIRDevice(m.ir)
+	m.syms = nil
+	if m.dbg != nil {
+		m.dbg.Attach(c, b)
+		m.dbg.SetSymbols(nil)
+	}
 	m.bootROM = nil
 	if len(boot) >= 0x100 {
 		m.bootROM = make([]byte, 0x100)
@@ -138,6 +163,15 @@ func (m *Machine) LoadCartridge(rom []byte, boot []byte) error {
 			m.cgbCompat = false
 		}
 	}
+	// A chosen model overrides the per-game choice: CGB hardware always runs DMG games in
+	// compatibility mode, DMG-family hardware never exposes CGB features
+	if m.cfg.Model.CGB() {
+		m.cfg.UseCGBBG = true
+		m.bus.SetCGBMode(true)
+	} else if m.dmgModel() {
+		m.cfg.UseCGBBG = false
+		m.bus.SetCGBMode(false)
+	}
 	// If user had CGB colors toggled on and this ROM is DMG-only, enable compatibility mode now
 	if !m.cgbCapable && m.cfg.UseCGBBG && m.bus != nil {
 		m.bus.SetCGBMode(true)
@@ -150,6 +184,13 @@ func (m *Machine) LoadCartridge(rom []byte, boot []byte) error {
 		}
 		m.seedCGBCompatPalettes()
 	}
+	m.bus.SetSGB(nil)
+	if romHeader != nil && m.Model().SGB() && sgbGame(romHeader) {
+		m.bus.SetSGB(sgb.New())
+	}
+	if !useBoot {
+		m.applyPostBoot(m.Model())
+	}
 	return nil
 }
 
@@ -301,6 +342,14 @@ func clamp01(v float64) float64 {
 // SetUseFetcherBG toggles the BG renderer between classic and fetcher-based path.
 func (m *Machine) SetUseFetcherBG(on bool) { m.cfg.UseFetcherBG = on }
 
+// SetClassicPPU selects the classic renderer, which draws each frame after it ran from
+// per-line register snapshots, instead of the PPU's dot-accurate pixel FIFO output.
+// It loses mid-scanline effects.
+func (m *Machine) SetClassicPPU(on bool) { m.cfg.ClassicPPU = on }
+
+// pixelFIFO reports whether the framebuffer comes from the PPU's pixel FIFO.
+func (m *Machine) pixelFIFO() bool { return !m.cfg.ClassicPPU && !m.cfg.UseFetcherBG }
+
 // SetUseCGBBG toggles the CGB BG/Window/Sprite rendering path using CGB attributes and palettes.
 func (m *Machine) SetUseCGBBG(on bool) {
 	m.cfg.UseCGBBG = on
@@ -324,17 +373,33 @@ func (m *Machine) SetUseCGBBG(on bool) {
 }
 
 // UseCGBBG reports whether the CGB rendering path is enabled.
-func (m *Machine) UseCGBBG() bool { return m.cfg.UseCGBBG && m.cgbCapable }
+// While the CGB boot ROM runs it is on for every game, as the logo uses CGB palettes.
+func (m *Machine) UseCGBBG() bool { return m.cfg.UseCGBBG && (m.cgbCapable || m.cgbBooting) }
 
 // WantCGBColors reports the user's intent to enable CGB colorization (even for DMG ROMs).
 func (m *Machine) WantCGBColors() bool { return m.cfg.UseCGBBG }
 
 // LoadROMFromFile replaces the current cartridge with a ROM from disk, preserving boot ROM setting.
+// Zip and gzip archives are accepted; from a zip the first .gb/.gbc entry is loaded.
 func (m *Machine) LoadROMFromFile(path string) error {
-	data, err := os.ReadFile(path)
+	return m.LoadROMFromArchive(path, "")
+}
+
+// LoadROMFromArchive loads the named entry of a zip archive (empty picks the first ROM).
+// Saves and savestates are named after the archive, or after archive and entry when it
+// holds several ROMs (see romfile.NamePath).
+// An IPS/BPS/UPS patch next to the ROM (see patch.Find) is applied before the header is parsed.
+func (m *Machine) LoadROMFromArchive(path, entry string) error {
+	data, err := romfile.ReadEntry(path, entry)
 	if err != nil {
 		return err
 	}
+	name := romfile.NamePath(path, entry)
+	if pp := patch.Find(name); pp != "" {
+		if data, err = patch.ApplyFile(data, pp); err != nil {
+			return err
+		}
+	}
 	var boot []byte
 	if len(m.bootROM) >= 0x100 {
 		boot = m.bootROM
@@ -342,7 +407,11 @@ func (m *Machine) LoadROMFromFile(path string) error {
 	if err := m.LoadCartridge(data, boot); err != nil {
 		return err
 	}
-	m.romPath = path
+	m.romPath = name
+	// Symbols are best-effort: a broken .sym file must not keep the game from loading
+	if sp := symbols.Find(name); sp != "" {
+		_ = m.LoadSymbols(sp)
+	}
 	// When in DMG compat mode after load, try to compute a palette ID from header
 	if m.cgbCompat {
 		if id, ok := computeCompatPaletteIDFromROM(data); ok {
@@ -353,7 +422,8 @@ func (m *Machine) LoadROMFromFile(path string) error {
 	return nil
 }
 
-// ROMPath returns the currently loaded ROM file path, if any.
+// ROMPath returns the currently loaded ROM file path, if any. For a zip with several ROMs
+// it is the entry's romfile.NamePath.
 func (m *Machine) ROMPath() string {
 	return m.romPath
 }
@@ -375,12 +445,13 @@ func (m *Machine) SetBootROM(data []byte) {
 	}
 }
 
-// SetCGBBootROM sets the CGB boot ROM image.
+// SetCGBBootROM sets the CGB boot ROM image: a full 0x900-byte dump, or 0x800 bytes
+// without the cartridge header hole (see bus.SetCGBBootROM).
 func (m *Machine) SetCGBBootROM(data []byte) {
 	if len(data) >= 0x800 {
-		m.cgbBootROM = make([]byte, 0x800)
-		// accept either full 0x800 CGB boot or a larger blob containing it
-		copy(m.cgbBootROM, data[len(data)-0x800:])
+		n := min(len(data), 0x900)
+		m.cgbBootROM = make([]byte, n)
+		copy(m.cgbBootROM, data[:n])
 	} else {
 		m.cgbBootROM = nil
 	}
@@ -396,14 +467,48 @@ func (m *Machine) HasBootROM() bool { return len(m.bootROM) >= 0x100 }
 func (m *Machine) HasCGBBootROM() bool { return len(m.cgbBootROM) >= 0x800 }
 
 // ResetPostBoot resets CPU and IO to DMG post-boot state (no boot ROM), keeping the loaded cartridge.
+// With a CGB model configured it resets to the CGB post-boot state instead.
 func (m *Machine) ResetPostBoot() {
 	if m.cpu == nil || m.bus == nil {
 		return
 	}
+	if m.cfg.Model.CGB() {
+		m.ResetCGBPostBoot(!m.cgbCapable)
+		return
+	}
+	model := m.cfg.Model
+	if model == ModelAuto {
+		model = ModelDMG
+	}
+	m.resetBus()
 	m.cpu.ResetNoBoot()
 	m.cpu.SetPC(0x0100)
 	m.applyDMGPostBootIO()
 	m.bus.EnableBoot(0)
+	m.applyPostBoot(model)
+}
+
+// applyTrace installs the trace writer and LY override requested by the config.
+func (m *Machine) applyTrace() {
+	if m.cfg.Trace {
+		out := m.cfg.TraceOut
+		if out == nil {
+			out = os.Stdout
+		}
+		m.cpu.SetTracer(trace.NewDoctor(out))
+	}
+	if m.cfg.DoctorLY {
+		m.bus.SetLYOverride(trace.DoctorLY)
+	}
+}
+
+// resetBus clears bus state that a console reset does not carry over, whichever reset path is taken.
+func (m *Machine) resetBus() {
+	m.bus.ResetSpeed()
+	m.bus.ResetHDMA()
+	if s := m.bus.SGB(); s != nil {
+		s.Reset()
+	}
 }
 
 // ResetWithBoot re-enables the boot ROM (if present) and restarts execution from 0x0000.
@@ -413,6 +518,7 @@ func (m *Machine) ResetWithBoot() {
 		m.ResetPostBoot()
 		return
 	}
+	m.resetBus()
 	m.bus.SetBootROM(m.bootROM)
 	m.bus.EnableBoot(1)
 	m.cpu.SP = 0xFFFE
@@ -420,12 +526,19 @@ func (m *Machine) ResetWithBoot() {
 	m.cpu.IME = false
 }
 
-// ResetWithCGBBoot enables the CGB boot ROM and restarts from 0x0000.
+// ResetWithCGBBoot enables the CGB boot ROM and restarts from 0x0000 on CGB hardware.
+// The boot ROM shows the logo, picks compatibility palettes for DMG games and sets
+// KEY0/OPRI itself; when it writes FF50 the machine takes over its choice of mode.
 func (m *Machine) ResetWithCGBBoot() {
 	if m.cpu == nil || m.bus == nil || len(m.cgbBootROM) < 0x800 {
 		m.ResetPostBoot()
 		return
 	}
+	m.resetBus()
+	m.cfg.UseCGBBG = true
+	m.bus.SetCGBMode(true)
+	m.cgbCompat = false
+	m.cgbBooting = true
 	m.bus.SetCGBBootROM(m.cgbBootROM)
 	m.bus.EnableBoot(2)
 	m.cpu.SP = 0xFFFE
@@ -433,6 +546,14 @@ func (m *Machine) ResetWithCGBBoot() {
 	m.cpu.IME = false
 }
 
+// finishCGBBoot runs once the CGB boot ROM has unmapped itself. KEY0 bit 2 tells
+// whether it put the console in DMG compatibility mode; the palettes it wrote to
+// CRAM stay as they are.
+func (m *Machine) finishCGBBoot() {
+	m.cgbBooting = false
+	m.cgbCompat = m.bus.DMGCompatMode()
+}
+
 // ResetCGBPostBoot simulates the CGB boot hand-off: enables CGB hardware, sets A=0x11, and jumps to $0100.
 // If compat is true (DMG ROM on CGB), this represents DMG compatibility mode; we still enable CGB hardware
 // so palettes and VBK/SVBK exist, but DMG games will keep grayscale unless we implement compatibility palettes.
@@ -440,21 +561,29 @@ func (m *Machine) ResetCGBPostBoot(compat bool) {
 	if m.cpu == nil || m.bus == nil {
 		return
 	}
+	m.resetBus()
 	// Expose CGB hardware to the CPU
 	m.bus.SetCGBMode(true)
 	// Track compatibility mode for DMG ROMs under CGB
 	m.cgbCompat = compat
+	m.cgbBooting = false
 	// Clear any boot mapping
 	m.bus.EnableBoot(0)
-	// CPU state like CGB after boot
+	// CPU state like CGB after boot; A=$11 tells games they run on CGB hardware
 	m.cpu.ResetNoBoot()
 	m.cpu.SetPC(0x0100)
-	m.cpu.A = 0x11 // indicate CGB hardware per Pan Docs
 	// Set minimal IO similar to applyDMGPostBootIO
 	m.applyDMGPostBootIO()
-	// Seed default compatibility palettes when running a DMG ROM under CGB
+	model := m.cfg.Model
+	if !model.CGB() {
+		model = ModelCGB
+	}
+	m.applyPostBoot(model)
+	// Seed default compatibility palettes when running a DMG ROM under CGB; the boot
+	// ROM also switches OBJ priority to DMG-style X ordering
 	if compat {
 		m.seedCGBCompatPalettes()
+		m.bus.Write(0xFF6C, 0x01)
 	}
 }
 
@@ -518,30 +647,121 @@ func (m *Machine) LoadBattery(data []byte) bool {
 	return false
 }
 
+// HasTilt reports whether the loaded cartridge has an accelerometer (MBC7).
+func (m *Machine) HasTilt() bool {
+	if m == nil || m.bus == nil {
+		return false
+	}
+	_, ok := m.bus.Cart().(interface{ SetTilt(x, y float64) })
+	return ok
+}
+
+// SetTilt feeds the cartridge accelerometer in units of g (-1..1 per axis).
+// Positive x tilts right, positive y tilts towards the player. No-op without an MBC7.
+func (m *Machine) SetTilt(x, y float64) {
+	if m == nil || m.bus == nil {
+		return
+	}
+	if t, ok := m.bus.Cart().(interface{ SetTilt(x, y float64) }); ok {
+		t.SetTilt(x, y)
+	}
+}
+
+// HasCamera reports whether the loaded cartridge is a Pocket Camera.
+func (m *Machine) HasCamera() bool {
+	if m == nil || m.bus == nil {
+		return false
+	}
+	_, ok := m.bus.Cart().(interface{ SetCameraSource(cart.CameraSource) })
+	return ok
+}
+
+// SetCameraSource sets the image source for the Pocket Camera sensor. It is remembered
+// and applied to cartridges loaded later; nil leaves the sensor dark.
+func (m *Machine) SetCameraSource(src cart.CameraSource) {
+	if m == nil {
+		return
+	}
+	m.camera = src
+	if m.bus == nil {
+		return
+	}
+	if c, ok := m.bus.Cart().(interface{ SetCameraSource(cart.CameraSource) }); ok {
+		c.SetCameraSource(src)
+	}
+}
+
 func (m *Machine) StepFrame() {
+	m.prepareFrame()
 	m.stepFrameCPU()
-	// Render background, window, then sprites
-	m.renderBG()
-	m.renderWindow()
-	m.renderSprites()
+	m.presentFrame()
 }
 
 // StepFrameNoRender advances one frame of emulation without producing a new framebuffer.
 func (m *Machine) StepFrameNoRender() { m.stepFrameCPU() }
 
-// stepFrameCPU advances CPU for approximately one frame worth of cycles (~70224).
+// frameDots is the length of a frame in dots (154 lines of 456 dots).
+const frameDots = 70224
+
+// stepFrameCPU advances CPU for approximately one frame worth of cycles (~70224 dots).
+// In CGB double-speed mode a dot lasts two CPU cycles, so twice as many CPU cycles run.
 func (m *Machine) stepFrameCPU() {
 	if m.cpu == nil {
 		return
 	}
-	target := 70224
-	acc := 0
-	for acc < target {
-		acc += m.cpu.Step()
+	for acc := 0; acc < frameDots; {
+		if m.dbg != nil && m.dbg.Paused() {
+			return
+		}
+		acc += m.stepInstruction()
+	}
+	m.sgbTransfer()
+	m.syncSerial()
+}
+
+// stepInstruction runs one CPU instruction and returns how many dots it took.
+func (m *Machine) stepInstruction() int {
+	cyc := m.cpu.Step()
+	if m.bus.DoubleSpeed() {
+		cyc /= 2
+	}
+	if m.cgbBooting && !m.bus.BootROMActive() {
+		m.finishCGBBoot()
+		m.bus.PPU().SetColorMode(m.UseCGBBG(), m.cgbCompat)
+	}
+	return cyc
+}
+
+// prepareFrame sets up the PPU's color mode before a frame runs. It is set for the
+// classic renderer too, since it decides the pipeline's fetches and window timing.
+func (m *Machine) prepareFrame() {
+	if m.bus != nil {
+		m.bus.PPU().SetColorMode(m.UseCGBBG(), m.cgbCompat)
+	}
+}
+
+// presentFrame fills the framebuffer once a frame has run.
+func (m *Machine) presentFrame() {
+	if m.pixelFIFO() && m.bus != nil {
+		// The PPU draws while it runs; just pick up its last completed frame
+		copy(m.fb, m.bus.PPU().Framebuffer())
+	} else {
+		// Render background, window, then sprites
+		m.renderBG()
+		m.renderWindow()
+		m.renderSprites()
 	}
+	m.renderSGB()
 }
 
-func (m *Machine) Framebuffer() []byte { return m.fb }
+// Framebuffer returns the last frame as RGBA, sized as FrameSize reports: the SGB
+// picture with its border while SGB features are on, else the 160x144 screen.
+func (m *Machine) Framebuffer() []byte {
+	if m.SGBActive() {
+		return m.sgbFB
+	}
+	return m.fb
+}
 
 // IsCGBCompat reports if we're running a DMG ROM under CGB colorization.
 func (m *Machine) IsCGBCompat() bool { return m != nil && m.cgbCompat }
@@ -603,11 +823,46 @@ func computeCompatPaletteIDFromROM(rom []byte) (int, bool) {
 	return 0, true
 }
 
-// SetSerialWriter connects an io.Writer to receive bytes written to the serial port (FF01/FF02).
-// Useful for running test ROMs that report via serial.
+// SetSerialDevice plugs a device into the serial port, such as a link partner in
+// another process (see package link) or a Game Boy Printer (see package printer), or
+// unplugs it with nil.
+func (m *Machine) SetSerialDevice(d bus.SerialDevice) {
+	m.serial = d
+	if m.bus != nil {
+		m.bus.SetSerialDevice(d)
+	}
+}
+
+// SetIRDevice points a device at the CGB infrared port, or removes it with nil.
+func (m *Machine) SetIRDevice(d bus.IRDevice) {
+	m.ir = d
+	if m.bus != nil {
+		m.bus.SetIRDevice(d)
+	}
+}
+
+// syncSerial lets a serial device that keeps both sides in lockstep wait for
+// the other side at the end of a frame; bytes it receives meanwhile go to the serial port.
+func (m *Machine) syncSerial() {
+	if s, ok := m.serial.(interface{ EndFrame(func(byte) byte) }); ok {
+		s.EndFrame(m.bus.SerialReceive)
+	}
+}
+
+// PauseSerial tells a serial device that keeps both sides in lockstep whether this
+// machine has stopped stepping frames, e.g. while the menu is open, so the other side
+// runs on instead of waiting for it.
+func (m *Machine) PauseSerial(paused bool) {
+	if s, ok := m.serial.(interface{ SetPaused(bool) }); ok {
+		s.SetPaused(paused)
+	}
+}
+
+// SetSerialWriter plugs a bus.SerialWriter into the serial port, so w receives the bytes
+// sent through FF01/FF02. Useful for running test ROMs that report via serial.
 func (m *Machine) SetSerialWriter(w interface{ Write([]byte) (int, error) }) {
-	if m != nil && m.bus != nil {
-		m.bus.SetSerialWriter(w)
+	if m != nil {
+		m.SetSerialDevice(bus.SerialWriter{W: w})
 	}
 }
 
@@ -657,6 +912,7 @@ type machineState struct {
 	CPU         []byte
 	CGBCompat   bool
 	CGBCompatID int
+	CGBBooting  bool
 }
 
 func (m *Machine) SaveState() []byte {
@@ -670,6 +926,7 @@ func (m *Machine) SaveState() []byte {
 		CPU:         m.cpu.SaveState(),
 		CGBCompat:   m.cgbCompat,
 		CGBCompatID: m.cgbCompatID,
+		CGBBooting:  m.cgbBooting,
 	})
 	return buf.Bytes()
 }
@@ -685,6 +942,7 @@ func (m *Machine) LoadState(data []byte) error {
 	}
 	m.bus.LoadState(s.Bus)
 	m.cpu.LoadState(s.CPU)
+	m.cgbBooting = s.CGBBooting
 	// Reconcile loaded state with current user color toggle and ROM capability.
 	// Goals:
 	//  - DMG-only ROMs: require that the current color setting matches the state (no unsafe conversion at load).
@@ -692,7 +950,7 @@ func (m *Machine) LoadState(data []byte) error {
 	wantColors := m.cfg.UseCGBBG
 	if !m.cgbCapable {
 		// DMG-only ROM: reject mismatch to avoid broken state.
-		if wantColors != s.CGBCompat {
+		if wantColors != (s.CGBCompat || s.CGBBooting) {
 			return ErrStateIncompatibleMode
 		}
 		// Apply exactly the saved mode
@@ -701,6 +959,8 @@ func (m *Machine) LoadState(data []byte) error {
 		if m.cgbCompat {
 			m.bus.SetCGBMode(true)
 			m.seedCGBCompatPalettesID(m.cgbCompatID)
+		} else if m.cgbBooting {
+			m.bus.SetCGBMode(true)
 		} else {
 			m.bus.SetCGBMode(false)
 		}
@@ -0,0 +1,109 @@
+package emu
+
+// LinkCable connects the serial ports of two machines in the same process. Its
+// StepFrame runs both machines interleaved one instruction at a time, so the side
+// driving the serial clock exchanges every bit with a partner at the same point in
+// emulated time.
+type LinkCable struct {
+	m [2]*Machine
+}
+
+// NewLinkCable plugs a cable between a and b. Loading a new ROM replaces a machine's
+// bus; the cable plugs itself back in on the next StepFrame.
+func NewLinkCable(a, b *Machine) *LinkCable {
+	l := &LinkCable{m: [2]*Machine{a, b}}
+	l.plug()
+	return l
+}
+
+func (l *LinkCable) plug() {
+	a, b := l.m[0].bus, l.m[1].bus
+	if a != nil && b != nil {
+		a.ConnectSerial(b)
+	}
+}
+
+// Unplug disconnects the two machines and plugs back the devices set with
+// SetSerialDevice; afterwards each can be stepped on its own.
+func (l *LinkCable) Unplug() {
+	for _, m := range l.m {
+		if m.bus != nil {
+			m.bus.SetSerialDevice(m.serial)
+		}
+	}
+}
+
+// StepFrame advances both machines by one frame in lockstep, always stepping the one
+// that is behind, then renders both framebuffers. It stops early when either
+// machine's debugger pauses.
+func (l *LinkCable) StepFrame() {
+	l.plug()
+	stepLockstep(l.m)
+}
+
+// IRLink points the infrared ports of two machines in the same process at each other.
+// Like LinkCable, its StepFrame runs both machines interleaved, so each sensor sees
+// the other LED at the same point in emulated time.
+type IRLink struct {
+	m [2]*Machine
+}
+
+// NewIRLink faces the infrared ports of a and b. Loading a new ROM replaces a
+// machine's bus; the link connects itself again on the next StepFrame.
+func NewIRLink(a, b *Machine) *IRLink {
+	l := &IRLink{m: [2]*Machine{a, b}}
+	l.plug()
+	return l
+}
+
+func (l *IRLink) plug() {
+	a, b := l.m[0].bus, l.m[1].bus
+	if a != nil && b != nil {
+		a.ConnectIR(b)
+	}
+}
+
+// Unplug separates the two machines and points back the devices set with
+// SetIRDevice; afterwards each can be stepped on its own.
+func (l *IRLink) Unplug() {
+	for _, m := range l.m {
+		if m.bus != nil {
+			m.bus.SetIRDevice(m.ir)
+		}
+	}
+}
+
+// StepFrame advances both machines by one frame in lockstep, like LinkCable.StepFrame.
+func (l *IRLink) StepFrame() {
+	l.plug()
+	stepLockstep(l.m)
+}
+
+// stepLockstep advances two machines by one frame, always stepping the one that is
+// behind, then renders both framebuffers.
+func stepLockstep(ms [2]*Machine) {
+	var acc [2]int
+	for i, m := range ms {
+		m.prepareFrame()
+		if m.cpu == nil {
+			acc[i] = frameDots
+		}
+	}
+	for acc[0] < frameDots || acc[1] < frameDots {
+		i := 0
+		if acc[1] < acc[0] {
+			i = 1
+		}
+		m := ms[i]
+		if m.dbg != nil && m.dbg.Paused() {
+			break
+		}
+		acc[i] += m.stepInstruction()
+	}
+	for _, m := range ms {
+		if m.cpu != nil {
+			m.sgbTransfer()
+		}
+		m.presentFrame()
+	}
+}
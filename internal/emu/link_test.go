@@ -0,0 +1,145 @@
+package emu
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/link"
+)
+
+// serialROM sends out and stores the byte received at $C000. The master waits a
+// little before starting so the slave is already listening.
+func serialROM(t *testing.T, out, sc byte, wait byte) *Machine {
+	t.Helper()
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{
+		0x06, wait, // LD B,wait
+		0x05, 0x20, 0xFD, // .delay: DEC B; JR NZ,.delay
+		0x3E, out, 0xE0, 0x01, // LD A,out; LDH [$01],A
+		0x3E, sc, 0xE0, 0x02, // LD A,sc; LDH [$02],A
+		0xF0, 0x02, 0xCB, 0x7F, 0x20, 0xFA, // .wait: LDH A,[$02]; BIT 7,A; JR NZ,.wait
+		0xF0, 0x01, 0xEA, 0x00, 0xC0, // LDH A,[$01]; LD [$C000],A
+		0x18, 0xFE, // JR @
+	})
+	m := New(Config{})
+	if err := m.LoadCartridge(rom, nil); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestLinkCable_ExchangesBytes(t *testing.T) {
+	master := serialROM(t, 0x42, 0x81, 0x40)
+	slave := serialROM(t, 0x99, 0x80, 0x01)
+	l := NewLinkCable(master, slave)
+	l.StepFrame()
+	if got := master.bus.Read(0xC000); got != 0x99 {
+		t.Errorf("master received %02X, want 99", got)
+	}
+	if got := slave.bus.Read(0xC000); got != 0x42 {
+		t.Errorf("slave received %02X, want 42", got)
+	}
+
+	// Unplugged, a new master transfer reads $FF and the slave keeps waiting
+	l.Unplug()
+	master.bus.Write(0xFF02, 0x81)
+	slave.bus.Write(0xFF02, 0x80)
+	master.StepFrame()
+	slave.StepFrame()
+	if got := master.bus.Read(0xFF01); got != 0xFF {
+		t.Errorf("unplugged master received %02X, want FF", got)
+	}
+	if slave.bus.Read(0xFF02)&0x80 == 0 {
+		t.Errorf("unplugged slave finished its transfer")
+	}
+}
+
+func TestLink_TCPLoopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan *link.Conn, 1)
+	go func() {
+		c, err := link.Accept(ln)
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+	dialed, err := link.Dial(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dialed.Close()
+	listened := <-accepted
+	if listened == nil {
+		t.FailNow()
+	}
+	defer listened.Close()
+
+	master := serialROM(t, 0x42, 0x81, 0x40)
+	slave := serialROM(t, 0x99, 0x80, 0x01)
+	master.SetSerialDevice(listened)
+	slave.SetSerialDevice(dialed)
+	var wg sync.WaitGroup
+	for _, m := range []*Machine{master, slave} {
+		wg.Add(1)
+		go func(m *Machine) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				m.StepFrame()
+			}
+		}(m)
+	}
+	wg.Wait()
+	if got := master.bus.Read(0xC000); got != 0x99 {
+		t.Errorf("master received %02X, want 99", got)
+	}
+	if got := slave.bus.Read(0xC000); got != 0x42 {
+		t.Errorf("slave received %02X, want 42", got)
+	}
+}
+
+// irROM runs code as a color game.
+func irROM(t *testing.T, code ...byte) *Machine {
+	t.Helper()
+	rom := make([]byte, 0x8000)
+	rom[0x143] = 0x80
+	copy(rom[0x100:], code)
+	m := New(Config{})
+	if err := m.LoadCartridge(rom, nil); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestIRLink_SeesOtherLED(t *testing.T) {
+	sender := irROM(t,
+		0x06, 0x40, // LD B,$40
+		0x05, 0x20, 0xFD, // .delay: DEC B; JR NZ,.delay
+		0x3E, 0x01, 0xE0, 0x56, // LD A,$01; LDH [$56],A
+		0x18, 0xFE, // JR @
+	)
+	receiver := irROM(t,
+		0x3E, 0xC0, 0xE0, 0x56, // LD A,$C0; LDH [$56],A
+		0xF0, 0x56, 0xCB, 0x4F, 0x20, 0xFA, // .wait: LDH A,[$56]; BIT 1,A; JR NZ,.wait
+		0xFA, 0x56, 0xFF, 0xEA, 0x00, 0xC0, // LD A,[$FF56]; LD [$C000],A
+		0x18, 0xFE, // JR @
+	)
+	receiver.StepFrame()
+	if got := receiver.bus.Read(0xC000); got != 0x00 {
+		t.Fatalf("light seen with nothing connected: %02X", got)
+	}
+	l := NewIRLink(sender, receiver)
+	l.StepFrame()
+	if got := receiver.bus.Read(0xC000); got != 0xFC {
+		t.Fatalf("receiver read RP %02X, want FC", got)
+	}
+	l.Unplug()
+	if receiver.bus.Read(0xFF56)&0x02 == 0 {
+		t.Fatalf("light seen after unplugging")
+	}
+}
@@ -0,0 +1,143 @@
+package emu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model selects the Game Boy hardware to emulate. It decides the registers the boot ROM
+// leaves behind when starting without one, the DIV phase at $0100, and whether CGB
+// hardware is exposed.
+type Model int
+
+const (
+	ModelAuto Model = iota // DMG, or CGB for color games and with CGB colors on
+	ModelDMG0              // early Japanese DMG with the older boot ROM
+	ModelDMG               // Game Boy
+	ModelMGB               // Game Boy Pocket / Light
+	ModelSGB               // Super Game Boy
+	ModelSGB2              // Super Game Boy 2
+	ModelCGB               // Game Boy Color
+	ModelAGB               // Game Boy Advance in GB mode
+)
+
+var modelNames = [...]string{"auto", "dmg0", "dmg", "mgb", "sgb", "sgb2", "cgb", "agb"}
+
+func (m Model) String() string {
+	if m >= 0 && int(m) < len(modelNames) {
+		return modelNames[m]
+	}
+	return fmt.Sprintf("Model(%d)", int(m))
+}
+
+// ParseModel parses a model name as printed by String, case-insensitively.
+func ParseModel(s string) (Model, error) {
+	for i, n := range modelNames {
+		if strings.EqualFold(s, n) {
+			return Model(i), nil
+		}
+	}
+	return ModelAuto, fmt.Errorf("unknown model %q (want one of %s)", s, strings.Join(modelNames[:], ", "))
+}
+
+// CGB reports whether the model has CGB hardware (CGB and AGB).
+func (m Model) CGB() bool { return m == ModelCGB || m == ModelAGB }
+
+// SGB reports whether the model is a Super Game Boy.
+func (m Model) SGB() bool { return m == ModelSGB || m == ModelSGB2 }
+
+// dmgModel reports whether a model without CGB hardware was chosen explicitly.
+func (m *Machine) dmgModel() bool { return m.cfg.Model != ModelAuto && !m.cfg.Model.CGB() }
+
+// Model returns the hardware being emulated: the configured model, or for ModelAuto
+// CGB while CGB hardware is wanted and DMG otherwise.
+func (m *Machine) Model() Model {
+	if m.cfg.Model != ModelAuto {
+		return m.cfg.Model
+	}
+	if m.cfg.UseCGBBG {
+		return ModelCGB
+	}
+	return ModelDMG
+}
+
+// SetModel changes the model used by the next ROM load or reset.
+func (m *Machine) SetModel(model Model) { m.cfg.Model = model }
+
+// regs holds the 8-bit CPU registers.
+type regs struct{ A, F, B, C, D, E, H, L byte }
+
+// postBootRegs returns the registers each model's boot ROM leaves at $0100, per Pan Docs
+// (Power Up Sequence). Some depend on the cartridge header, read through header; cgbGame
+// tells CGB models whether the game runs in CGB mode or in DMG compatibility mode.
+func postBootRegs(model Model, cgbGame bool, header func(addr uint16) byte) regs {
+	// DMG and MGB leave H and C set unless the header checksum is $00
+	hc := byte(0x30)
+	if header(0x014D) == 0 {
+		hc = 0
+	}
+	switch model {
+	case ModelDMG0:
+		return regs{A: 0x01, F: 0x00, B: 0xFF, C: 0x13, D: 0x00, E: 0xC1, H: 0x84, L: 0x03}
+	case ModelMGB:
+		return regs{A: 0xFF, F: 0x80 | hc, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D}
+	case ModelSGB:
+		return regs{A: 0x01, F: 0x00, B: 0x00, C: 0x14, D: 0x00, E: 0x00, H: 0xC0, L: 0x60}
+	case ModelSGB2:
+		return regs{A: 0xFF, F: 0x00, B: 0x00, C: 0x14, D: 0x00, E: 0x00, H: 0xC0, L: 0x60}
+	case ModelCGB, ModelAGB:
+		r := regs{A: 0x11, F: 0x80, B: 0x00, C: 0x00, D: 0xFF, E: 0x56, H: 0x00, L: 0x0D}
+		if !cgbGame {
+			// The boot ROM hashes the title of Nintendo-licensed games to pick a palette
+			// and leaves the hash in B
+			r.D, r.E, r.H, r.L = 0x00, 0x08, 0x00, 0x7C
+			if header(0x014B) == 0x01 || (header(0x014B) == 0x33 && header(0x0144) == '0' && header(0x0145) == '1') {
+				for a := uint16(0x0134); a <= 0x0143; a++ {
+					r.B += header(a)
+				}
+			}
+			if r.B == 0x43 || r.B == 0x58 {
+				r.H, r.L = 0x99, 0x1A
+			}
+		}
+		if model == ModelAGB {
+			// The AGB boot ROM ends with an extra INC B, which games use to detect it
+			r.B++
+			r.F = 0
+			if r.B == 0 {
+				r.F |= 0x80
+			}
+			if r.B&0x0F == 0 {
+				r.F |= 0x20
+			}
+		}
+		return r
+	default: // DMG
+		return regs{A: 0x01, F: 0x80 | hc, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D}
+	}
+}
+
+// postBootDivider returns the internal 16-bit divider (DIV is its high byte) at $0100.
+// Pan Docs gives DIV for DMG0 ($18) and DMG/MGB ($AB); the SGB, CGB and AGB boot ROMs run
+// for a variable time, so these use the phase commonly observed after a typical boot.
+func postBootDivider(model Model) uint16 {
+	switch model {
+	case ModelDMG0:
+		return 0x1800
+	case ModelCGB, ModelAGB:
+		return 0x1EA0
+	default:
+		return 0xABCC
+	}
+}
+
+// applyPostBoot sets the CPU registers and divider the model's boot ROM would leave.
+func (m *Machine) applyPostBoot(model Model) {
+	if m.cpu == nil || m.bus == nil {
+		return
+	}
+	r := postBootRegs(model, m.cgbCapable && model.CGB() && !m.cgbCompat, m.bus.Cart().Read)
+	c := m.cpu
+	c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L = r.A, r.F, r.B, r.C, r.D, r.E, r.H, r.L
+	m.bus.SetDivider(postBootDivider(model))
+}
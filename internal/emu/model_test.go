@@ -0,0 +1,107 @@
+package emu
+
+import "testing"
+
+func loadModelROM(t *testing.T, model Model, cgbFlag byte) *Machine {
+	t.Helper()
+	rom := make([]byte, 0x8000)
+	copy(rom[0x134:], "TETRIS")
+	rom[0x143] = cgbFlag
+	rom[0x14B] = 0x01 // Nintendo
+	rom[0x14D] = 0x0A // header checksum (not checked)
+	m := New(Config{Model: model})
+	if err := m.LoadCartridge(rom, nil); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestModel_PostBootRegisters(t *testing.T) {
+	cases := []struct {
+		model   Model
+		cgbFlag byte
+		want    regs
+	}{
+		{ModelAuto, 0x00, regs{0x01, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D}},
+		{ModelDMG0, 0x00, regs{0x01, 0x00, 0xFF, 0x13, 0x00, 0xC1, 0x84, 0x03}},
+		{ModelMGB, 0x00, regs{0xFF, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D}},
+		{ModelSGB2, 0x00, regs{0xFF, 0x00, 0x00, 0x14, 0x00, 0x00, 0xC0, 0x60}},
+		{ModelAuto, 0x80, regs{0x11, 0x80, 0x00, 0x00, 0xFF, 0x56, 0x00, 0x0D}},
+		{ModelAGB, 0x80, regs{0x11, 0x00, 0x01, 0x00, 0xFF, 0x56, 0x00, 0x0D}},
+		// DMG game on CGB: B holds the title hash ("TETRIS" = $DB)
+		{ModelCGB, 0x00, regs{0x11, 0x80, 0xDB, 0x00, 0x00, 0x08, 0x00, 0x7C}},
+		// A color game on DMG hardware gets DMG registers and no CGB mode
+		{ModelDMG, 0xC0, regs{0x01, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D}},
+	}
+	for _, tc := range cases {
+		m := loadModelROM(t, tc.model, tc.cgbFlag)
+		c := m.cpu
+		got := regs{c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L}
+		if got != tc.want {
+			t.Errorf("%s cgb=%02X: regs %02X, want %02X", tc.model, tc.cgbFlag, got, tc.want)
+		}
+		if cgb := tc.want.A == 0x11; m.Model().CGB() != cgb {
+			t.Errorf("%s cgb=%02X: Model()=%s", tc.model, tc.cgbFlag, m.Model())
+		}
+	}
+}
+
+func TestModel_DIVPhaseAndReset(t *testing.T) {
+	m := loadModelROM(t, ModelDMG, 0x00)
+	if div := m.bus.Read(0xFF04); div != 0xAB {
+		t.Fatalf("DMG DIV at $0100 = %02X, want AB", div)
+	}
+	m.cpu.A = 0
+	m.SetModel(ModelMGB)
+	m.ResetPostBoot()
+	if m.cpu.A != 0xFF || m.cpu.PC != 0x0100 {
+		t.Fatalf("MGB reset: A=%02X PC=%04X", m.cpu.A, m.cpu.PC)
+	}
+}
+
+func TestParseModel(t *testing.T) {
+	for _, name := range modelNames {
+		m, err := ParseModel(name)
+		if err != nil || m.String() != name {
+			t.Fatalf("ParseModel(%q) = %v, %v", name, m, err)
+		}
+	}
+	if m, err := ParseModel("SGB"); err != nil || m != ModelSGB {
+		t.Fatalf("ParseModel is case-sensitive: %v %v", m, err)
+	}
+	if _, err := ParseModel("gba"); err == nil {
+		t.Fatal("ParseModel accepted an unknown model")
+	}
+}
+
+func TestCGBBoot_HandOff(t *testing.T) {
+	cases := []struct {
+		cgbFlag, key0 byte
+		compat        bool
+	}{
+		{0x00, 0x04, true},
+		{0x80, 0x80, false},
+	}
+	for _, tc := range cases {
+		boot := make([]byte, 0x900)
+		// LD A,key0; LDH [$4C],A; LD A,1; LDH [$6C],A; LD A,$11; LDH [$50],A
+		copy(boot, []byte{0x3E, tc.key0, 0xE0, 0x4C, 0x3E, 0x01, 0xE0, 0x6C, 0x3E, 0x11, 0xE0, 0x50})
+		m := loadModelROM(t, ModelAuto, tc.cgbFlag)
+		m.SetCGBBootROM(boot)
+		m.ResetWithCGBBoot()
+		if !m.UseCGBBG() {
+			t.Fatalf("cgb=%02X: CGB colors off while the boot ROM runs", tc.cgbFlag)
+		}
+		m.StepFrameNoRender()
+		if m.bus.BootROMActive() || m.IsCGBCompat() != tc.compat {
+			t.Errorf("cgb=%02X: boot active=%t compat=%t, want compat=%t",
+				tc.cgbFlag, m.bus.BootROMActive(), m.IsCGBCompat(), tc.compat)
+		}
+		if m.UseCGBBG() == tc.compat {
+			t.Errorf("cgb=%02X: UseCGBBG=%t after boot", tc.cgbFlag, m.UseCGBBG())
+		}
+		if got := m.bus.Read(0xFF6C); got != 0xFF {
+			t.Errorf("cgb=%02X: OPRI=%02X, want FF", tc.cgbFlag, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package emu
+
+import (
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cart"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/sgb"
+)
+
+// sgbGame reports whether a game asks for SGB features. Like the SGB BIOS, this wants
+// both the SGB flag ($03) and the old licensee code $33.
+func sgbGame(h *cart.Header) bool { return h.SGBFlag == 0x03 && h.OldLicensee == 0x33 }
+
+// SGBActive reports whether SGB features are on: an SGB model running an SGB game.
+func (m *Machine) SGBActive() bool { return m.bus != nil && m.bus.SGB() != nil }
+
+// FrameSize returns the size of the picture returned by Framebuffer.
+func (m *Machine) FrameSize() (w, h int) {
+	if m.SGBActive() {
+		return sgb.Width, sgb.Height
+	}
+	return 160, 144
+}
+
+// renderSGB colors the finished game frame and puts the border around it.
+func (m *Machine) renderSGB() {
+	if !m.SGBActive() {
+		return
+	}
+	if m.sgbFB == nil {
+		m.sgbFB = make([]byte, sgb.Width*sgb.Height*4)
+	}
+	m.bus.SGB().Render(m.sgbFB, m.fb)
+}
+
+// sgbTransfer completes a pending SGB VRAM transfer once a frame has been shown.
+func (m *Machine) sgbTransfer() {
+	if s := m.bus.SGB(); s != nil && s.TransferPending() {
+		s.Transfer(m.sgbScreenData())
+	}
+}
+
+// sgbScreenData returns what the SGB reads for a VRAM transfer: the tiles of the top
+// 20x13 BG map area in order, mapped through BGP as the LCD shows them. Games point
+// the map at tiles 0-255 and set BGP to $E4 so this is the raw VRAM data.
+func (m *Machine) sgbScreenData() []byte {
+	p := m.bus.PPU()
+	lcdc, bgp := p.LCDC(), p.BGP()
+	mapBase := uint16(0x9800)
+	if lcdc&0x08 != 0 {
+		mapBase = 0x9C00
+	}
+	out := make([]byte, 0, sgb.TransferSize)
+	for n := 0; n < 256; n++ {
+		idx := p.RawVRAM(mapBase + uint16(n/20)*32 + uint16(n%20))
+		addr := 0x8000 + uint16(idx)*16
+		if lcdc&0x10 == 0 {
+			addr = uint16(0x9000 + int(int8(idx))*16)
+		}
+		for row := uint16(0); row < 8; row++ {
+			lo, hi := p.RawVRAM(addr+row*2), p.RawVRAM(addr+row*2+1)
+			var nlo, nhi byte
+			for bit := 0; bit < 8; bit++ {
+				ci := (lo>>bit)&1 | (hi>>bit)&1<<1
+				sh := (bgp >> (ci * 2)) & 0x03
+				nlo |= (sh & 1) << bit
+				nhi |= (sh >> 1) << bit
+			}
+			out = append(out, nlo, nhi)
+		}
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package emu
+
+import "testing"
+
+func TestSGB_OnlyForSGBGamesOnSGBModels(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x146], rom[0x14B] = 0x03, 0x33
+	for _, tc := range []struct {
+		model Model
+		sgb   bool
+	}{{ModelSGB, true}, {ModelSGB2, true}, {ModelDMG, false}, {ModelAuto, false}} {
+		m := New(Config{Model: tc.model})
+		if err := m.LoadCartridge(rom, nil); err != nil {
+			t.Fatal(err)
+		}
+		m.StepFrame()
+		w, h := m.FrameSize()
+		if m.SGBActive() != tc.sgb || len(m.Framebuffer()) != w*h*4 {
+			t.Errorf("%s: SGB=%t frame %dx%d (%d bytes)", tc.model, m.SGBActive(), w, h, len(m.Framebuffer()))
+		}
+		if tc.sgb && w != 256 {
+			t.Errorf("%s: frame width %d, want 256", tc.model, w)
+		}
+	}
+}
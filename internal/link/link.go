@@ -0,0 +1,375 @@
+// Package link connects the serial ports of two emulator processes over TCP.
+//
+// A Conn is a bus.SerialDevice. Bytes are exchanged whole: the side driving the
+// clock sends its byte when the transfer starts and waits for the partner's byte,
+// and the partner answers from its serial port as soon as it sees it. At the end of
+// every frame both sides report their frame count and wait while the other is more
+// than one frame behind, so they stay in lockstep and agree on transfer timing.
+//
+// Messages are one type byte and a payload:
+//
+//	'X' b        transfer clocked by the sender, sending b
+//	'R' b        reply to the partner's transfer
+//	'F' n(4, BE) the sender finished frame n
+//	'P' p        the sender stopped (p=1) or resumed (p=0) stepping frames
+//
+// A side that stops stepping, e.g. while its menu is open, says so with SetPaused. Its
+// partner then runs on without waiting for frames, and its transfers read $FF as if
+// nobody were listening. When the partner disconnects or stays silent for Timeout
+// while it should be stepping, the link goes down and the serial port behaves as if
+// the cable were pulled: transfers read $FF.
+package link
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Timeout is how long a side waits for its partner before treating the link as down.
+var Timeout = 5 * time.Second
+
+const (
+	magic     = "GBL1"
+	window    = 1 // frames one side may run ahead of the other
+	msgXfer   = 'X'
+	msgReply  = 'R'
+	msgFrame  = 'F'
+	msgPause  = 'P'
+	queueSize = 256
+)
+
+// ErrTimeout is reported by Err when the partner stopped answering.
+var ErrTimeout = errors.New("link: partner timed out")
+
+type message struct {
+	kind  byte
+	value uint32
+}
+
+// Conn is one end of a link cable over TCP. Its methods other than Close, Done and Err
+// must be called from the goroutine running the emulation.
+type Conn struct {
+	conn net.Conn
+	w    *bufio.Writer
+	msgs chan message
+	done chan struct{} // closed when the link is down
+	quit chan struct{} // closed by Close to stop the reader
+
+	mu        sync.Mutex
+	err       error
+	closeOnce sync.Once
+
+	frame, peerFrame uint32
+	pending          []byte // partner transfers not answered yet
+	peerPaused       bool
+
+	// while paused, a goroutine answers the partner until resumed
+	pauseStop, pauseDone chan struct{}
+}
+
+// Listen waits for one partner to connect on addr (e.g. ":5000").
+func Listen(addr string) (*Conn, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	return Accept(ln)
+}
+
+// Accept waits for one partner on an existing listener.
+func Accept(ln net.Listener) (*Conn, error) {
+	c, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return New(c)
+}
+
+// Dial connects to a partner waiting in Listen.
+func Dial(addr string) (*Conn, error) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return New(c)
+}
+
+// New runs the handshake on an established connection and starts reading from it.
+func New(nc net.Conn) (*Conn, error) {
+	_ = nc.SetDeadline(time.Now().Add(Timeout))
+	if _, err := io.WriteString(nc, magic); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	var hello [len(magic)]byte
+	if _, err := io.ReadFull(nc, hello[:]); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	if string(hello[:]) != magic {
+		nc.Close()
+		return nil, fmt.Errorf("link: unexpected handshake %q", hello[:])
+	}
+	_ = nc.SetDeadline(time.Time{})
+	c := &Conn{
+		conn: nc,
+		w:    bufio.NewWriter(nc),
+		msgs: make(chan message, queueSize),
+		done: make(chan struct{}),
+		quit: make(chan struct{}),
+	}
+	go c.read(bufio.NewReader(nc))
+	return c, nil
+}
+
+func (c *Conn) read(r *bufio.Reader) {
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		select {
+		case c.msgs <- m:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+func readMessage(r *bufio.Reader) (message, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return message{}, err
+	}
+	switch kind {
+	case msgXfer, msgReply, msgPause:
+		v, err := r.ReadByte()
+		return message{kind, uint32(v)}, err
+	case msgFrame:
+		var n [4]byte
+		_, err := io.ReadFull(r, n[:])
+		return message{kind, binary.BigEndian.Uint32(n[:])}, err
+	}
+	return message{}, fmt.Errorf("link: bad message type %#x", kind)
+}
+
+func (c *Conn) send(kind byte, v uint32) {
+	if c.down() {
+		return
+	}
+	_ = c.w.WriteByte(kind)
+	if kind == msgFrame {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], v)
+		_, _ = c.w.Write(n[:])
+	} else {
+		_ = c.w.WriteByte(byte(v))
+	}
+	if err := c.w.Flush(); err != nil {
+		c.fail(err)
+	}
+}
+
+// fail records why the link went down and closes it.
+func (c *Conn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.closeOnce.Do(func() {
+		close(c.done)
+		close(c.quit)
+		c.conn.Close()
+	})
+}
+
+// Close disconnects from the partner.
+func (c *Conn) Close() error {
+	c.fail(net.ErrClosed)
+	return nil
+}
+
+// Done is closed once the link is down.
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+// Err returns why the link went down: io.EOF when the partner disconnected,
+// ErrTimeout when it stopped answering, or nil while it is up.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Conn) down() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// handle records a frame report or a partner transfer; replies are returned to the caller.
+func (c *Conn) handle(m message) {
+	switch m.kind {
+	case msgFrame:
+		c.peerFrame = m.value
+	case msgXfer:
+		c.pending = append(c.pending, byte(m.value))
+	case msgPause:
+		c.peerPaused = m.value != 0
+	}
+}
+
+// answer replies to the partner's pending transfers with receive.
+func (c *Conn) answer(receive func(in byte) byte) {
+	for _, in := range c.pending {
+		c.send(msgReply, uint32(receive(in)))
+	}
+	c.pending = c.pending[:0]
+}
+
+// notListening is the receive function of a side that is not stepping.
+func notListening(byte) byte { return 0xFF }
+
+// poll handles every message already received without blocking.
+func (c *Conn) poll() {
+	for {
+		select {
+		case m := <-c.msgs:
+			c.handle(m)
+		default:
+			return
+		}
+	}
+}
+
+// wait blocks for the next message; false means the link is down.
+func (c *Conn) wait() (message, bool) {
+	t := time.NewTimer(Timeout)
+	defer t.Stop()
+	select {
+	case m := <-c.msgs:
+		return m, true
+	case <-c.done:
+		return message{}, false
+	case <-t.C:
+		c.fail(ErrTimeout)
+		return message{}, false
+	}
+}
+
+// Ready implements bus.SerialDevice: it reports whether the link is up.
+func (c *Conn) Ready() bool { return !c.down() }
+
+// Exchange implements bus.SerialDevice: it sends out and waits for the partner's byte.
+func (c *Conn) Exchange(out byte) byte {
+	// Transfers the partner clocked while this side was not listening read $FF
+	c.poll()
+	c.answer(notListening)
+	if c.peerPaused {
+		return 0xFF
+	}
+	c.send(msgXfer, uint32(out))
+	for {
+		m, ok := c.wait()
+		if !ok {
+			return 0xFF
+		}
+		switch m.kind {
+		case msgReply:
+			return byte(m.value)
+		case msgXfer:
+			// Both sides drive the clock at once: neither one listens
+			c.send(msgReply, 0xFF)
+		default:
+			c.handle(m)
+		}
+	}
+}
+
+// Poll implements bus.SerialDevice: it passes a byte the partner clocked, if any, to
+// receive and sends back the answer.
+func (c *Conn) Poll(receive func(in byte) byte) {
+	c.poll()
+	if len(c.pending) == 0 {
+		return
+	}
+	in := c.pending[0]
+	c.pending = c.pending[1:]
+	c.send(msgReply, uint32(receive(in)))
+}
+
+// EndFrame reports the finished frame and waits while the partner is too far behind.
+// Transfers the partner clocks meanwhile go to receive, which returns the byte to
+// send back (see bus.Bus.SerialReceive).
+func (c *Conn) EndFrame(receive func(in byte) byte) {
+	if c.down() {
+		return
+	}
+	c.poll()
+	if c.peerPaused {
+		// The frame count stays put, so both sides line up again on resume
+		c.answer(receive)
+		return
+	}
+	c.frame++
+	c.send(msgFrame, c.frame)
+	for {
+		c.poll()
+		c.answer(receive)
+		if c.peerFrame+window >= c.frame || c.peerPaused {
+			return
+		}
+		m, ok := c.wait()
+		if !ok {
+			return
+		}
+		c.handle(m)
+	}
+}
+
+// SetPaused tells the partner that this side stopped or resumed stepping frames. While
+// paused, the partner does not wait for frames and transfers it clocks read $FF; the
+// link stays up however long the pause lasts.
+func (c *Conn) SetPaused(paused bool) {
+	if paused == (c.pauseStop != nil) || c.down() {
+		return
+	}
+	if !paused {
+		close(c.pauseStop)
+		<-c.pauseDone
+		c.pauseStop, c.pauseDone = nil, nil
+		c.send(msgPause, 0)
+		return
+	}
+	c.send(msgPause, 1)
+	c.pauseStop, c.pauseDone = make(chan struct{}), make(chan struct{})
+	go c.whilePaused(c.pauseStop, c.pauseDone)
+}
+
+// whilePaused answers the partner's transfers in place of the emulation until stop is
+// closed, so every transfer gets exactly one reply.
+func (c *Conn) whilePaused(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		c.answer(notListening)
+		select {
+		case m := <-c.msgs:
+			c.handle(m)
+		case <-stop:
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
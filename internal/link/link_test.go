@@ -0,0 +1,177 @@
+package link
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/bus"
+)
+
+// pair connects two Conns over loopback.
+func pair(t *testing.T) (*Conn, *Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan *Conn, 1)
+	go func() {
+		c, err := Accept(ln)
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+	a, err := Dial(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := <-accepted
+	if b == nil {
+		t.FailNow()
+	}
+	t.Cleanup(func() { a.Close(); b.Close() })
+	return a, b
+}
+
+func TestConn_ExchangesBytesBetweenBuses(t *testing.T) {
+	a, b := pair(t)
+	master, slave := bus.New(make([]byte, 0x8000)), bus.New(make([]byte, 0x8000))
+	master.SetSerialDevice(a)
+	slave.SetSerialDevice(b)
+	slave.Write(0xFF01, 0x99)
+	slave.Write(0xFF02, 0x80)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for end := time.Now().Add(2 * time.Second); slave.Read(0xFF02)&0x80 != 0 && time.Now().Before(end); {
+			slave.Tick(4)
+		}
+	}()
+	master.Write(0xFF01, 0x42)
+	master.Write(0xFF02, 0x81) // waits for the slave's byte
+	master.Tick(8 * 512)
+	<-done
+	if got := master.Read(0xFF01); got != 0x99 {
+		t.Errorf("master received %02X, want 99", got)
+	}
+	if got := slave.Read(0xFF01); got != 0x42 {
+		t.Errorf("slave received %02X, want 42", got)
+	}
+	if master.Read(0xFF02)&0x80 != 0 || slave.Read(0xFF02)&0x80 != 0 {
+		t.Errorf("transfer still running")
+	}
+}
+
+func TestConn_Lockstep(t *testing.T) {
+	a, b := pair(t)
+	noSerial := func(byte) byte { return 0xFF }
+	a.EndFrame(noSerial) // one frame ahead is allowed
+	returned := make(chan struct{})
+	go func() {
+		a.EndFrame(noSerial)
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("ran two frames ahead of the partner")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.EndFrame(noSerial)
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("still waiting after the partner caught up")
+	}
+}
+
+func TestConn_Disconnect(t *testing.T) {
+	a, b := pair(t)
+	a.Close()
+	select {
+	case <-b.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("disconnect not noticed")
+	}
+	if b.Err() != io.EOF {
+		t.Errorf("Err() = %v, want EOF", b.Err())
+	}
+	if b.Ready() {
+		t.Errorf("Ready after disconnect")
+	}
+	if in := b.Exchange(0x12); in != 0xFF {
+		t.Errorf("Exchange after disconnect = %02X, want FF", in)
+	}
+	b.EndFrame(func(byte) byte { return 0xFF }) // must not block
+}
+
+func TestConn_PauseOutlastsTimeout(t *testing.T) {
+	defer func(old time.Duration) { Timeout = old }(Timeout)
+	Timeout = 100 * time.Millisecond
+	a, b := pair(t)
+	noSerial := func(byte) byte { return 0xFF }
+	a.EndFrame(noSerial)
+	b.EndFrame(noSerial)
+
+	a.SetPaused(true)
+	start := time.Now()
+	for time.Since(start) < 3*Timeout {
+		b.EndFrame(noSerial) // must not wait for the paused side
+	}
+	if in := b.Exchange(0x12); in != 0xFF {
+		t.Errorf("Exchange with a paused partner = %02X, want FF", in)
+	}
+	if !a.Ready() || !b.Ready() {
+		t.Fatalf("link dropped during the pause: %v / %v", a.Err(), b.Err())
+	}
+
+	// Resumed, the two sides are in lockstep again: a cannot run far ahead of b
+	a.SetPaused(false)
+	returned := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			a.EndFrame(noSerial)
+		}
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("ran five frames ahead after resuming")
+	case <-time.After(Timeout / 4):
+	}
+	for end := time.Now().Add(2 * time.Second); ; {
+		b.EndFrame(noSerial)
+		select {
+		case <-returned:
+		default:
+			if time.Now().Before(end) {
+				continue
+			}
+			t.Fatal("still waiting after the partner caught up")
+		}
+		break
+	}
+	if !a.Ready() || !b.Ready() {
+		t.Fatalf("link dropped after resuming: %v / %v", a.Err(), b.Err())
+	}
+}
+
+func TestConn_PausedSideAnswersTransfers(t *testing.T) {
+	a, b := pair(t)
+	a.SetPaused(true)
+	// b may not have seen the pause yet; either way its transfer is answered
+	done := make(chan byte)
+	go func() { done <- b.Exchange(0x34) }()
+	select {
+	case in := <-done:
+		if in != 0xFF {
+			t.Errorf("Exchange = %02X, want FF", in)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("transfer to a paused side never answered")
+	}
+	a.SetPaused(false)
+}
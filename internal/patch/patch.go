@@ -0,0 +1,328 @@
+// Package patch applies IPS, BPS and UPS soft-patches to ROM images at load time.
+// The format is detected from the patch's magic bytes. BPS and UPS carry CRC32s of the
+// source, target and patch, which are all checked; IPS has no checksums.
+package patch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/romfile"
+)
+
+var (
+	ErrUnknownFormat = errors.New("patch: unknown format (want IPS, BPS or UPS)")
+	ErrCorrupt       = errors.New("patch: truncated or corrupt")
+	ErrPatchCRC      = errors.New("patch: patch file checksum mismatch")
+	ErrSourceCRC     = errors.New("patch: ROM does not match the patch's source checksum")
+	ErrTargetCRC     = errors.New("patch: patched ROM does not match the target checksum")
+)
+
+// Extensions lists the patch file extensions Find looks for, in order of preference.
+var Extensions = []string{".bps", ".ups", ".ips"}
+
+// Apply patches rom and returns the result; rom itself is left untouched.
+func Apply(rom, p []byte) ([]byte, error) {
+	switch {
+	case len(p) >= 5 && string(p[:5]) == "PATCH":
+		return applyIPS(rom, p)
+	case len(p) >= 4 && string(p[:4]) == "BPS1":
+		return applyBPS(rom, p)
+	case len(p) >= 4 && string(p[:4]) == "UPS1":
+		return applyUPS(rom, p)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// ApplyFile reads a patch from disk and applies it to rom.
+func ApplyFile(rom []byte, path string) ([]byte, error) {
+	p, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := Apply(rom, p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return out, nil
+}
+
+// Find returns the patch that sits next to a ROM or ROM archive, trying "<rom>.bps",
+// "<rom>.ups" and "<rom>.ips" both with the ROM's own extension dropped ("game.ips")
+// and kept ("game.gb.ips"). It returns "" when there is none.
+func Find(romPath string) string {
+	return romfile.FindSibling(romPath, Extensions...)
+}
+
+// applyIPS handles "PATCH" records (3-byte offset, 2-byte size, data; size 0 is an RLE run)
+// up to "EOF", optionally followed by a 3-byte truncation length.
+func applyIPS(rom, p []byte) ([]byte, error) {
+	out := append([]byte(nil), rom...)
+	i := 5
+	for {
+		if i+3 > len(p) {
+			return nil, ErrCorrupt
+		}
+		if string(p[i:i+3]) == "EOF" {
+			i += 3
+			break
+		}
+		off := int(p[i])<<16 | int(p[i+1])<<8 | int(p[i+2])
+		i += 3
+		if i+2 > len(p) {
+			return nil, ErrCorrupt
+		}
+		size := int(binary.BigEndian.Uint16(p[i:]))
+		i += 2
+		if size == 0 {
+			if i+3 > len(p) {
+				return nil, ErrCorrupt
+			}
+			run := int(binary.BigEndian.Uint16(p[i:]))
+			v := p[i+2]
+			i += 3
+			out = grow(out, off+run)
+			for k := 0; k < run; k++ {
+				out[off+k] = v
+			}
+			continue
+		}
+		if i+size > len(p) {
+			return nil, ErrCorrupt
+		}
+		out = grow(out, off+size)
+		copy(out[off:], p[i:i+size])
+		i += size
+	}
+	if i+3 <= len(p) {
+		if n := int(p[i])<<16 | int(p[i+1])<<8 | int(p[i+2]); n < len(out) {
+			out = out[:n]
+		}
+	}
+	return out, nil
+}
+
+func grow(b []byte, n int) []byte {
+	if n <= len(b) {
+		return b
+	}
+	return append(b, make([]byte, n-len(b))...)
+}
+
+// beatReader decodes the variable-length integers shared by BPS and UPS.
+type beatReader struct {
+	p   []byte
+	pos int
+	end int // start of the 12-byte checksum footer
+}
+
+func (r *beatReader) byte() (byte, error) {
+	if r.pos >= r.end {
+		return 0, ErrCorrupt
+	}
+	b := r.p[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// varint reads one number. Eight bytes already encode more than 2^56, far beyond any
+// size or offset in a ROM patch, so longer numbers are rejected before they overflow.
+func (r *beatReader) varint() (int, error) {
+	data, shift := 0, 1
+	for n := 0; n < 8; n++ {
+		x, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		data += int(x&0x7F) * shift
+		if data < 0 {
+			return 0, ErrCorrupt
+		}
+		if x&0x80 != 0 {
+			return data, nil
+		}
+		shift <<= 7
+		data += shift
+	}
+	return 0, ErrCorrupt
+}
+
+// footer checks the patch CRC and returns the source and target CRCs.
+func footer(p []byte) (src, dst uint32, err error) {
+	if len(p) < 4+12 {
+		return 0, 0, ErrCorrupt
+	}
+	f := p[len(p)-12:]
+	if crc32.ChecksumIEEE(p[:len(p)-4]) != binary.LittleEndian.Uint32(f[8:]) {
+		return 0, 0, ErrPatchCRC
+	}
+	return binary.LittleEndian.Uint32(f[0:]), binary.LittleEndian.Uint32(f[4:]), nil
+}
+
+// applyUPS handles "UPS1": source and target sizes, then hunks of a skip count followed by
+// bytes XORed onto the source up to and including a 0 terminator.
+func applyUPS(rom, p []byte) ([]byte, error) {
+	srcCRC, dstCRC, err := footer(p)
+	if err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(rom) != srcCRC {
+		return nil, ErrSourceCRC
+	}
+	r := &beatReader{p: p, pos: 4, end: len(p) - 12}
+	srcSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	dstSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != len(rom) {
+		return nil, ErrSourceCRC
+	}
+	if dstSize > romfile.MaxROMSize {
+		return nil, ErrCorrupt
+	}
+	out := make([]byte, dstSize)
+	copy(out, rom)
+	pos := 0
+	for r.pos < r.end {
+		skip, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		pos += skip
+		for {
+			x, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if pos < len(out) {
+				out[pos] ^= x
+			}
+			pos++
+			if x == 0 {
+				break
+			}
+		}
+	}
+	if crc32.ChecksumIEEE(out) != dstCRC {
+		return nil, ErrTargetCRC
+	}
+	return out, nil
+}
+
+// BPS actions, encoded in the low two bits of each command.
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// applyBPS handles "BPS1": sizes and metadata, then SourceRead/TargetRead/SourceCopy/TargetCopy
+// actions that build the target front to back.
+func applyBPS(rom, p []byte) ([]byte, error) {
+	srcCRC, dstCRC, err := footer(p)
+	if err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(rom) != srcCRC {
+		return nil, ErrSourceCRC
+	}
+	r := &beatReader{p: p, pos: 4, end: len(p) - 12}
+	srcSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	dstSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	metaSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != len(rom) {
+		return nil, ErrSourceCRC
+	}
+	if dstSize > romfile.MaxROMSize || metaSize > r.end-r.pos {
+		return nil, ErrCorrupt
+	}
+	r.pos += metaSize
+	out := make([]byte, dstSize)
+	outPos, srcRel, dstRel := 0, 0, 0
+	// relative reads a signed offset: bit 0 is the sign, the rest the magnitude
+	relative := func() (int, error) {
+		d, err := r.varint()
+		if err != nil {
+			return 0, err
+		}
+		if d&1 != 0 {
+			return -(d >> 1), nil
+		}
+		return d >> 1, nil
+	}
+	for r.pos < r.end {
+		data, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		length := (data >> 2) + 1
+		if outPos+length > len(out) {
+			return nil, ErrCorrupt
+		}
+		switch data & 3 {
+		case bpsSourceRead:
+			if outPos+length > len(rom) {
+				return nil, ErrCorrupt
+			}
+			copy(out[outPos:], rom[outPos:outPos+length])
+			outPos += length
+		case bpsTargetRead:
+			if r.pos+length > r.end {
+				return nil, ErrCorrupt
+			}
+			copy(out[outPos:], p[r.pos:r.pos+length])
+			r.pos += length
+			outPos += length
+		case bpsSourceCopy:
+			d, err := relative()
+			if err != nil {
+				return nil, err
+			}
+			srcRel += d
+			if srcRel < 0 || srcRel+length > len(rom) {
+				return nil, ErrCorrupt
+			}
+			copy(out[outPos:], rom[srcRel:srcRel+length])
+			srcRel += length
+			outPos += length
+		case bpsTargetCopy:
+			d, err := relative()
+			if err != nil {
+				return nil, err
+			}
+			dstRel += d
+			if dstRel < 0 || dstRel >= outPos {
+				return nil, ErrCorrupt
+			}
+			// Byte by byte: the copy may overlap the bytes it is producing
+			for k := 0; k < length; k++ {
+				out[outPos] = out[dstRel]
+				outPos++
+				dstRel++
+			}
+		}
+	}
+	if crc32.ChecksumIEEE(out) != dstCRC {
+		return nil, ErrTargetCRC
+	}
+	return out, nil
+}
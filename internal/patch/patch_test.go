@@ -0,0 +1,146 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func putVarint(b []byte, v int) []byte {
+	for {
+		x := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(b, x|0x80)
+		}
+		b = append(b, x)
+		v--
+	}
+}
+
+// withFooter appends source, target and patch CRCs.
+func withFooter(p, src, dst []byte) []byte {
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(src))
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(dst))
+	return binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(p))
+}
+
+func TestIPS_RecordsRLEAndGrow(t *testing.T) {
+	rom := []byte{0, 1, 2, 3, 4, 5}
+	p := []byte("PATCH")
+	p = append(p, 0, 0, 1, 0, 2, 0xAA, 0xBB) // offset 1: AA BB
+	p = append(p, 0, 0, 6, 0, 0, 0, 3, 0xCC) // offset 6: RLE 3x CC (grows the ROM)
+	p = append(p, 'E', 'O', 'F')
+	out, err := Apply(rom, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 0xAA, 0xBB, 3, 4, 5, 0xCC, 0xCC, 0xCC}
+	if !bytes.Equal(out, want) {
+		t.Fatalf("IPS got % X want % X", out, want)
+	}
+	if rom[1] != 1 {
+		t.Fatalf("source ROM modified")
+	}
+}
+
+func TestUPS_XorHunksAndCRCs(t *testing.T) {
+	src := []byte{0x10, 0x20, 0x30, 0x40}
+	dst := []byte{0x10, 0x21, 0x30, 0x40, 0x50}
+	p := []byte("UPS1")
+	p = putVarint(p, len(src))
+	p = putVarint(p, len(dst))
+	p = putVarint(p, 1)            // skip 1
+	p = append(p, 0x20^0x21, 0x00) // xor byte 1, terminator covers byte 2
+	p = putVarint(p, 1)            // skip byte 3
+	p = append(p, 0x50, 0x00)      // byte 4 (past the source: 0 ^ 0x50)
+	p = withFooter(p, src, dst)
+	out, err := Apply(src, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, dst) {
+		t.Fatalf("UPS got % X want % X", out, dst)
+	}
+	if _, err := Apply([]byte{9, 9, 9, 9}, p); err != ErrSourceCRC {
+		t.Fatalf("wrong source got %v want ErrSourceCRC", err)
+	}
+	bad := append([]byte(nil), p...)
+	bad[6] ^= 0xFF
+	if _, err := Apply(src, bad); err != ErrPatchCRC {
+		t.Fatalf("corrupt patch got %v want ErrPatchCRC", err)
+	}
+}
+
+func TestBPS_AllActions(t *testing.T) {
+	src := []byte("ABCDEFGH")
+	dst := []byte("ABCDxyxyxEFG")
+	p := []byte("BPS1")
+	p = putVarint(p, len(src))
+	p = putVarint(p, len(dst))
+	p = putVarint(p, 0)                      // no metadata
+	p = putVarint(p, (4-1)<<2|bpsSourceRead) // ABCD
+	p = putVarint(p, (2-1)<<2|bpsTargetRead) // xy
+	p = append(p, 'x', 'y')
+	p = putVarint(p, (3-1)<<2|bpsTargetCopy) // xyx from target offset 4 (overlapping)
+	p = putVarint(p, 4<<1)
+	p = putVarint(p, (3-1)<<2|bpsSourceCopy) // EFG from source offset 4
+	p = putVarint(p, 4<<1)
+	good := withFooter(append([]byte(nil), p...), src, dst)
+	out, err := Apply(src, good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, dst) {
+		t.Fatalf("BPS got %q want %q", out, dst)
+	}
+	wrongTarget := withFooter(append([]byte(nil), p...), src, []byte("nope"))
+	if _, err := Apply(src, wrongTarget); err != ErrTargetCRC {
+		t.Fatalf("target mismatch got %v want ErrTargetCRC", err)
+	}
+}
+
+func TestBeat_CorruptSizes(t *testing.T) {
+	src := []byte("ABCD")
+	// A 9-byte target size used to overflow into a negative length
+	long := bytes.Repeat([]byte{0x7F}, 8)
+	long = append(long, 0x81)
+	cases := map[string][]byte{
+		"bps overflow": append(putVarint([]byte("BPS1"), len(src)), long...),
+		"ups overflow": append(putVarint([]byte("UPS1"), len(src)), long...),
+		"bps too big":  putVarint(putVarint([]byte("BPS1"), len(src)), 1<<30),
+		"ups too big":  putVarint(putVarint([]byte("UPS1"), len(src)), 1<<30),
+		"bps metadata": putVarint(putVarint(putVarint([]byte("BPS1"), len(src)), len(src)), 1000),
+	}
+	for name, p := range cases {
+		p = putVarint(p, 0)
+		if _, err := Apply(src, withFooter(p, src, src)); err != ErrCorrupt {
+			t.Errorf("%s: got %v want ErrCorrupt", name, err)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "game.gb.zip")
+	if got := Find(rom); got != "" {
+		t.Fatalf("Find without patch got %q", got)
+	}
+	ips := filepath.Join(dir, "game.ips")
+	if err := os.WriteFile(ips, []byte("PATCHEOF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Find(rom); got != ips {
+		t.Fatalf("Find got %q want %q", got, ips)
+	}
+	bps := filepath.Join(dir, "game.bps")
+	if err := os.WriteFile(bps, []byte("BPS1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Find(filepath.Join(dir, "game.gb")); got != bps {
+		t.Fatalf("Find preference got %q want %q", got, bps)
+	}
+}
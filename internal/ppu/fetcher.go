@@ -1,7 +1,7 @@
 package ppu
 
-// Minimal, isolated BG fetcher + FIFO used for future PPU refactor.
-// This is not wired into rendering yet; unit tests validate behavior.
+// BG/window fetcher + pixel FIFO. The scanline helpers drive the fetcher one whole tile at a
+// time; the PPU's pixel pipeline (pipeline.go) steps it dot by dot during mode 3.
 
 // VRAMReader provides read-only access for the fetcher or scanline helpers.
 // It abstracts how VRAM bytes are fetched (tests vs. live PPU).
@@ -15,9 +15,18 @@ type VRAMBankedReader interface {
 	ReadBank(bank int, addr uint16) byte
 }
 
-// fifo is a simple ring buffer for 2-bit color indices (0..3).
+// pixel is one FIFO entry: a 2-bit color index plus the attributes needed to mix BG and OBJ.
+type pixel struct {
+	ci   byte // color index 0..3 (0 is transparent for OBJ)
+	pal  byte // CGB palette 0..7; DMG OBJ: 0=OBP0, 1=OBP1
+	pri  bool // BG: CGB attribute bit7; OBJ: attribute bit7 (behind BG colors 1-3)
+	obp1 bool // OBJ: DMG palette select (attribute bit4), kept for CGB fallback shading
+	oam  byte // OBJ: OAM index, used for CGB priority
+}
+
+// fifo is a simple ring buffer of pixels (BG or OBJ).
 type fifo struct {
-	buf  [32]byte // room for several tiles
+	buf  [32]pixel // room for several tiles
 	head int
 	tail int
 	size int
@@ -25,18 +34,30 @@ type fifo struct {
 
 func (q *fifo) Clear()   { q.head, q.tail, q.size = 0, 0, 0 }
 func (q *fifo) Len() int { return q.size }
-func (q *fifo) Push(ci byte) bool {
+
+// Push appends a bare color index (no attributes).
+func (q *fifo) Push(ci byte) bool { return q.PushPixel(pixel{ci: ci}) }
+
+// Pop removes the next pixel and returns only its color index.
+func (q *fifo) Pop() (byte, bool) {
+	px, ok := q.PopPixel()
+	return px.ci, ok
+}
+
+func (q *fifo) PushPixel(px pixel) bool {
 	if q.size == len(q.buf) {
 		return false
 	}
-	q.buf[q.tail] = ci & 0x03
+	px.ci &= 0x03
+	q.buf[q.tail] = px
 	q.tail = (q.tail + 1) % len(q.buf)
 	q.size++
 	return true
 }
-func (q *fifo) Pop() (byte, bool) {
+
+func (q *fifo) PopPixel() (pixel, bool) {
 	if q.size == 0 {
-		return 0, false
+		return pixel{}, false
 	}
 	v := q.buf[q.head]
 	q.head = (q.head + 1) % len(q.buf)
@@ -44,6 +65,9 @@ func (q *fifo) Pop() (byte, bool) {
 	return v, true
 }
 
+// At returns the i-th queued pixel (0 = next out) for in-place merging of OBJ rows.
+func (q *fifo) At(i int) *pixel { return &q.buf[(q.head+i)%len(q.buf)] }
+
 // bgFetcher pulls one tile row (8 pixels) into the FIFO.
 type bgFetcher struct {
 	mem           VRAMReader
@@ -52,6 +76,15 @@ type bgFetcher struct {
 	tileData8000  bool   // true: 0x8000 addressing; false: 0x8800 signed
 	tileIndexAddr uint16 // tile index address within map
 	fineY         byte   // 0..7 within tile
+
+	// banked gives access to the CGB attribute map and VRAM bank 1; nil fetches plain DMG tiles.
+	banked VRAMBankedReader
+
+	// Dot-stepped fetch state (see Step)
+	ticks   int // dots spent on the current tile row (0..6)
+	tileNum byte
+	attr    byte
+	lo, hi  byte
 }
 
 func newBGFetcher(mem VRAMReader, f *fifo) *bgFetcher { return &bgFetcher{mem: mem, fifo: f} }
@@ -66,18 +99,85 @@ func (fch *bgFetcher) Configure(mapBase uint16, tileData8000 bool, tileIndexAddr
 
 // Fetch pushes 8 pixels (color indices) for the current tile row to the FIFO.
 func (fch *bgFetcher) Fetch() {
-	tileNum := fch.mem.Read(fch.tileIndexAddr)
-	var base uint16
+	fch.readTile()
+	fch.readData(false)
+	fch.readData(true)
+	fch.push()
+}
+
+// Step advances the fetcher by one dot. Reading the tile number, data low and data high
+// takes two dots each; the row is then pushed as soon as the FIFO is empty.
+// It returns true on the dot the row is pushed.
+func (fch *bgFetcher) Step() bool {
+	if fch.ticks < 6 {
+		fch.ticks++
+		switch fch.ticks {
+		case 2:
+			fch.readTile()
+		case 4:
+			fch.readData(false)
+		case 6:
+			fch.readData(true)
+		}
+		if fch.ticks < 6 {
+			return false
+		}
+	}
+	if fch.fifo.Len() != 0 {
+		return false
+	}
+	fch.push()
+	fch.ticks = 0
+	return true
+}
+
+func (fch *bgFetcher) readTile() {
+	fch.tileNum = fch.mem.Read(fch.tileIndexAddr)
+	fch.attr = 0
+	if fch.banked != nil {
+		fch.attr = fch.banked.ReadBank(1, fch.tileIndexAddr)
+	}
+}
+
+func (fch *bgFetcher) readData(high bool) {
+	row := fch.fineY
+	if (fch.attr & (1 << 6)) != 0 { // CGB Y flip
+		row = 7 - row
+	}
+	var addr uint16
 	if fch.tileData8000 {
-		base = 0x8000 + uint16(tileNum)*16 + uint16(fch.fineY)*2
+		addr = 0x8000 + uint16(fch.tileNum)*16 + uint16(row)*2
 	} else {
-		base = 0x9000 + uint16(int8(tileNum))*16 + uint16(fch.fineY)*2
+		addr = 0x9000 + uint16(int8(fch.tileNum))*16 + uint16(row)*2
 	}
-	lo := fch.mem.Read(base)
-	hi := fch.mem.Read(base + 1)
+	if high {
+		addr++
+	}
+	var v byte
+	if fch.banked != nil {
+		bank := 0
+		if (fch.attr & (1 << 3)) != 0 {
+			bank = 1
+		}
+		v = fch.banked.ReadBank(bank, addr)
+	} else {
+		v = fch.mem.Read(addr)
+	}
+	if high {
+		fch.hi = v
+	} else {
+		fch.lo = v
+	}
+}
+
+func (fch *bgFetcher) push() {
+	xflip := (fch.attr & (1 << 5)) != 0
 	for px := 0; px < 8; px++ {
 		bit := 7 - byte(px)
-		ci := ((hi>>bit)&1)<<1 | ((lo >> bit) & 1)
-		_ = fch.fifo.Push(ci)
+		if xflip {
+			bit = byte(px)
+		}
+		ci := ((fch.hi>>bit)&1)<<1 | ((fch.lo >> bit) & 1)
+		_ = fch.fifo.PushPixel(pixel{ci: ci, pal: fch.attr & 0x07, pri: (fch.attr & (1 << 7)) != 0})
 	}
 }
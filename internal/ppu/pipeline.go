@@ -0,0 +1,357 @@
+package ppu
+
+// Dot-driven pixel pipeline.
+//
+// Mode 2 scans one OAM entry every two dots and keeps up to 10 objects for the line.
+// Mode 3 steps the BG/window fetcher one dot at a time and pops one pixel per dot from the
+// BG FIFO, mixing it with the OBJ FIFO straight into the framebuffer. Registers are read when
+// the hardware reads them, so mid-scanline writes (SCX, palettes, LCDC, WX) show up on screen.
+//
+// Timing per line: a dummy tile fetch (6 dots) is thrown away, the first real tile takes another
+// 6 dots, then pixels flow at one per dot. SCX%8 pixels are dropped at the start, the window
+// restarts the fetcher (6 dots) and each object stalls the FIFO for its fetch.
+
+// objEntry is an object selected during the OAM scan for the current line.
+type objEntry struct {
+	y, x  byte // raw OAM Y/X (screen position +16/+8)
+	tile  byte
+	attr  byte
+	index byte // OAM index 0..39
+	done  bool // already fetched on this line
+}
+
+// pipeline holds the pixel FIFO state of the current line plus the framebuffers.
+type pipeline struct {
+	bg    fifo
+	obj   fifo
+	fetch bgFetcher
+
+	lx      int  // next screen X to output (0..160)
+	discard int  // pixels still to drop (SCX fine scroll, or WX<7)
+	fetchX  byte // tile column of the next fetch (relative to SCX or window start)
+	warm    bool // dummy first fetch of the line is done
+	window  bool // fetcher switched to the window on this line
+	wyHit   bool // LY matched WY with the window enabled during this frame
+	winY    byte // window line counter; advances only on lines that drew the window
+
+	objs     [10]objEntry
+	nobj     int
+	objFetch bool // an object fetch is stalling the pixel output
+	objWait  bool // waiting for the BG fetcher to finish its tile first
+	objTicks int
+	objCur   int
+
+	back  [160 * 144 * 4]byte // frame being drawn
+	front [160 * 144 * 4]byte // last completed frame
+}
+
+// vramBus adapts PPU VRAM to the fetcher's reader interfaces.
+type vramBus struct{ p *PPU }
+
+func (v vramBus) Read(addr uint16) byte { return v.p.RawVRAM(addr) }
+func (v vramBus) ReadBank(bank int, addr uint16) byte {
+	return v.p.RawVRAMBank(bank, addr)
+}
+
+// SetColorMode selects how the pixel pipeline resolves colors and OBJ priority:
+// native CGB (CRAM palettes, OAM-order priority), DMG-on-CGB compatibility
+// (BGP/OBP index CRAM palettes 0/1) or plain DMG shades when both are false.
+// Native CGB mode also enables BG attribute fetches, OBJ VRAM banks and the window's
+// independence from LCDC.0; in compatibility mode these behave as on a DMG.
+func (p *PPU) SetColorMode(cgb, compat bool) {
+	p.cgb = cgb
+	p.compat = compat && !cgb
+}
+
+// Framebuffer returns the last frame completed by the pixel pipeline (RGBA 160x144).
+func (p *PPU) Framebuffer() []byte { return p.pipe.front[:] }
+
+// clearFramebuffer paints both buffers white (LCD off).
+func (p *PPU) clearFramebuffer() {
+	for i := range p.pipe.back {
+		p.pipe.back[i] = 0xFF
+	}
+	p.pipe.front = p.pipe.back
+}
+
+// oamScan evaluates OAM entry i (0..39); called every other dot of mode 2.
+func (p *PPU) oamScan(i int) {
+	pp := &p.pipe
+	if i == 0 {
+		pp.nobj = 0
+	}
+	if pp.nobj >= len(pp.objs) {
+		return
+	}
+	h := 8
+	if (p.lcdc & 0x04) != 0 {
+		h = 16
+	}
+	y := p.oam[i*4]
+	line := int(p.ly) + 16
+	if line >= int(y) && line < int(y)+h {
+		pp.objs[pp.nobj] = objEntry{y: y, x: p.oam[i*4+1], tile: p.oam[i*4+2], attr: p.oam[i*4+3], index: byte(i)}
+		pp.nobj++
+	}
+}
+
+// startLine resets the pipeline as mode 3 begins.
+func (p *PPU) startLine() {
+	pp := &p.pipe
+	pp.bg.Clear()
+	pp.obj.Clear()
+	pp.lx = 0
+	pp.discard = int(p.scx & 7)
+	pp.fetchX = 0
+	pp.warm = false
+	pp.window = false
+	pp.objFetch = false
+	pp.fetch.ticks = 0
+	pp.fetch.mem = vramBus{p}
+	pp.fetch.fifo = &pp.bg
+	pp.fetch.banked = nil
+	if p.cgb {
+		pp.fetch.banked = vramBus{p}
+	}
+	for i := 0; i < pp.nobj; i++ {
+		pp.objs[i].done = false
+	}
+}
+
+// finishLine runs when mode 3 ends. Mode 3 normally ends once all 160 pixels are out;
+// if it is cut short (e.g. a savestate restored mid-line) the remaining pixels are drained.
+func (p *PPU) finishLine() {
+	pp := &p.pipe
+	for n := 0; pp.lx < 160 && n < 456; n++ {
+		p.pipelineDot()
+	}
+	if pp.window {
+		pp.winY++
+	}
+}
+
+// pipelineDot advances mode 3 by one dot.
+func (p *PPU) pipelineDot() {
+	pp := &p.pipe
+	if pp.lx >= 160 {
+		return
+	}
+	if !pp.warm {
+		// The first fetch of each line is done twice; the first result is discarded
+		pp.fetch.ticks++
+		if pp.fetch.ticks == 6 {
+			pp.fetch.ticks = 0
+			pp.warm = true
+		}
+		return
+	}
+	if pp.objFetch {
+		p.objFetchDot()
+		return
+	}
+	// Window start: restart the fetcher on the window map when X reaches WX-7
+	if !pp.window && pp.wyHit && (p.lcdc&0x20) != 0 && (p.cgb || (p.lcdc&0x01) != 0) && int(p.wx) <= pp.lx+7 {
+		pp.window = true
+		pp.bg.Clear()
+		pp.fetchX = 0
+		pp.fetch.ticks = 0
+		pp.discard = 0
+		if p.wx < 7 {
+			pp.discard = int(7 - p.wx)
+		}
+		p.fetchStep()
+		return
+	}
+	// Object hit at the current X: stall the FIFO and fetch it
+	if (p.lcdc&0x02) != 0 && pp.discard == 0 {
+		for i := 0; i < pp.nobj; i++ {
+			o := &pp.objs[i]
+			if !o.done && int(o.x) <= pp.lx+8 {
+				o.done = true
+				pp.objFetch, pp.objWait, pp.objTicks, pp.objCur = true, true, 0, i
+				p.objFetchDot()
+				return
+			}
+		}
+	}
+	// Pop before the fetcher advances so a freshly pushed row appears on the next dot
+	if pp.bg.Len() > 0 {
+		px, _ := pp.bg.PopPixel()
+		if pp.discard > 0 {
+			pp.discard--
+		} else {
+			p.outputPixel(px)
+		}
+	}
+	p.fetchStep()
+}
+
+// fetchStep advances the BG/window fetcher, computing the next tile address when it starts a row.
+func (p *PPU) fetchStep() {
+	pp := &p.pipe
+	if pp.fetch.ticks == 0 {
+		mapBase := uint16(0x9800)
+		var addr uint16
+		var fineY byte
+		if pp.window {
+			if (p.lcdc & 0x40) != 0 {
+				mapBase = 0x9C00
+			}
+			addr = mapBase + uint16(pp.winY>>3)*32 + uint16(pp.fetchX&31)
+			fineY = pp.winY & 7
+		} else {
+			if (p.lcdc & 0x08) != 0 {
+				mapBase = 0x9C00
+			}
+			y := p.ly + p.scy
+			addr = mapBase + uint16(y>>3)*32 + uint16(((p.scx>>3)+pp.fetchX)&31)
+			fineY = y & 7
+		}
+		pp.fetch.Configure(mapBase, (p.lcdc&0x10) != 0, addr, fineY)
+		pp.fetchX++
+	}
+	pp.fetch.Step()
+}
+
+// objFetchDot spends one dot on the pending object fetch.
+func (p *PPU) objFetchDot() {
+	pp := &p.pipe
+	if pp.objWait {
+		// The BG fetcher finishes its current tile (and refills an empty FIFO) first
+		if (pp.fetch.ticks > 0 && pp.fetch.ticks < 6) || pp.bg.Len() == 0 {
+			p.fetchStep()
+			return
+		}
+		pp.objWait = false
+	}
+	pp.objTicks++
+	if pp.objTicks < 6 {
+		return
+	}
+	p.fetchOBJ(&pp.objs[pp.objCur])
+	pp.objFetch = false
+}
+
+// fetchOBJ reads an object's row and merges it into the OBJ FIFO. Existing opaque pixels win
+// (DMG: lower X / earlier OAM entry), except that on CGB the lower OAM index always wins.
+func (p *PPU) fetchOBJ(o *objEntry) {
+	pp := &p.pipe
+	h := 8
+	if (p.lcdc & 0x04) != 0 {
+		h = 16
+	}
+	row := int(p.ly) + 16 - int(o.y)
+	if (o.attr & (1 << 6)) != 0 {
+		row = h - 1 - row
+	}
+	tile := o.tile
+	if h == 16 {
+		tile = (tile & 0xFE) + byte(row>>3)
+	}
+	addr := 0x8000 + uint16(tile)*16 + uint16(row&7)*2
+	bank := 0
+	if p.cgb && (o.attr&(1<<3)) != 0 {
+		bank = 1
+	}
+	lo := p.RawVRAMBank(bank, addr)
+	hi := p.RawVRAMBank(bank, addr+1)
+	// Pixels of an object hanging off the left edge are already past
+	skip := pp.lx + 8 - int(o.x)
+	for pp.obj.Len() < 8 {
+		pp.obj.PushPixel(pixel{})
+	}
+	for i := skip; i < 8; i++ {
+		bit := 7 - byte(i)
+		if (o.attr & (1 << 5)) != 0 {
+			bit = byte(i)
+		}
+		ci := ((hi>>bit)&1)<<1 | ((lo >> bit) & 1)
+		if ci == 0 {
+			continue
+		}
+		np := pixel{ci: ci, pri: (o.attr & (1 << 7)) != 0, obp1: (o.attr & (1 << 4)) != 0, oam: o.index}
+		if p.cgb {
+			np.pal = o.attr & 0x07
+		} else if np.obp1 {
+			np.pal = 1
+		}
+		slot := pp.obj.At(i - skip)
+		if slot.ci == 0 || (p.cgb && p.opri&1 == 0 && o.index < slot.oam) {
+			*slot = np
+		}
+	}
+}
+
+// outputPixel mixes a BG pixel with the next OBJ pixel and writes it to the framebuffer.
+func (p *PPU) outputPixel(bg pixel) {
+	pp := &p.pipe
+	obj, hasObj := pp.obj.PopPixel()
+	// DMG: LCDC bit0 blanks BG and window; CGB: it only removes their priority
+	bgOn := p.cgb || (p.lcdc&0x01) != 0
+	if !bgOn {
+		bg.ci = 0
+	}
+	useObj := hasObj && obj.ci != 0 && (p.lcdc&0x02) != 0
+	if useObj && bg.ci != 0 {
+		if p.cgb {
+			if (p.lcdc&0x01) != 0 && (bg.pri || obj.pri) {
+				useObj = false
+			}
+		} else if obj.pri {
+			useObj = false
+		}
+	}
+	var r, g, b byte
+	switch {
+	case useObj:
+		r, g, b = p.objColor(obj)
+	case !bgOn:
+		r, g, b = 0xFF, 0xFF, 0xFF
+	default:
+		r, g, b = p.bgColor(bg)
+	}
+	i := (int(p.ly)*160 + pp.lx) * 4
+	pp.back[i+0], pp.back[i+1], pp.back[i+2], pp.back[i+3] = r, g, b, 0xFF
+	pp.lx++
+}
+
+// dmgShade maps a color index through a DMG palette register to a gray level.
+func dmgShade(pal, ci byte) byte {
+	switch (pal >> (ci * 2)) & 0x03 {
+	case 0:
+		return 0xFF
+	case 1:
+		return 0xC0
+	case 2:
+		return 0x60
+	default:
+		return 0x00
+	}
+}
+
+func (p *PPU) bgColor(px pixel) (r, g, b byte) {
+	switch {
+	case p.cgb && p.bgPalWritten:
+		return p.BGColorRGB(px.pal, px.ci)
+	case p.compat && p.bgPalWritten:
+		// In compatibility mode BGP indexes colors within CGB BG palette 0
+		return p.BGColorRGB(0, (p.bgp>>(px.ci*2))&0x03)
+	}
+	s := dmgShade(p.bgp, px.ci)
+	return s, s, s
+}
+
+func (p *PPU) objColor(px pixel) (r, g, b byte) {
+	obp := p.obp0
+	if px.obp1 {
+		obp = p.obp1
+	}
+	switch {
+	case p.cgb && p.objPalWritten:
+		return p.OBJColorRGB(px.pal, px.ci)
+	case p.compat && p.objPalWritten:
+		return p.OBJColorRGB(px.pal, (obp>>(px.ci*2))&0x03)
+	}
+	s := dmgShade(obp, px.ci)
+	return s, s, s
+}
@@ -0,0 +1,167 @@
+package ppu
+
+import "testing"
+
+// newPipelinePPU sets up tile 1 as a vertical stripe pattern (ci 3,0,3,0,...) on the whole
+// BG map and turns the LCD on with BG enabled and 0x8000 tile data.
+func newPipelinePPU() *PPU {
+	p := New(nil)
+	for row := 0; row < 8; row++ {
+		p.vram[0x10+row*2] = 0xAA
+		p.vram[0x10+row*2+1] = 0xAA
+	}
+	for i := 0; i < 0x400; i++ {
+		p.vram[0x1800+i] = 0x01
+	}
+	p.CPUWrite(0xFF47, 0xE4) // BGP identity
+	p.CPUWrite(0xFF40, 0x91) // LCD on, tile data 8000, BG on
+	return p
+}
+
+// pixelGray reads the red channel of the in-progress frame at (x, y).
+func pixelGray(p *PPU, x, y int) byte { return p.pipe.back[(y*160+x)*4] }
+
+func TestPipeline_BaseMode3Length(t *testing.T) {
+	p := newPipelinePPU()
+	p.Tick(250)
+	if p.pipe.lx != 159 {
+		t.Fatalf("pixels after 170 mode-3 dots got %d want 159", p.pipe.lx)
+	}
+	p.Tick(1)
+	if p.pipe.lx != 160 {
+		t.Fatalf("pixels after 172 mode-3 dots got %d want 160", p.pipe.lx)
+	}
+}
+
+func TestPipeline_BGPixelsAndFineScroll(t *testing.T) {
+	p := newPipelinePPU()
+	p.CPUWrite(0xFF43, 0x01) // SCX=1 shifts the stripes by one pixel
+	p.Tick(456)
+	for x := 0; x < 160; x++ {
+		want := byte(0x00) // ci 3 -> black
+		if x%2 == 0 {
+			want = 0xFF // SCX=1: even screen X shows odd tile columns (ci 0)
+		}
+		if got := pixelGray(p, x, 0); got != want {
+			t.Fatalf("x=%d got %02X want %02X", x, got, want)
+		}
+	}
+}
+
+func TestPipeline_MidScanlinePaletteWrite(t *testing.T) {
+	p := newPipelinePPU()
+	// Run 12 warm-up dots plus 80 pixels into mode 3, then invert the palette
+	p.Tick(80 + 12 + 80)
+	p.CPUWrite(0xFF47, 0x1B)
+	p.Tick(456 - (80 + 12 + 80))
+	if got := pixelGray(p, 0, 0); got != 0x00 {
+		t.Fatalf("left half pixel got %02X want 00", got)
+	}
+	if got := pixelGray(p, 120, 0); got != 0xFF {
+		t.Fatalf("right half pixel got %02X want FF after BGP write", got)
+	}
+}
+
+func TestPipeline_WindowStartsAtWX(t *testing.T) {
+	p := newPipelinePPU()
+	// Window map at 9C00 uses tile 0 (blank -> white with BGP identity)
+	p.CPUWrite(0xFF4A, 0)  // WY
+	p.CPUWrite(0xFF4B, 87) // WX -> screen X 80
+	p.CPUWrite(0xFF40, 0)
+	p.CPUWrite(0xFF40, 0xF1) // LCD on, window map 9C00, window on, tile data 8000, BG on
+	p.Tick(456)
+	if got := pixelGray(p, 78, 0); got != 0x00 {
+		t.Fatalf("BG pixel left of window got %02X want 00", got)
+	}
+	for x := 80; x < 160; x++ {
+		if got := pixelGray(p, x, 0); got != 0xFF {
+			t.Fatalf("window pixel x=%d got %02X want FF", x, got)
+		}
+	}
+}
+
+func TestPipeline_SpritePriority(t *testing.T) {
+	p := newPipelinePPU()
+	// Tile 2: solid ci 1
+	for row := 0; row < 8; row++ {
+		p.vram[0x20+row*2] = 0xFF
+	}
+	p.CPUWrite(0xFF48, 0xE4) // OBP0 identity: ci1 -> light gray
+	// OBJ 0 at screen (8,0) in front; OBJ 1 at (40,0) behind BG colors 1-3
+	copy(p.oam[0:8], []byte{16, 16, 2, 0x00, 16, 48, 2, 0x80})
+	p.CPUWrite(0xFF40, 0)
+	p.CPUWrite(0xFF40, 0x93) // + OBJ on
+	p.Tick(456)
+	for x := 8; x < 16; x++ {
+		if got := pixelGray(p, x, 0); got != 0xC0 {
+			t.Fatalf("front OBJ x=%d got %02X want C0", x, got)
+		}
+	}
+	// Behind-BG object only shows over BG color 0 (odd columns)
+	if got := pixelGray(p, 40, 0); got != 0x00 {
+		t.Fatalf("behind OBJ over BG ci3 got %02X want 00", got)
+	}
+	if got := pixelGray(p, 41, 0); got != 0xC0 {
+		t.Fatalf("behind OBJ over BG ci0 got %02X want C0", got)
+	}
+}
+
+func TestPipeline_FrameSwapAtVBlank(t *testing.T) {
+	p := newPipelinePPU()
+	if got := p.Framebuffer()[0]; got != 0xFF {
+		t.Fatalf("initial framebuffer got %02X want FF", got)
+	}
+	p.Tick(144 * 456)
+	if got := p.Framebuffer()[0]; got != 0x00 {
+		t.Fatalf("framebuffer after first frame got %02X want 00", got)
+	}
+}
+
+func TestPipeline_WindowIgnoresLCDC0OnlyInCGBMode(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		hw, cgb, compat bool
+		want            byte
+	}{
+		{"dmg", false, false, false, 0},
+		{"compat", true, false, true, 0},
+		{"cgb", true, true, false, 1}, // on CGB LCDC.0 does not stop the window
+	} {
+		p := newPipelinePPU()
+		p.SetCGBMode(tc.hw)
+		p.SetColorMode(tc.cgb, tc.compat)
+		p.CPUWrite(0xFF4A, 0)
+		p.CPUWrite(0xFF4B, 87)
+		p.CPUWrite(0xFF40, 0)
+		p.CPUWrite(0xFF40, 0xF0) // window on, BG/window enable (bit0) clear
+		p.Tick(456)
+		if p.pipe.winY != tc.want {
+			t.Fatalf("%s: window lines after one line got %d want %d", tc.name, p.pipe.winY, tc.want)
+		}
+	}
+}
+
+func TestPipeline_CompatIgnoresCGBAttributes(t *testing.T) {
+	p := newPipelinePPU()
+	p.SetCGBMode(true)
+	p.SetColorMode(false, true)
+	// Tile 2: solid ci 1 in bank 0; bank 1 is left blank
+	for row := 0; row < 8; row++ {
+		p.vram[0x20+row*2] = 0xFF
+	}
+	// Attribute map: every BG tile from bank 1, X flipped
+	for i := 0; i < 0x400; i++ {
+		p.vram1[0x1800+i] = 0x28
+	}
+	p.CPUWrite(0xFF48, 0xE4)
+	copy(p.oam[0:4], []byte{16, 16, 2, 0x08}) // OBJ at (8,0) from bank 1
+	p.CPUWrite(0xFF40, 0)
+	p.CPUWrite(0xFF40, 0x93)
+	p.Tick(456)
+	if got := pixelGray(p, 0, 0); got != 0x00 {
+		t.Fatalf("BG pixel got %02X want 00 (bank 0, unflipped)", got)
+	}
+	if got := pixelGray(p, 8, 0); got != 0xC0 {
+		t.Fatalf("OBJ pixel got %02X want C0 (bank 0)", got)
+	}
+}
@@ -25,6 +25,8 @@ type PPU struct {
 	objPalWritten bool
 	// CGB VRAM bank select for CPU accesses (FF4F VBK)
 	vbk byte // bit0 selects VRAM bank for CPURead/Write
+	// FF6C OPRI: bit0 set picks sprite priority by X coordinate (DMG style) instead of OAM order
+	opri byte
 
 	// regs
 	lcdc byte // FF40
@@ -41,13 +43,28 @@ type PPU struct {
 
 	dot int // dots within current line [0..455]
 
+	// lyWrapped is set during line 153 once LY already reads 0 (the frame wraps at its end)
+	lyWrapped bool
+	// statLine is the shared STAT interrupt line (OR of all enabled sources); IF is only
+	// requested on its rising edge, so an active source blocks the others
+	statLine bool
+	// cgbHW selects CGB hardware behavior: no DMG STAT-write quirk, OPRI writes
+	cgbHW bool
+
 	req InterruptRequester
+	// hblank is invoked on each visible-line transition into mode 0 (used by CGB HBlank DMA)
+	hblank func()
 
 	// Per-scanline register snapshot captured at start of each visible line (mode 2)
 	lineRegs [154]LineRegs
 
 	// Internal window line counter (increments each line when window is active)
 	winLineCounter byte
+
+	// Pixel FIFO pipeline (pipeline.go) and its color mode
+	pipe   pipeline
+	cgb    bool // native CGB colors: CRAM palettes, OAM-order OBJ priority, LCDC.0 as priority
+	compat bool // DMG ROM on CGB: BGP/OBP index CRAM palettes
 }
 
 func New(req InterruptRequester) *PPU {
@@ -60,9 +77,16 @@ func New(req InterruptRequester) *PPU {
 		p.objPal[i] = 0xFF
 		p.objPal[i+1] = 0x7F
 	}
+	p.clearFramebuffer()
 	return p
 }
 
+// SetCGBMode selects CGB hardware behavior for quirks that differ from the DMG.
+func (p *PPU) SetCGBMode(on bool) { p.cgbHW = on }
+
+// SetHBlankHook registers a callback fired when a visible line (LY 0..143) enters HBlank.
+func (p *PPU) SetHBlankHook(fn func()) { p.hblank = fn }
+
 // LineRegs represents the PPU-visible registers relevant for rendering a scanline.
 type LineRegs struct {
 	LCDC    byte
@@ -131,6 +155,11 @@ func (p *PPU) CPURead(addr uint16) byte {
 	case addr == 0xFF6B: // OCPD/OBPD
 		idx := int(p.ocps & 0x3F)
 		return p.objPal[idx]
+	case addr == 0xFF6C: // OPRI (CGB only)
+		if !p.cgbHW {
+			return 0xFF
+		}
+		return 0xFE | p.opri
 	default:
 		return 0xFF
 	}
@@ -162,18 +191,30 @@ func (p *PPU) CPUWrite(addr uint16, value byte) {
 			// Turning LCD off resets LY/mode
 			p.ly = 0
 			p.dot = 0
+			p.lyWrapped = false
 			p.setMode(0)
 			p.updateLYC()
+			p.clearFramebuffer()
 		} else if (p.lcdc&0x80) != 0 && (prev&0x80) == 0 {
 			// Turning LCD on: start at LY=0, mode 2 (OAM)
 			p.ly = 0
 			p.dot = 0
+			p.lyWrapped = false
 			p.winLineCounter = 0
+			p.pipe.winY = 0
+			p.pipe.wyHit = (p.lcdc&0x20) != 0 && p.wy == 0
 			p.setMode(2)
 			p.updateLYC()
 		}
 	case addr == 0xFF41:
+		if !p.cgbHW {
+			// DMG quirk: the write briefly enables the HBlank, VBlank and LYC sources,
+			// so writing STAT in modes 0/1 or while LY=LYC raises the line
+			p.stat |= 0x58
+			p.updateSTATLine()
+		}
 		p.stat = (p.stat & 0x07) | (value & 0x78)
+		p.updateSTATLine()
 	case addr == 0xFF42:
 		p.scy = value
 	case addr == 0xFF43:
@@ -181,7 +222,10 @@ func (p *PPU) CPUWrite(addr uint16, value byte) {
 	case addr == 0xFF44:
 		p.ly = 0
 		p.dot = 0
+		p.lyWrapped = false
 		p.winLineCounter = 0
+		p.pipe.winY = 0
+		p.pipe.wyHit = false
 		p.updateLYC()
 		if (p.lcdc & 0x80) != 0 {
 			p.setMode(2)
@@ -234,6 +278,10 @@ func (p *PPU) CPUWrite(addr uint16, value byte) {
 		if (p.ocps & 0x80) != 0 {
 			p.ocps = (p.ocps & 0xC0) | byte((idx+1)&0x3F)
 		}
+	case addr == 0xFF6C: // OPRI: the CGB boot ROM sets bit 0 for DMG games
+		if p.cgbHW {
+			p.opri = value & 0x01
+		}
 	}
 }
 
@@ -247,45 +295,71 @@ func (p *PPU) Tick(cycles int) {
 			continue
 		}
 		p.dot++
-		// Mode scheduling
+		// Line 153: LY reads 0 after a few dots, so LYC=0 already matches before the frame wraps
+		if p.ly == 153 && p.dot == 4 {
+			p.ly = 0
+			p.lyWrapped = true
+			p.updateLYC()
+		}
+		// Mode scheduling: mode 3 lasts until the pixel pipeline has output 160 pixels
+		// (172 dots plus SCX%8, window and object penalties)
 		var mode byte
-		if p.ly >= 144 {
+		if p.ly >= 144 || p.lyWrapped {
 			mode = 1
 		} else {
 			switch {
 			case p.dot < 80:
 				mode = 2
-			case p.dot < 80+172:
+			case p.dot == 80:
+				mode = 3
+			case (p.stat&0x03) == 3 && p.pipe.lx < 160:
 				mode = 3
 			default:
 				mode = 0
 			}
 		}
 		p.setMode(mode)
+		switch mode {
+		case 2:
+			// One OAM entry is examined every two dots
+			if (p.dot & 1) == 1 {
+				p.oamScan(p.dot >> 1)
+			}
+		case 3:
+			p.pipelineDot()
+		}
 
 		if p.dot >= 456 {
 			p.dot = 0
-			p.ly++
+			if p.lyWrapped {
+				// End of line 153: LY already reads 0, start the next frame
+				p.lyWrapped = false
+				p.winLineCounter = 0
+				p.pipe.winY = 0
+				p.pipe.wyHit = false
+			} else {
+				p.ly++
+			}
 			if p.ly == 144 {
-				// Enter VBlank
+				// Enter VBlank; the pipeline's frame is complete
+				p.pipe.front = p.pipe.back
 				if p.req != nil {
 					p.req(0)
 				} // VBlank IF
-				if (p.stat & (1 << 4)) != 0 {
-					if p.req != nil {
-						p.req(1)
-					}
-				} // STAT VBlank
-			} else if p.ly > 153 {
-				p.ly = 0
-				p.winLineCounter = 0
 			}
 			p.updateLYC()
 			// Set mode for new line start (dot=0)
 			if p.ly >= 144 {
 				p.setMode(1)
+				// The OAM source also fires as line 144 starts (a short pulse that does not hold the line)
+				if p.ly == 144 && (p.stat&(1<<5)) != 0 && !p.statLine && p.req != nil {
+					p.req(1)
+				}
 			} else {
 				p.setMode(2)
+				if (p.lcdc&0x20) != 0 && p.ly == p.wy {
+					p.pipe.wyHit = true
+				}
 				// Update window line counter for THIS line based on visibility
 				// On DMG, window display requires both BG (bit0) and window (bit5) enabled.
 				windowVisible := (p.lcdc&0x20) != 0 && (p.lcdc&0x01) != 0 && p.ly >= p.wy && p.wx <= 166
@@ -309,33 +383,49 @@ func (p *PPU) setMode(mode byte) {
 	p.stat = (p.stat &^ 0x03) | (mode & 0x03)
 	switch mode {
 	case 0: // HBlank
-		if (p.stat & (1 << 3)) != 0 {
-			if p.req != nil {
-				p.req(1)
-			}
+		if prev == 3 && p.ly < 144 && (p.lcdc&0x80) != 0 {
+			p.finishLine()
 		}
-	case 2: // OAM
-		if (p.stat & (1 << 5)) != 0 {
-			if p.req != nil {
-				p.req(1)
-			}
+		if p.ly < 144 && (p.lcdc&0x80) != 0 && p.hblank != nil {
+			p.hblank()
 		}
 	case 3: // Entering mode 3: latch per-line regs for rendering
 		p.captureLineRegs()
+		p.startLine()
 	}
+	p.updateSTATLine()
 }
 
 func (p *PPU) updateLYC() {
 	if p.ly == p.lyc {
 		p.stat |= 1 << 2
-		if (p.stat & (1 << 6)) != 0 {
-			if p.req != nil {
-				p.req(1)
-			}
-		}
 	} else {
 		p.stat &^= 1 << 2
 	}
+	p.updateSTATLine()
+}
+
+// updateSTATLine recomputes the STAT interrupt line from the enabled sources (HBlank, VBlank,
+// OAM, LY=LYC) and requests IF bit 1 only when the line goes from low to high.
+func (p *PPU) updateSTATLine() {
+	high := false
+	if (p.lcdc & 0x80) != 0 {
+		switch p.stat & 0x03 {
+		case 0:
+			high = (p.stat & (1 << 3)) != 0
+		case 1:
+			high = (p.stat & (1 << 4)) != 0
+		case 2:
+			high = (p.stat & (1 << 5)) != 0
+		}
+		if (p.stat&(1<<2)) != 0 && (p.stat&(1<<6)) != 0 {
+			high = true
+		}
+	}
+	if high && !p.statLine && p.req != nil {
+		p.req(1)
+	}
+	p.statLine = high
 }
 
 func (p *PPU) captureLineRegs() {
@@ -382,6 +472,20 @@ func (p *PPU) RawVRAMBank(bank int, addr uint16) byte {
 	return p.vram1[off]
 }
 
+// DMAWriteVRAM writes a byte into the CPU-selected VRAM bank (VBK) without mode-3 blocking.
+// Used by CGB HDMA/GDMA, which owns the VRAM bus while it runs.
+func (p *PPU) DMAWriteVRAM(addr uint16, value byte) {
+	if addr < 0x8000 || addr > 0x9FFF {
+		return
+	}
+	off := addr - 0x8000
+	if (p.vbk & 0x01) != 0 {
+		p.vram1[off] = value
+	} else {
+		p.vram[off] = value
+	}
+}
+
 // RawOAM returns OAM bytes without CPU access restrictions; for renderer use only.
 func (p *PPU) RawOAM(addr uint16) byte {
 	if addr >= 0xFE00 && addr <= 0xFE9F {
@@ -462,6 +566,11 @@ type ppuState struct {
 	DOT           int
 	LineRegs      [154]LineRegs
 	WinLine       byte
+	WYHit         bool
+	FIFOWinLine   byte
+	LYWrapped     bool
+	STATLine      bool
+	OPRI          byte
 }
 
 func (p *PPU) SaveState() []byte {
@@ -474,6 +583,8 @@ func (p *PPU) SaveState() []byte {
 		LCDC: p.lcdc, STAT: p.stat, SCY: p.scy, SCX: p.scx, LY: p.ly, LYC: p.lyc,
 		BGP: p.bgp, OBP0: p.obp0, OBP1: p.obp1, WY: p.wy, WX: p.wx,
 		DOT: p.dot, LineRegs: p.lineRegs, WinLine: p.winLineCounter,
+		WYHit: p.pipe.wyHit, FIFOWinLine: p.pipe.winY,
+		LYWrapped: p.lyWrapped, STATLine: p.statLine, OPRI: p.opri,
 	}
 	_ = enc.Encode(s)
 	return buf.Bytes()
@@ -500,4 +611,11 @@ func (p *PPU) LoadState(data []byte) {
 	p.dot = s.DOT
 	p.lineRegs = s.LineRegs
 	p.winLineCounter = s.WinLine
+	p.pipe.wyHit = s.WYHit
+	p.pipe.winY = s.FIFOWinLine
+	p.lyWrapped = s.LYWrapped
+	p.statLine = s.STATLine
+	p.opri = s.OPRI
+	// Mid-line FIFO state is not saved; skip the rest of a line restored inside mode 3
+	p.pipe.lx = 160
 }
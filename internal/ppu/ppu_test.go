@@ -100,3 +100,122 @@ func TestSTATModeAndLYCCoincidence(t *testing.T) {
 		t.Fatalf("expected STAT IRQ on LYC coincidence at LY=2")
 	}
 }
+
+// mode3Length returns how many dots mode 3 lasts on the current line (PPU must be at dot 0 of a visible line).
+func mode3Length(p *PPU) int {
+	p.Tick(80)
+	n := 0
+	for statMode(p) == 3 {
+		p.Tick(1)
+		n++
+	}
+	return n
+}
+
+func TestMode3LengthPenalties(t *testing.T) {
+	p := New(nil)
+	p.CPUWrite(0xFF40, 0x91)
+	if n := mode3Length(p); n != 172 {
+		t.Fatalf("base mode 3 got %d dots want 172", n)
+	}
+	// SCX fine scroll adds SCX%8 dots
+	p.CPUWrite(0xFF43, 0x05)
+	p.Tick(456 - 80 - 172)
+	if n := mode3Length(p); n != 177 {
+		t.Fatalf("SCX=5 mode 3 got %d dots want 177", n)
+	}
+	p.CPUWrite(0xFF43, 0x00)
+	// Window on the line adds a 6-dot fetcher restart
+	p.CPUWrite(0xFF4A, 0)
+	p.CPUWrite(0xFF4B, 87)
+	p.CPUWrite(0xFF40, 0)
+	p.CPUWrite(0xFF40, 0xB1)
+	if n := mode3Length(p); n != 178 {
+		t.Fatalf("window mode 3 got %d dots want 178", n)
+	}
+	// An object on the line stalls for its fetch (at least 6 dots)
+	p.CPUWrite(0xFF40, 0)
+	copy(p.oam[0:4], []byte{16, 40, 0, 0})
+	p.CPUWrite(0xFF40, 0x93)
+	if n := mode3Length(p); n < 178 || n > 183 {
+		t.Fatalf("one-object mode 3 got %d dots want 178..183", n)
+	}
+}
+
+func TestSTATLineBlocking(t *testing.T) {
+	stats := 0
+	p := New(func(bit int) {
+		if bit == 1 {
+			stats++
+		}
+	})
+	p.SetCGBMode(true) // no STAT-write quirk
+	// LYC=0 and HBlank sources both enabled: the line stays high from LY=LYC into HBlank
+	p.CPUWrite(0xFF45, 0)
+	p.CPUWrite(0xFF41, (1<<3)|(1<<6))
+	p.CPUWrite(0xFF40, 0x80)
+	if stats != 1 {
+		t.Fatalf("LYC match at LCD on got %d STAT IRQs want 1", stats)
+	}
+	p.Tick(80 + 172)
+	if statMode(p) != 0 || stats != 1 {
+		t.Fatalf("HBlank while LYC holds the line got %d STAT IRQs want 1", stats)
+	}
+	// Next line: LY!=LYC, the HBlank edge is seen again
+	p.Tick(456)
+	if stats != 2 {
+		t.Fatalf("HBlank on LY=1 got %d STAT IRQs want 2", stats)
+	}
+}
+
+func TestLine153ReadsLYZero(t *testing.T) {
+	stats := 0
+	p := New(func(bit int) {
+		if bit == 1 {
+			stats++
+		}
+	})
+	p.CPUWrite(0xFF40, 0x80)
+	p.CPUWrite(0xFF45, 0)
+	p.CPUWrite(0xFF41, 1<<6)
+	p.Tick(153 * 456)
+	stats = 0
+	if ly := p.CPURead(0xFF44); ly != 153 {
+		t.Fatalf("LY at line 153 start got %d", ly)
+	}
+	p.Tick(8)
+	if ly := p.CPURead(0xFF44); ly != 0 {
+		t.Fatalf("LY late in line 153 got %d want 0", ly)
+	}
+	if statMode(p) != 1 || stats != 1 {
+		t.Fatalf("line 153: mode=%d LYC=0 IRQs=%d want mode 1 and 1 IRQ", statMode(p), stats)
+	}
+	// Frame wraps at the end of the line without a second LYC edge
+	p.Tick(456 - 8)
+	if ly := p.CPURead(0xFF44); ly != 0 || statMode(p) != 2 || stats != 1 {
+		t.Fatalf("after wrap LY=%d mode=%d IRQs=%d", ly, statMode(p), stats)
+	}
+}
+
+func TestDMGSTATWriteQuirk(t *testing.T) {
+	for _, cgb := range []bool{false, true} {
+		stats := 0
+		p := New(func(bit int) {
+			if bit == 1 {
+				stats++
+			}
+		})
+		p.SetCGBMode(cgb)
+		p.CPUWrite(0xFF45, 0x80) // never matches
+		p.CPUWrite(0xFF40, 0x80)
+		p.Tick(80 + 172) // HBlank
+		p.CPUWrite(0xFF41, 0x00)
+		want := 1
+		if cgb {
+			want = 0
+		}
+		if stats != want {
+			t.Fatalf("cgb=%v STAT write in HBlank got %d IRQs want %d", cgb, stats, want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Package printer emulates the Game Boy Printer on the serial port.
+//
+// The game drives the clock and sends packets; the printer answers every byte with $00
+// except the last two, which carry its ID ($81) and status:
+//
+//	$88 $33 cmd compression len(2, LE) data... checksum(2, LE) $00 $00
+//
+// The checksum is the 16-bit sum of cmd through the last data byte. Commands are INIT
+// ($01) to clear the buffer, DATA ($04) to append tile data (RLE compressed when
+// compression is 1, an empty packet ends the image), PRINT ($02) to print the buffer,
+// and STATUS ($0F) to poll. Printed images are added to a paper strip, which is written
+// to a PNG file once the paper is fed out after a print.
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Width is the printed width in pixels: 20 tiles per row.
+const Width = 160
+
+// Commands.
+const (
+	CmdInit   = 0x01
+	CmdPrint  = 0x02
+	CmdData   = 0x04
+	CmdStatus = 0x0F
+)
+
+// Status bits.
+const (
+	StatusChecksum    = 0x01 // last packet had a bad checksum
+	StatusBusy        = 0x02 // printing
+	StatusFull        = 0x04 // the buffer holds a full screen
+	StatusUnprocessed = 0x08 // data received but not printed yet
+)
+
+const (
+	deviceID   = 0x81
+	bufferSize = 0x2000         // 8 KiB printer RAM
+	fullSize   = 0x1680         // 160x144 pixels of tile data
+	rowBytes   = Width / 8 * 16 // tile data for 8 pixel rows
+	feedRows   = 8              // pixel rows per line of paper feed in the PRINT margins
+	busyPolls  = 4              // STATUS packets answered busy after a print
+)
+
+// shades maps 2-bit shades (0 white .. 3 black) to paper colors.
+var shades = color.Palette{color.Gray{0xFF}, color.Gray{0xAA}, color.Gray{0x55}, color.Gray{0x00}}
+
+// Printer is a Game Boy Printer. It implements bus.SerialDevice and is plugged in with
+// Machine.SetSerialDevice.
+type Printer struct {
+	dir string
+	n   int // prints written so far
+
+	// OnPrint, if set, is called after each print with the file written or the error.
+	OnPrint func(path string, err error)
+
+	// packet being received
+	pos      int
+	cmd      byte
+	compress byte
+	length   int
+	data     []byte
+	sum      uint16
+	recvSum  uint16
+
+	status byte
+	busy   int
+	buf    []byte   // decoded tile data waiting to be printed
+	strip  [][]byte // printed pixel rows (2-bit shades) not fed out yet
+}
+
+// New returns a printer writing its prints to dir as PNG files.
+func New(dir string) *Printer { return &Printer{dir: dir} }
+
+// Ready implements bus.SerialDevice.
+func (p *Printer) Ready() bool { return true }
+
+// Exchange implements bus.SerialDevice: it receives one byte of a packet and returns
+// the printer's answer.
+func (p *Printer) Exchange(out byte) byte { return p.receive(out) }
+
+// Poll implements bus.SerialDevice; the printer never drives the clock.
+func (p *Printer) Poll(func(byte) byte) {}
+
+// receive advances the packet state machine by one byte.
+func (p *Printer) receive(b byte) byte {
+	switch pos := p.pos; {
+	case pos == 0:
+		if b == 0x88 {
+			p.pos++
+		}
+		return 0
+	case pos == 1:
+		p.pos = 0
+		if b == 0x33 {
+			p.pos = 2
+		} else if b == 0x88 {
+			p.pos = 1
+		}
+		return 0
+	case pos == 2:
+		p.cmd, p.sum, p.data = b, uint16(b), p.data[:0]
+	case pos == 3:
+		p.compress = b
+		p.sum += uint16(b)
+	case pos == 4:
+		p.length = int(b)
+		p.sum += uint16(b)
+	case pos == 5:
+		p.length |= int(b) << 8
+		p.sum += uint16(b)
+	case pos < 6+p.length:
+		p.data = append(p.data, b)
+		p.sum += uint16(b)
+	case pos == 6+p.length:
+		p.recvSum = uint16(b)
+	case pos == 7+p.length:
+		p.recvSum |= uint16(b) << 8
+		p.execute()
+	case pos == 8+p.length:
+		p.pos++
+		return deviceID
+	default:
+		p.pos = 0
+		return p.status
+	}
+	p.pos++
+	return 0
+}
+
+// execute runs a complete packet.
+func (p *Printer) execute() {
+	if p.recvSum != p.sum {
+		p.status |= StatusChecksum
+		return
+	}
+	p.status &^= StatusChecksum
+	switch p.cmd {
+	case CmdInit:
+		p.buf = p.buf[:0]
+		p.status, p.busy = 0, 0
+	case CmdData:
+		data := p.data
+		if p.compress != 0 {
+			data = decompress(data)
+		}
+		if n := bufferSize - len(p.buf); len(data) > n {
+			data = data[:n]
+		}
+		p.buf = append(p.buf, data...)
+		if len(p.buf) > 0 {
+			p.status |= StatusUnprocessed
+		}
+		if len(p.buf) >= fullSize {
+			p.status |= StatusFull
+		}
+	case CmdPrint:
+		if len(p.data) < 4 {
+			return
+		}
+		p.print(p.data[1], p.data[2])
+		p.buf = p.buf[:0]
+		p.status = p.status&^(StatusUnprocessed|StatusFull) | StatusBusy
+		p.busy = busyPolls
+	case CmdStatus:
+		if p.busy > 0 {
+			if p.busy--; p.busy == 0 {
+				p.status &^= StatusBusy
+			}
+		}
+	}
+}
+
+// decompress expands RLE data: a control byte below $80 is followed by that many plus
+// one literal bytes, otherwise the next byte repeats (control&$7F)+2 times.
+func decompress(src []byte) []byte {
+	var out []byte
+	for i := 0; i < len(src); {
+		c := src[i]
+		i++
+		if c&0x80 == 0 {
+			n := min(int(c)+1, len(src)-i)
+			out = append(out, src[i:i+n]...)
+			i += n
+			continue
+		}
+		if i < len(src) {
+			for n := int(c&0x7F) + 2; n > 0; n-- {
+				out = append(out, src[i])
+			}
+			i++
+		}
+	}
+	return out
+}
+
+// print adds the buffer to the paper strip. The high nibble of margins is the number of
+// lines fed before the image and the low nibble those fed after; feeding after a print
+// ends the strip and writes it out. palette maps color numbers to shades like BGP.
+func (p *Printer) print(margins, palette byte) {
+	if palette == 0 {
+		palette = 0xE4 // treated as the default palette
+	}
+	if len(p.buf) > 0 {
+		p.feed(int(margins>>4) * feedRows)
+		for y := 0; y < len(p.buf)/rowBytes*8; y++ {
+			row := make([]byte, Width)
+			for x := range row {
+				tile := (y/8)*(Width/8) + x/8
+				lo := p.buf[tile*16+(y%8)*2]
+				hi := p.buf[tile*16+(y%8)*2+1]
+				bit := 7 - uint(x%8)
+				c := (hi>>bit&1)<<1 | lo>>bit&1
+				row[x] = palette >> (c * 2) & 3
+			}
+			p.strip = append(p.strip, row)
+		}
+	}
+	if after := int(margins & 0x0F); after > 0 && len(p.strip) > 0 {
+		p.feed(after * feedRows)
+		_ = p.flush() // reported through OnPrint
+	}
+}
+
+// feed adds blank paper to the strip.
+func (p *Printer) feed(rows int) {
+	for ; rows > 0; rows-- {
+		p.strip = append(p.strip, make([]byte, Width))
+	}
+}
+
+// flush writes the strip to a PNG file and starts a new one.
+func (p *Printer) flush() error {
+	img := image.NewPaletted(image.Rect(0, 0, Width, len(p.strip)), shades)
+	for y, row := range p.strip {
+		copy(img.Pix[y*img.Stride:], row)
+	}
+	p.strip = nil
+	p.n++
+	name := filepath.Join(p.dir, fmt.Sprintf("print_%s_%d.png", time.Now().Format("20060102_150405"), p.n))
+	err := writePNG(name, img)
+	if p.OnPrint != nil {
+		p.OnPrint(name, err)
+	}
+	return err
+}
+
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Close writes out a strip that was printed but not fed out yet.
+func (p *Printer) Close() error {
+	if len(p.strip) > 0 {
+		return p.flush()
+	}
+	return nil
+}
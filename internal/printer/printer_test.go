@@ -0,0 +1,121 @@
+package printer
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"testing"
+)
+
+// send transfers one packet and returns the printer's answers to its last two bytes.
+func send(p *Printer, cmd, compress byte, data []byte) (id, status byte) {
+	pkt := []byte{0x88, 0x33, cmd, compress, byte(len(data)), byte(len(data) >> 8)}
+	pkt = append(pkt, data...)
+	var sum uint16
+	for _, b := range pkt[2:] {
+		sum += uint16(b)
+	}
+	pkt = append(pkt, byte(sum), byte(sum>>8), 0, 0)
+	for i, b := range pkt {
+		in := p.Exchange(b)
+		switch i {
+		case len(pkt) - 2:
+			id = in
+		case len(pkt) - 1:
+			status = in
+		default:
+			if in != 0 {
+				panic("printer answered during the packet")
+			}
+		}
+	}
+	return id, status
+}
+
+func TestDecompress(t *testing.T) {
+	got := decompress([]byte{0x02, 1, 2, 3, 0x81, 9, 0x00, 4})
+	want := []byte{1, 2, 3, 9, 9, 9, 4}
+	if string(got) != string(want) {
+		t.Fatalf("decompress = %v, want %v", got, want)
+	}
+}
+
+func TestPrinter_PrintsPNG(t *testing.T) {
+	p := New(t.TempDir())
+	var path string
+	p.OnPrint = func(name string, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		path = name
+	}
+	if id, st := send(p, CmdInit, 0, nil); id != deviceID || st != 0 {
+		t.Fatalf("INIT answered %02X %02X", id, st)
+	}
+	// One tile row: the first tile is color 3 in its top row, everything else color 0
+	row := make([]byte, rowBytes)
+	row[0], row[1] = 0xFF, 0xFF
+	if _, st := send(p, CmdData, 0, row); st != StatusUnprocessed {
+		t.Fatalf("DATA status %02X", st)
+	}
+	// The same row again, RLE compressed: two literal bytes, then 318 zeros
+	rle := []byte{0x01, 0xFF, 0xFF, 0xFF, 0x00, 0xFF, 0x00, 0xBA, 0x00}
+	send(p, CmdData, 1, rle)
+	if len(p.buf) != 2*rowBytes {
+		t.Fatalf("buffer holds %d bytes, want %d", len(p.buf), 2*rowBytes)
+	}
+	send(p, CmdData, 0, nil)
+	// Palette $1B maps color 3 to white and color 0 to black; one feed line after
+	if _, st := send(p, CmdPrint, 0, []byte{1, 0x01, 0x1B, 0x40}); st&StatusBusy == 0 {
+		t.Fatalf("PRINT status %02X, want busy", st)
+	}
+	for i := 0; i < busyPolls; i++ {
+		send(p, CmdStatus, 0, nil)
+	}
+	if _, st := send(p, CmdStatus, 0, nil); st != 0 {
+		t.Fatalf("status after printing %02X", st)
+	}
+	if path == "" {
+		t.Fatal("nothing printed")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b != image.Rect(0, 0, Width, 16+feedRows) {
+		t.Fatalf("print size %v", b)
+	}
+	gray := func(x, y int) uint32 { r, _, _, _ := img.At(x, y).RGBA(); return r >> 8 }
+	for _, c := range []struct{ x, y int }{{0, 0}, {0, 8}} {
+		if g := gray(c.x, c.y); g != 0xFF {
+			t.Errorf("pixel %v = %02X, want white", c, g)
+		}
+	}
+	for _, c := range []struct{ x, y int }{{8, 0}, {0, 1}, {0, 9}} {
+		if g := gray(c.x, c.y); g != 0x00 {
+			t.Errorf("pixel %v = %02X, want black", c, g)
+		}
+	}
+	if g := gray(0, 16); g != 0xFF {
+		t.Errorf("feed = %02X, want white paper", g)
+	}
+}
+
+func TestPrinter_ChecksumError(t *testing.T) {
+	p := New(t.TempDir())
+	for _, b := range []byte{0x88, 0x33, CmdStatus, 0, 0, 0, 0x00, 0x00} {
+		p.Exchange(b)
+	}
+	p.Exchange(0)
+	if st := p.Exchange(0); st != StatusChecksum {
+		t.Fatalf("status %02X, want checksum error", st)
+	}
+	if _, st := send(p, CmdStatus, 0, nil); st != 0 {
+		t.Fatalf("status %02X after a good packet", st)
+	}
+}
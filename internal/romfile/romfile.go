@@ -0,0 +1,185 @@
+// Package romfile reads ROM images from plain files and from .zip and .gz archives.
+// An archive stands in for the ROM wherever a path is kept (.sav and savestate names),
+// so loaders only need the archive path and, for zips with several ROMs, the entry name;
+// each ROM of such a zip gets its own names from NamePath.
+package romfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MaxROMSize caps how much is read from one entry (8 MiB is the largest MBC5 ROM).
+const MaxROMSize = 8 * 1024 * 1024
+
+// ErrNoROM is returned when an archive holds no .gb/.gbc entry.
+var ErrNoROM = errors.New("archive contains no .gb/.gbc ROM")
+
+// IsROMName reports whether a file name has a Game Boy ROM extension.
+func IsROMName(name string) bool {
+	ln := strings.ToLower(name)
+	return strings.HasSuffix(ln, ".gb") || strings.HasSuffix(ln, ".gbc")
+}
+
+// IsArchive reports whether a path names a supported archive.
+func IsArchive(path string) bool {
+	ln := strings.ToLower(path)
+	return strings.HasSuffix(ln, ".zip") || strings.HasSuffix(ln, ".gz")
+}
+
+// IsLoadable reports whether the ROM picker should offer a file: a ROM or an archive.
+func IsLoadable(name string) bool { return IsROMName(name) || IsArchive(name) }
+
+// Entries lists the ROMs inside an archive in name order. A gzip file holds exactly one,
+// named after the archive without .gz; a plain ROM path returns its own base name.
+func Entries(path string) ([]string, error) {
+	ln := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(ln, ".zip"):
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		var names []string
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() && IsROMName(f.Name) {
+				names = append(names, f.Name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, ErrNoROM
+		}
+		sort.Strings(names)
+		return names, nil
+	case strings.HasSuffix(ln, ".gz"):
+		return []string{filepath.Base(path[:len(path)-len(".gz")])}, nil
+	default:
+		return []string{filepath.Base(path)}, nil
+	}
+}
+
+// Read returns the ROM at path, taking the first ROM entry (in name order) from an archive.
+func Read(path string) ([]byte, error) {
+	return ReadEntry(path, "")
+}
+
+// ReadEntry returns one ROM from an archive; an empty entry selects the first one.
+// For plain files and gzip archives entry is ignored.
+func ReadEntry(path, entry string) ([]byte, error) {
+	ln := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(ln, ".zip"):
+		return readZip(path, entry)
+	case strings.HasSuffix(ln, ".gz"):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer zr.Close()
+		return readLimited(zr, path)
+	default:
+		return os.ReadFile(path)
+	}
+}
+
+func readZip(path, entry string) ([]byte, error) {
+	if entry == "" {
+		names, err := Entries(path)
+		if err != nil {
+			return nil, err
+		}
+		entry = names[0]
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name != entry {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return readLimited(rc, path+":"+entry)
+	}
+	return nil, fmt.Errorf("%s: no entry %q", path, entry)
+}
+
+// readLimited reads a decompressed stream, refusing anything larger than a ROM can be.
+func readLimited(r io.Reader, name string) ([]byte, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, MaxROMSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if n > MaxROMSize {
+		return nil, fmt.Errorf("%s: larger than %d bytes", name, MaxROMSize)
+	}
+	return buf.Bytes(), nil
+}
+
+// BasePath strips archive extensions and a trailing ".gb", e.g. "dir/game.gb.gz" -> "dir/game".
+// Battery saves are named from it; ".gbc" is kept, matching the names saves already have.
+func BasePath(path string) string {
+	for IsArchive(path) {
+		path = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return strings.TrimSuffix(path, ".gb")
+}
+
+// SavePath returns the battery save file for a ROM or archive path.
+func SavePath(path string) string { return BasePath(path) + ".sav" }
+
+// NamePath returns the path saves, savestates and sibling files of an archive entry are
+// named after. For a zip with several ROMs it joins the archive and entry names, e.g.
+// "dir/game.zip" and "foo.gb" -> "dir/game.foo.gb", so entries do not share files; any
+// other path is returned as is. An empty entry is the first ROM. The path need not exist.
+func NamePath(path, entry string) string {
+	if !strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return path
+	}
+	names, err := Entries(path)
+	if err != nil || len(names) < 2 {
+		return path
+	}
+	if entry == "" {
+		entry = names[0]
+	}
+	return BasePath(path) + "." + strings.ReplaceAll(entry, "/", "_")
+}
+
+// FindSibling returns the first existing file next to a ROM or archive named after it
+// with one of exts, trying the name with the ROM extension dropped ("game.sym") before
+// the name with it kept ("game.gb.sym"). It returns "" when there is none.
+func FindSibling(path string, exts ...string) string {
+	base := path
+	for IsArchive(base) {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	for _, stem := range []string{strings.TrimSuffix(base, filepath.Ext(base)), base} {
+		for _, ext := range exts {
+			if fi, err := os.Stat(stem + ext); err == nil && !fi.IsDir() {
+				return stem + ext
+			}
+		}
+	}
+	return ""
+}
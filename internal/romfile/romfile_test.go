@@ -0,0 +1,108 @@
+package romfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeZip(t *testing.T, path string, files map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZipEntriesAndRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "set.zip")
+	writeZip(t, path, map[string][]byte{
+		"readme.txt": []byte("x"),
+		"b.gbc":      {0x02},
+		"a.gb":       {0x01},
+	})
+	names, err := Entries(path)
+	if err != nil || len(names) != 2 || names[0] != "a.gb" || names[1] != "b.gbc" {
+		t.Fatalf("Entries got %v, %v", names, err)
+	}
+	if data, err := Read(path); err != nil || !bytes.Equal(data, []byte{0x01}) {
+		t.Fatalf("Read first entry got %v, %v", data, err)
+	}
+	if data, err := ReadEntry(path, "b.gbc"); err != nil || !bytes.Equal(data, []byte{0x02}) {
+		t.Fatalf("ReadEntry got %v, %v", data, err)
+	}
+	empty := filepath.Join(dir, "empty.zip")
+	writeZip(t, empty, map[string][]byte{"readme.txt": []byte("x")})
+	if _, err := Read(empty); err != ErrNoROM {
+		t.Fatalf("zip without ROM got %v want ErrNoROM", err)
+	}
+}
+
+func TestGzipRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.gb.gz")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte{0xAA, 0xBB})
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := Read(path); err != nil || !bytes.Equal(data, []byte{0xAA, 0xBB}) {
+		t.Fatalf("gzip Read got %v, %v", data, err)
+	}
+	if names, _ := Entries(path); len(names) != 1 || names[0] != "game.gb" {
+		t.Fatalf("gzip Entries got %v", names)
+	}
+}
+
+func TestSavePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"roms/game.gb":     "roms/game.sav",
+		"roms/game.gb.gz":  "roms/game.sav",
+		"roms/game.zip":    "roms/game.sav",
+		"roms/game.gbc":    "roms/game.gbc.sav",
+		"roms/game.gbc.gz": "roms/game.gbc.sav",
+	} {
+		if got := SavePath(in); got != want {
+			t.Errorf("SavePath(%q) got %q want %q", in, got, want)
+		}
+	}
+}
+
+func TestNamePath(t *testing.T) {
+	dir := t.TempDir()
+	single := filepath.Join(dir, "one.zip")
+	writeZip(t, single, map[string][]byte{"one.gb": {0x01}, "readme.txt": []byte("x")})
+	set := filepath.Join(dir, "set.zip")
+	writeZip(t, set, map[string][]byte{"a.gb": {0x01}, "sub/b.gbc": {0x02}})
+	for _, tc := range []struct{ path, entry, want, sav string }{
+		{single, "", "one.zip", "one.sav"},
+		{set, "a.gb", "set.a.gb", "set.a.sav"},
+		{set, "", "set.a.gb", "set.a.sav"}, // first ROM
+		{set, "sub/b.gbc", "set.sub_b.gbc", "set.sub_b.gbc.sav"},
+		{filepath.Join(dir, "game.gb.gz"), "", "game.gb.gz", "game.sav"},
+	} {
+		got := NamePath(tc.path, tc.entry)
+		if want := filepath.Join(dir, tc.want); got != want {
+			t.Errorf("NamePath(%q, %q) got %q want %q", tc.path, tc.entry, got, want)
+		}
+		if sav := filepath.Base(SavePath(got)); sav != tc.sav {
+			t.Errorf("SavePath(NamePath(%q, %q)) got %q want %q", tc.path, tc.entry, sav, tc.sav)
+		}
+	}
+}
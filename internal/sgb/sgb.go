@@ -0,0 +1,436 @@
+// Package sgb emulates the Super Game Boy side of the hardware: the command packets a
+// game sends through JOYP, the palettes and attribute map that color the Game Boy
+// screen, VRAM transfers, multiplayer joypad IDs and the 256x224 picture with the
+// border around the game.
+//
+// A packet is 16 bytes sent LSB first, one bit per JOYP write: P14 low (value $20)
+// sends a 0, P15 low ($10) a 1, each followed by both high ($30). A write with both
+// low ($00) starts the packet and a 0 bit after the 128 data bits ends it. The first
+// byte holds the command (bits 3-7) and the number of packets it spans (bits 0-2).
+package sgb
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Size of the SGB picture and where the Game Boy screen sits in it.
+const (
+	Width  = 256
+	Height = 224
+	GameX  = 48
+	GameY  = 40
+)
+
+// TransferSize is the number of bytes a VRAM transfer takes from the screen.
+const TransferSize = 0x1000
+
+// Commands, per Pan Docs (SGB Command Summary).
+const (
+	cmdPAL01   = 0x00
+	cmdPAL23   = 0x01
+	cmdPAL03   = 0x02
+	cmdPAL12   = 0x03
+	cmdATTRBLK = 0x04
+	cmdATTRLIN = 0x05
+	cmdATTRDIV = 0x06
+	cmdATTRCHR = 0x07
+	cmdPALSET  = 0x0A
+	cmdPALTRN  = 0x0B
+	cmdMLTREQ  = 0x11
+	cmdCHRTRN  = 0x13
+	cmdPCTTRN  = 0x14
+	cmdMASKEN  = 0x17
+)
+
+// MASK_EN modes.
+const (
+	maskOff    = 0
+	maskFreeze = 1
+	maskBlack  = 2
+	maskColor0 = 3
+)
+
+// defaultPalette is the palette the SGB BIOS starts games with (BGR555).
+var defaultPalette = [4]uint16{0x67BF, 0x265B, 0x10B5, 0x2866}
+
+// SGB holds the state of the Super Game Boy. Colors are stored as SNES BGR555.
+type SGB struct {
+	// packet receiver
+	ready  bool // JOYP went back to $30 since the last pulse
+	recv   bool // a packet is being received
+	nbits  int
+	packet [16]byte
+	cmd    []byte // packets received so far for the current command
+
+	players int // 1, 2 or 4, set by MLT_REQ
+	player  int // joypad ID returned while multiplayer is on
+	p15     bool
+
+	pal       [4][4]uint16   // palettes 0-3 for the game screen; color 0 is shared
+	sysPal    [512][4]uint16 // system palettes loaded by PAL_TRN, picked by PAL_SET
+	attr      [20 * 18]byte  // palette of each 8x8 cell of the game screen
+	mask      byte           // MASK_EN mode
+	tiles     [256 * 32]byte // border tiles (SNES 4bpp) from CHR_TRN
+	border    [32 * 28]uint16
+	borderPal [4][16]uint16 // border palettes 4-7 from PCT_TRN
+
+	xfer    bool // a VRAM transfer waits for the next frame
+	xferCmd byte
+	xferArg byte
+
+	screen [160 * 144]byte // last shown game screen as shades 0-3, kept while frozen
+}
+
+// New returns an SGB in its power-on state.
+func New() *SGB {
+	s := &SGB{}
+	s.Reset()
+	return s
+}
+
+// Reset restores the power-on state: default palettes, palette 0 everywhere, no border,
+// one player and no mask.
+func (s *SGB) Reset() {
+	*s = SGB{players: 1}
+	for i := range s.pal {
+		s.pal[i] = defaultPalette
+	}
+}
+
+// WriteJOYP feeds a JOYP write (only bits 4-5 matter) to the packet receiver and the
+// multiplayer joypad ID, which steps to the next player when P15 goes high.
+func (s *SGB) WriteJOYP(v byte) {
+	p15 := v&0x20 != 0
+	if p15 && !s.p15 && s.players > 1 {
+		s.player = (s.player + 1) % s.players
+	}
+	s.p15 = p15
+	switch v & 0x30 {
+	case 0x30:
+		s.ready = true
+	case 0x00:
+		s.recv, s.nbits, s.packet = true, 0, [16]byte{}
+		s.ready = false
+	default:
+		if !s.ready || !s.recv {
+			return
+		}
+		s.ready = false
+		one := v&0x30 == 0x10
+		if s.nbits == 128 {
+			s.recv = false
+			if !one {
+				s.packetDone()
+			}
+			return
+		}
+		if one {
+			s.packet[s.nbits/8] |= 1 << (s.nbits % 8)
+		}
+		s.nbits++
+	}
+}
+
+// JoypadID returns the low JOYP nibble while multiplayer is on and neither button
+// group is selected: $F for player 1, $E for player 2 and so on.
+func (s *SGB) JoypadID() (byte, bool) {
+	if s.players <= 1 {
+		return 0, false
+	}
+	return 0x0F - byte(s.player), true
+}
+
+// Player returns the joypad currently read by the game (0 for player 1).
+func (s *SGB) Player() int { return s.player }
+
+func (s *SGB) packetDone() {
+	s.cmd = append(s.cmd, s.packet[:]...)
+	n := int(s.cmd[0] & 0x07)
+	if n == 0 {
+		n = 1
+	}
+	if len(s.cmd) < n*16 {
+		return
+	}
+	s.execute(s.cmd)
+	s.cmd = nil
+}
+
+func (s *SGB) execute(d []byte) {
+	switch d[0] >> 3 {
+	case cmdPAL01:
+		s.setPalettes(d, 0, 1)
+	case cmdPAL23:
+		s.setPalettes(d, 2, 3)
+	case cmdPAL03:
+		s.setPalettes(d, 0, 3)
+	case cmdPAL12:
+		s.setPalettes(d, 1, 2)
+	case cmdATTRBLK:
+		s.attrBlock(d)
+	case cmdATTRLIN:
+		for i := 0; i < int(d[1]) && 2+i < len(d); i++ {
+			v := d[2+i]
+			line, pal := int(v&0x1F), (v>>5)&0x03
+			for j := 0; j < 20*18; j++ {
+				if (v&0x80 != 0 && j/20 == line) || (v&0x80 == 0 && j%20 == line) {
+					s.attr[j] = pal
+				}
+			}
+		}
+	case cmdATTRDIV:
+		at := int(d[2])
+		for j := range s.attr {
+			c := j % 20
+			if d[1]&0x40 != 0 {
+				c = j / 20
+			}
+			switch {
+			case c < at:
+				s.attr[j] = (d[1] >> 2) & 0x03
+			case c == at:
+				s.attr[j] = (d[1] >> 4) & 0x03
+			default:
+				s.attr[j] = d[1] & 0x03
+			}
+		}
+	case cmdATTRCHR:
+		x, y := int(d[1]), int(d[2])
+		n := int(d[3]) | int(d[4])<<8
+		for i := 0; i < n && i < 20*18 && 6+i/4 < len(d) && x < 20 && y < 18; i++ {
+			s.attr[y*20+x] = (d[6+i/4] >> (6 - 2*(i%4))) & 0x03
+			if d[5] == 0 {
+				if x++; x == 20 {
+					x, y = 0, y+1
+				}
+			} else if y++; y == 18 {
+				x, y = x+1, 0
+			}
+		}
+	case cmdPALSET:
+		for i := range s.pal {
+			s.pal[i] = s.sysPal[(int(d[1+2*i])|int(d[2+2*i])<<8)&0x1FF]
+		}
+		s.sharePalette0()
+		// Attribute files (ATTR_TRF) are not emulated; bit 6 still releases the mask
+		if d[9]&0x40 != 0 {
+			s.mask = maskOff
+		}
+	case cmdPALTRN, cmdCHRTRN, cmdPCTTRN:
+		s.xfer, s.xferCmd, s.xferArg = true, d[0]>>3, d[1]
+	case cmdMLTREQ:
+		s.players = [4]int{1, 2, 1, 4}[d[1]&0x03]
+		s.player = 0
+	case cmdMASKEN:
+		s.mask = d[1] & 0x03
+	}
+}
+
+// setPalettes handles PAL01/23/03/12: a shared color 0, then colors 1-3 of two palettes.
+func (s *SGB) setPalettes(d []byte, a, b int) {
+	color := func(i int) uint16 { return uint16(d[1+2*i]) | uint16(d[2+2*i])<<8 }
+	s.pal[0][0] = color(0)
+	for c := 1; c < 4; c++ {
+		s.pal[a][c] = color(c)
+		s.pal[b][c] = color(c + 3)
+	}
+	s.sharePalette0()
+}
+
+// sharePalette0 copies color 0 of palette 0 to the other palettes, as the SGB uses one
+// backdrop color for all of them.
+func (s *SGB) sharePalette0() {
+	for i := 1; i < 4; i++ {
+		s.pal[i][0] = s.pal[0][0]
+	}
+}
+
+// attrBlock handles ATTR_BLK: data sets of 6 bytes, each coloring the inside, the
+// surrounding line and the outside of a rectangle of cells.
+func (s *SGB) attrBlock(d []byte) {
+	for i := 0; i < int(d[1]) && 2+i*6+5 < len(d); i++ {
+		b := d[2+i*6:]
+		in, line, out := b[0]&1 != 0, b[0]&2 != 0, b[0]&4 != 0
+		pIn, pLine, pOut := b[1]&0x03, (b[1]>>2)&0x03, (b[1]>>4)&0x03
+		// A lone inside or outside flag also colors the line with that palette
+		if in && !line && !out {
+			line, pLine = true, pIn
+		} else if out && !line && !in {
+			line, pLine = true, pOut
+		}
+		x1, y1, x2, y2 := int(b[2]), int(b[3]), int(b[4]), int(b[5])
+		for j := range s.attr {
+			x, y := j%20, j/20
+			switch {
+			case x > x1 && x < x2 && y > y1 && y < y2:
+				if in {
+					s.attr[j] = pIn
+				}
+			case x >= x1 && x <= x2 && y >= y1 && y <= y2:
+				if line {
+					s.attr[j] = pLine
+				}
+			default:
+				if out {
+					s.attr[j] = pOut
+				}
+			}
+		}
+	}
+}
+
+// TransferPending reports whether PAL_TRN, CHR_TRN or PCT_TRN waits for the screen data;
+// the caller passes it to Transfer once the next frame has been displayed.
+func (s *SGB) TransferPending() bool { return s.xfer }
+
+// Transfer completes a pending VRAM transfer with the 4 KiB the Game Boy shows on
+// screen: the first 256 BG tiles in map order, as the SGB reads them from the LCD.
+func (s *SGB) Transfer(data []byte) {
+	if !s.xfer || len(data) < TransferSize {
+		return
+	}
+	s.xfer = false
+	switch s.xferCmd {
+	case cmdPALTRN:
+		for i := range s.sysPal {
+			for c := range s.sysPal[i] {
+				o := i*8 + c*2
+				s.sysPal[i][c] = uint16(data[o]) | uint16(data[o+1])<<8
+			}
+		}
+	case cmdCHRTRN:
+		copy(s.tiles[int(s.xferArg&1)*128*32:], data[:TransferSize])
+	case cmdPCTTRN:
+		for i := range s.border {
+			s.border[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+		}
+		for p := range s.borderPal {
+			for c := range s.borderPal[p] {
+				o := 0x800 + p*32 + c*2
+				s.borderPal[p][c] = uint16(data[o]) | uint16(data[o+1])<<8
+			}
+		}
+	}
+}
+
+// Render draws the SGB picture into dst (Width x Height RGBA): the border, and inside
+// it the game screen colored through the attribute map. game is the 160x144 RGBA
+// frame in the four DMG gray levels; with the screen frozen by MASK_EN it is ignored.
+func (s *SGB) Render(dst, game []byte) {
+	if s.mask != maskFreeze {
+		for i := range s.screen {
+			s.screen[i] = shade(game[i*4])
+		}
+	}
+	back := s.pal[0][0]
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			color := back
+			gx, gy := x-GameX, y-GameY
+			if gx >= 0 && gx < 160 && gy >= 0 && gy < 144 {
+				switch s.mask {
+				case maskBlack:
+					color = 0
+				case maskColor0:
+				default:
+					color = s.pal[s.attr[(gy/8)*20+gx/8]][s.screen[gy*160+gx]]
+				}
+			}
+			if c, ok := s.borderPixel(x, y); ok {
+				color = c
+			}
+			i := (y*Width + x) * 4
+			dst[i], dst[i+1], dst[i+2] = rgb(color)
+			dst[i+3] = 0xFF
+		}
+	}
+}
+
+// borderPixel returns the border color at (x, y), or false where the border is transparent.
+func (s *SGB) borderPixel(x, y int) (uint16, bool) {
+	e := s.border[(y/8)*32+x/8]
+	col, row := x%8, y%8
+	if e&0x4000 != 0 {
+		col = 7 - col
+	}
+	if e&0x8000 != 0 {
+		row = 7 - row
+	}
+	t := s.tiles[int(e&0xFF)*32:]
+	bit := 7 - col
+	ci := (t[row*2]>>bit)&1 | (t[row*2+1]>>bit)&1<<1 | (t[16+row*2]>>bit)&1<<2 | (t[16+row*2+1]>>bit)&1<<3
+	if ci == 0 {
+		return 0, false
+	}
+	return s.borderPal[(e>>10)&0x03][ci], true
+}
+
+// shade maps a DMG gray level back to its shade number (0 lightest .. 3 darkest).
+func shade(gray byte) byte {
+	switch {
+	case gray >= 0xE0:
+		return 0
+	case gray >= 0x90:
+		return 1
+	case gray >= 0x30:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// rgb expands a BGR555 color to 8 bits per channel.
+func rgb(c uint16) (r, g, b byte) {
+	ex := func(v uint16) byte { v &= 0x1F; return byte(v<<3 | v>>2) }
+	return ex(c), ex(c >> 5), ex(c >> 10)
+}
+
+// --- Save/Load state ---
+type sgbState struct {
+	Ready, Recv bool
+	NBits       int
+	Packet      [16]byte
+	Cmd         []byte
+	Players     int
+	Player      int
+	P15         bool
+	Pal         [4][4]uint16
+	SysPal      [512][4]uint16
+	Attr        [20 * 18]byte
+	Mask        byte
+	Tiles       [256 * 32]byte
+	Border      [32 * 28]uint16
+	BorderPal   [4][16]uint16
+	Xfer        bool
+	XferCmd     byte
+	XferArg     byte
+	Screen      [160 * 144]byte
+}
+
+func (s *SGB) SaveState() []byte {
+	var buf bytes.Buffer
+	_ = gob.NewEncoder(&buf).Encode(sgbState{
+		Ready: s.ready, Recv: s.recv, NBits: s.nbits, Packet: s.packet, Cmd: s.cmd,
+		Players: s.players, Player: s.player, P15: s.p15,
+		Pal: s.pal, SysPal: s.sysPal, Attr: s.attr, Mask: s.mask,
+		Tiles: s.tiles, Border: s.border, BorderPal: s.borderPal,
+		Xfer: s.xfer, XferCmd: s.xferCmd, XferArg: s.xferArg, Screen: s.screen,
+	})
+	return buf.Bytes()
+}
+
+func (s *SGB) LoadState(data []byte) {
+	var st sgbState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&st); err != nil {
+		return
+	}
+	s.ready, s.recv, s.nbits, s.packet, s.cmd = st.Ready, st.Recv, st.NBits, st.Packet, st.Cmd
+	s.players, s.player, s.p15 = st.Players, st.Player, st.P15
+	if s.players < 1 {
+		s.players = 1
+	}
+	s.pal, s.sysPal, s.attr, s.mask = st.Pal, st.SysPal, st.Attr, st.Mask
+	s.tiles, s.border, s.borderPal = st.Tiles, st.Border, st.BorderPal
+	s.xfer, s.xferCmd, s.xferArg, s.screen = st.Xfer, st.XferCmd, st.XferArg, st.Screen
+}
@@ -0,0 +1,111 @@
+package sgb
+
+import "testing"
+
+// send transmits packets through JOYP as a game does.
+func send(s *SGB, packets ...[16]byte) {
+	for _, p := range packets {
+		s.WriteJOYP(0x00)
+		s.WriteJOYP(0x30)
+		for i := 0; i < 128; i++ {
+			if p[i/8]>>(i%8)&1 != 0 {
+				s.WriteJOYP(0x10)
+			} else {
+				s.WriteJOYP(0x20)
+			}
+			s.WriteJOYP(0x30)
+		}
+		s.WriteJOYP(0x20) // stop bit
+		s.WriteJOYP(0x30)
+	}
+}
+
+func TestSGB_PAL01AndAttrBlk(t *testing.T) {
+	s := New()
+	send(s, [16]byte{cmdPAL01<<3 | 1, 0x1F, 0x00, 1, 0, 2, 0, 3, 0, 0xE0, 0x03, 5, 0, 6, 0})
+	if s.pal[0] != [4]uint16{0x001F, 1, 2, 3} || s.pal[1] != [4]uint16{0x001F, 0x03E0, 5, 6} {
+		t.Fatalf("palettes after PAL01: %04X %04X", s.pal[0], s.pal[1])
+	}
+	if s.pal[3][0] != 0x001F {
+		t.Errorf("color 0 not shared: %04X", s.pal[3][0])
+	}
+	// Inside only (cells 1..3 x 1..3 exclusive inside, line included): palette 1
+	send(s, [16]byte{cmdATTRBLK<<3 | 1, 1, 0x01, 0x01, 1, 1, 3, 3})
+	for _, tc := range []struct {
+		x, y int
+		want byte
+	}{{2, 2, 1}, {1, 1, 1}, {3, 2, 1}, {0, 0, 0}, {4, 2, 0}} {
+		if got := s.attr[tc.y*20+tc.x]; got != tc.want {
+			t.Errorf("attr(%d,%d)=%d want %d", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	game := make([]byte, 160*144*4)
+	for i := range game {
+		game[i] = 0xFF
+	}
+	game[(16*160+16)*4] = 0x00 // darkest shade at cell (2,2)
+	dst := make([]byte, Width*Height*4)
+	s.Render(dst, game)
+	px := func(x, y int) [3]byte {
+		i := (y*Width + x) * 4
+		return [3]byte{dst[i], dst[i+1], dst[i+2]}
+	}
+	if got := px(GameX+16, GameY+16); got != [3]byte{0x31, 0x00, 0x00} {
+		t.Errorf("palette 1 color 3 rendered as %v", got)
+	}
+	if got := px(0, 0); got != [3]byte{0xFF, 0x00, 0x00} {
+		t.Errorf("backdrop rendered as %v", got)
+	}
+}
+
+func TestSGB_MultiplayerID(t *testing.T) {
+	s := New()
+	if _, ok := s.JoypadID(); ok {
+		t.Fatal("multiplayer on at power-on")
+	}
+	send(s, [16]byte{cmdMLTREQ<<3 | 1, 0x01})
+	id, ok := s.JoypadID()
+	if !ok || id != 0x0F {
+		t.Fatalf("after MLT_REQ: id=%X ok=%t", id, ok)
+	}
+	s.WriteJOYP(0x10)
+	s.WriteJOYP(0x30) // P15 rising steps to player 2
+	if id, _ := s.JoypadID(); id != 0x0E || s.Player() != 1 {
+		t.Fatalf("after P15 pulse: id=%X player=%d", id, s.Player())
+	}
+	s.WriteJOYP(0x10)
+	s.WriteJOYP(0x30)
+	if s.Player() != 0 {
+		t.Fatalf("two players should wrap, player=%d", s.Player())
+	}
+}
+
+func TestSGB_BorderTransfer(t *testing.T) {
+	s := New()
+	send(s, [16]byte{cmdCHRTRN<<3 | 1, 0})
+	if !s.TransferPending() {
+		t.Fatal("CHR_TRN did not wait for a transfer")
+	}
+	data := make([]byte, TransferSize)
+	data[32] = 0xFF // tile 1, row 0: plane 0 set -> color 1
+	s.Transfer(data)
+
+	data = make([]byte, TransferSize)
+	data[0], data[1] = 0x01, 0x10 // map (0,0): tile 1, palette 4
+	data[0x802], data[0x803] = 0x00, 0x7C
+	send(s, [16]byte{cmdPCTTRN<<3 | 1})
+	s.Transfer(data)
+	if s.TransferPending() {
+		t.Fatal("transfer still pending")
+	}
+
+	dst := make([]byte, Width*Height*4)
+	s.Render(dst, make([]byte, 160*144*4))
+	if dst[0] != 0x00 || dst[2] != 0xFF {
+		t.Errorf("border pixel = %v, want blue", dst[:3])
+	}
+	if i := Width * 4; dst[i+2] == 0xFF && dst[i] == 0x00 {
+		t.Errorf("row 1 of tile 1 should be transparent")
+	}
+}
@@ -0,0 +1,291 @@
+// Package symbols reads RGBDS symbol files so tools can show labels instead of raw
+// addresses. A .sym file lists one "bank:addr name" per line, e.g. "01:4A2F Main.loop";
+// a .map file from rgblink -m lists "$addr = name" under "ROMX bank #n:" headings.
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/romfile"
+)
+
+// AnyBank asks Lookup for a symbol at an address regardless of its bank.
+const AnyBank = -1
+
+// Symbol is one named address.
+type Symbol struct {
+	Bank int
+	Addr uint16
+	Name string
+}
+
+type location struct {
+	bank int
+	addr uint16
+}
+
+// Table maps addresses to names and back. The zero value is an empty table.
+type Table struct {
+	byLoc  map[location]string
+	byAddr map[uint16]string // first symbol at an address in any bank
+	byName map[string]Symbol
+	syms   []Symbol
+
+	// sorted views for Describe, rebuilt after Add
+	byBank map[int][]Symbol
+	all    []Symbol
+}
+
+// Add records a symbol. When several names share an address the first one wins for
+// Lookup; all of them can be found by name.
+func (t *Table) Add(s Symbol) {
+	if t.byLoc == nil {
+		t.byLoc = make(map[location]string)
+		t.byAddr = make(map[uint16]string)
+		t.byName = make(map[string]Symbol)
+	}
+	loc := location{s.Bank, s.Addr}
+	if _, ok := t.byLoc[loc]; !ok {
+		t.byLoc[loc] = s.Name
+	}
+	if _, ok := t.byAddr[s.Addr]; !ok {
+		t.byAddr[s.Addr] = s.Name
+	}
+	t.byName[s.Name] = s
+	t.syms = append(t.syms, s)
+	t.byBank, t.all = nil, nil
+}
+
+// Len returns the number of symbols.
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.syms)
+}
+
+// Symbols returns all symbols ordered by bank and address.
+func (t *Table) Symbols() []Symbol {
+	if t == nil {
+		return nil
+	}
+	out := append([]Symbol(nil), t.syms...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Bank != out[j].Bank {
+			return out[i].Bank < out[j].Bank
+		}
+		return out[i].Addr < out[j].Addr
+	})
+	return out
+}
+
+// Lookup returns the name at addr in bank. The fixed ROM bank (0000-3FFF) is always
+// bank 0; outside ROM, or with AnyBank, a symbol in any bank matches when the exact
+// bank has none, since the caller rarely knows the mapped RAM bank.
+func (t *Table) Lookup(bank int, addr uint16) (string, bool) {
+	if t == nil || t.byLoc == nil {
+		return "", false
+	}
+	if addr < 0x4000 {
+		bank = 0
+	}
+	if name, ok := t.byLoc[location{bank, addr}]; ok {
+		return name, true
+	}
+	if bank == AnyBank || addr >= 0x8000 {
+		name, ok := t.byAddr[addr]
+		return name, ok
+	}
+	return "", false
+}
+
+// Find returns the symbol with the given name.
+func (t *Table) Find(name string) (Symbol, bool) {
+	if t == nil || t.byName == nil {
+		return Symbol{}, false
+	}
+	s, ok := t.byName[name]
+	return s, ok
+}
+
+// ParseSym reads an RGBDS .sym file. Blank lines and ';' comments are skipped.
+func ParseSym(r io.Reader) (*Table, error) {
+	t := &Table{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return nil, fmt.Errorf("symbols: line %d: want \"bank:addr name\"", n)
+		}
+		b, a, ok := strings.Cut(f[0], ":")
+		if !ok {
+			return nil, fmt.Errorf("symbols: line %d: want \"bank:addr name\"", n)
+		}
+		bank, err := strconv.ParseUint(b, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("symbols: line %d: bad bank %q", n, b)
+		}
+		addr, err := strconv.ParseUint(a, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("symbols: line %d: bad address %q", n, a)
+		}
+		t.Add(Symbol{Bank: int(bank), Addr: uint16(addr), Name: f[1]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadSym reads a .sym file from disk.
+func LoadSym(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSym(f)
+}
+
+// Describe names addr as "Label" or "Label+$N" using the closest symbol at or below it
+// in the same memory area; ROM addresses only match symbols of the given bank. Without
+// a symbol it returns "$XXXX".
+func (t *Table) Describe(bank int, addr uint16) string {
+	if s, ok := t.Nearest(bank, addr); ok {
+		if s.Addr == addr {
+			return s.Name
+		}
+		return fmt.Sprintf("%s+$%X", s.Name, addr-s.Addr)
+	}
+	return fmt.Sprintf("$%04X", addr)
+}
+
+// Nearest returns the closest symbol at or below addr in the same memory area (see Describe).
+func (t *Table) Nearest(bank int, addr uint16) (Symbol, bool) {
+	if t.Len() == 0 {
+		return Symbol{}, false
+	}
+	t.index()
+	list := t.all
+	if addr < 0x8000 {
+		if addr < 0x4000 {
+			bank = 0
+		}
+		list = t.byBank[bank]
+	}
+	i := sort.Search(len(list), func(i int) bool { return list[i].Addr > addr }) - 1
+	if i < 0 || area(list[i].Addr) != area(addr) {
+		return Symbol{}, false
+	}
+	// Prefer the first name at that address, as Lookup does
+	for i > 0 && list[i-1].Addr == list[i].Addr {
+		i--
+	}
+	return list[i], true
+}
+
+func (t *Table) index() {
+	if t.all != nil {
+		return
+	}
+	t.byBank = make(map[int][]Symbol)
+	for _, s := range t.syms {
+		t.byBank[s.Bank] = append(t.byBank[s.Bank], s)
+		t.all = append(t.all, s)
+	}
+	byAddr := func(l []Symbol) {
+		sort.SliceStable(l, func(i, j int) bool { return l[i].Addr < l[j].Addr })
+	}
+	for _, l := range t.byBank {
+		byAddr(l)
+	}
+	byAddr(t.all)
+}
+
+// area returns the start of the memory area addr belongs to, so offsets never reach
+// from one area into the next (e.g. from the last WRAM label into HRAM).
+func area(addr uint16) uint16 {
+	switch {
+	case addr < 0x4000:
+		return 0x0000
+	case addr < 0x8000:
+		return 0x4000
+	case addr < 0xA000:
+		return 0x8000
+	case addr < 0xC000:
+		return 0xA000
+	case addr < 0xD000:
+		return 0xC000
+	case addr < 0xE000:
+		return 0xD000
+	case addr < 0xFF00:
+		return 0xE000
+	case addr < 0xFF80:
+		return 0xFF00
+	default:
+		return 0xFF80
+	}
+}
+
+var (
+	mapBankRe   = regexp.MustCompile(`^\s*(ROM0|ROMX|VRAM|SRAM|WRAM0|WRAMX|OAM|HRAM) bank #(\d+):`)
+	mapSymbolRe = regexp.MustCompile(`^\s*\$([0-9A-Fa-f]{1,4}) = (\S+)`)
+)
+
+// ParseMap reads the symbols from an rgblink .map file. Section and summary lines are
+// ignored; each symbol takes the bank of the heading it appears under.
+func ParseMap(r io.Reader) (*Table, error) {
+	t := &Table{}
+	bank := 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := mapBankRe.FindStringSubmatch(line); m != nil {
+			b, _ := strconv.Atoi(m[2])
+			bank = b
+			continue
+		}
+		if m := mapSymbolRe.FindStringSubmatch(line); m != nil {
+			addr, _ := strconv.ParseUint(m[1], 16, 16)
+			t.Add(Symbol{Bank: bank, Addr: uint16(addr), Name: m[2]})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Load reads a .sym or .map file, chosen by extension.
+func Load(path string) (*Table, error) {
+	if strings.EqualFold(filepath.Ext(path), ".map") {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ParseMap(f)
+	}
+	return LoadSym(path)
+}
+
+// Find returns the symbol file next to a ROM or ROM archive ("game.sym", then
+// "game.map"), or "" when there is none.
+func Find(romPath string) string {
+	return romfile.FindSibling(romPath, ".sym", ".map")
+}
@@ -0,0 +1,115 @@
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sample = `; File generated by rgblink
+00:0150 Main
+00:0150 Main.entry
+01:4000 BankedFunc
+02:4000 OtherBank
+00:C000 wCounter
+01:D000 wBuffer
+`
+
+func TestParseSym_Lookup(t *testing.T) {
+	tab, err := ParseSym(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tab.Len() != 6 {
+		t.Fatalf("len %d", tab.Len())
+	}
+	if s, ok := tab.Lookup(5, 0x0150); !ok || s != "Main" {
+		t.Fatalf("bank-0 lookup got %q %v", s, ok)
+	}
+	if s, _ := tab.Lookup(2, 0x4000); s != "OtherBank" {
+		t.Fatalf("banked lookup got %q", s)
+	}
+	if _, ok := tab.Lookup(3, 0x4000); ok {
+		t.Fatalf("unmapped bank matched")
+	}
+	if s, _ := tab.Lookup(AnyBank, 0x4000); s != "BankedFunc" {
+		t.Fatalf("any-bank lookup got %q", s)
+	}
+	// RAM symbols match whatever bank the caller passes
+	if s, _ := tab.Lookup(0, 0xD000); s != "wBuffer" {
+		t.Fatalf("WRAMX lookup got %q", s)
+	}
+	if sym, ok := tab.Find("Main.entry"); !ok || sym.Addr != 0x0150 {
+		t.Fatalf("Find got %+v %v", sym, ok)
+	}
+	if _, err := ParseSym(strings.NewReader("zz:0150 Bad\n")); err == nil {
+		t.Fatalf("bad bank accepted")
+	}
+}
+
+const sampleMap = `SUMMARY:
+	ROM0: 336 bytes used / 16048 free
+
+ROM0 bank #0:
+	SECTION: $0150-$015F ($0010 bytes) ["Main"]
+	         $0150 = Main
+	         $0158 = Main.loop
+	EMPTY: $3EA0 bytes
+
+ROMX bank #2:
+	SECTION: $4000-$40FF ($0100 bytes) ["Data"]
+	         $4000 = LevelData
+
+WRAM0 bank #0:
+	SECTION: $C000-$C0FF ($0100 bytes) ["Vars"]
+	         $C000 = wScore
+`
+
+func TestParseMap_Describe(t *testing.T) {
+	tab, err := ParseMap(strings.NewReader(sampleMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tab.Len() != 4 {
+		t.Fatalf("len %d", tab.Len())
+	}
+	if s, ok := tab.Find("LevelData"); !ok || s.Bank != 2 || s.Addr != 0x4000 {
+		t.Fatalf("LevelData got %+v", s)
+	}
+	for _, tc := range []struct {
+		bank int
+		addr uint16
+		want string
+	}{
+		{1, 0x0150, "Main"},
+		{1, 0x015A, "Main.loop+$2"},
+		{2, 0x4010, "LevelData+$10"},
+		{3, 0x4010, "$4010"}, // LevelData is in bank 2
+		{0, 0xC004, "wScore+$4"},
+		{0, 0xFF80, "$FF80"}, // HRAM is a different area from WRAM
+	} {
+		if got := tab.Describe(tc.bank, tc.addr); got != tc.want {
+			t.Errorf("Describe(%d, %04X) got %q want %q", tc.bank, tc.addr, got, tc.want)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "game.gbc")
+	if got := Find(rom); got != "" {
+		t.Fatalf("Find without file got %q", got)
+	}
+	sym := filepath.Join(dir, "game.sym")
+	if err := os.WriteFile(sym, []byte("00:0150 Main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Find(rom); got != sym {
+		t.Fatalf("Find got %q want %q", got, sym)
+	}
+	tab, err := Load(sym)
+	if err != nil || tab.Len() != 1 {
+		t.Fatalf("Load: %v len %d", err, tab.Len())
+	}
+}
@@ -0,0 +1,132 @@
+// Package trace writes CPU trace logs in the gameboy-doctor format and compares them
+// against reference logs. Each line is the CPU state before one instruction runs:
+//
+//	A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+//
+// gameboy-doctor expects LY (FF44) to read $90; see bus.SetLYOverride.
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+)
+
+// DoctorLY is the value gameboy-doctor reference logs assume LY always reads as.
+const DoctorLY = 0x90
+
+// Line formats the CPU state as one gameboy-doctor line (without newline).
+func Line(c *cpu.CPU) string {
+	b := c.Bus()
+	pc := c.PC
+	return fmt.Sprintf("A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X",
+		c.A, c.F&0xF0, c.B, c.C, c.D, c.E, c.H, c.L, c.SP, pc,
+		b.Peek(pc), b.Peek(pc+1), b.Peek(pc+2), b.Peek(pc+3))
+}
+
+// Doctor is a cpu.Tracer that writes one line per instruction. Writes are unbuffered;
+// wrap w in a bufio.Writer for speed and flush it when done.
+type Doctor struct {
+	w   io.Writer
+	err error
+}
+
+// NewDoctor returns a tracer writing to w.
+func NewDoctor(w io.Writer) *Doctor { return &Doctor{w: w} }
+
+// Trace implements cpu.Tracer. After the first write error it stops writing.
+func (d *Doctor) Trace(c *cpu.CPU) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = io.WriteString(d.w, Line(c)+"\n")
+}
+
+// Err returns the first write error.
+func (d *Doctor) Err() error { return d.err }
+
+// Divergence describes where two logs first differ.
+type Divergence struct {
+	Line    int      // 1-based line number of the first difference
+	Ours    string   // our line, "" if our log ended first
+	Ref     string   // the reference line, "" if it ended first
+	Field   string   // first differing field name (e.g. "PC"), "" if a log ended
+	Context []string // up to the requested number of matching lines before it
+}
+
+func (d *Divergence) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "first divergence at line %d", d.Line)
+	if d.Field != "" {
+		fmt.Fprintf(&sb, " (%s differs)", d.Field)
+	}
+	sb.WriteString(":\n")
+	for i, l := range d.Context {
+		fmt.Fprintf(&sb, "  %6d   %s\n", d.Line-len(d.Context)+i, l)
+	}
+	show := func(tag, l string) {
+		if l == "" {
+			l = "<end of log>"
+		}
+		fmt.Fprintf(&sb, "  %s %s\n", tag, l)
+	}
+	show("ours  >", d.Ours)
+	show("ref   >", d.Ref)
+	return sb.String()
+}
+
+// Diff compares our log with a reference log line by line and returns the first
+// divergence, or nil when they match. Blank lines and surrounding whitespace are
+// ignored; context is the number of preceding lines to keep.
+func Diff(ours, ref io.Reader, context int) (*Divergence, error) {
+	a, b := bufio.NewScanner(ours), bufio.NewScanner(ref)
+	var ctx []string
+	next := func(s *bufio.Scanner) (string, bool) {
+		for s.Scan() {
+			if l := strings.TrimSpace(s.Text()); l != "" {
+				return l, true
+			}
+		}
+		return "", false
+	}
+	for n := 1; ; n++ {
+		la, okA := next(a)
+		lb, okB := next(b)
+		if err := a.Err(); err != nil {
+			return nil, err
+		}
+		if err := b.Err(); err != nil {
+			return nil, err
+		}
+		if !okA && !okB {
+			return nil, nil
+		}
+		if la != lb {
+			return &Divergence{Line: n, Ours: la, Ref: lb, Field: firstField(la, lb), Context: ctx}, nil
+		}
+		if context > 0 {
+			if len(ctx) == context {
+				ctx = append(ctx[:0], ctx[1:]...)
+			}
+			ctx = append(ctx, la)
+		}
+	}
+}
+
+// firstField names the first "NAME:value" field that differs between two lines.
+func firstField(a, b string) string {
+	if a == "" || b == "" {
+		return ""
+	}
+	fa, fb := strings.Fields(a), strings.Fields(b)
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		if fa[i] != fb[i] {
+			name, _, _ := strings.Cut(fb[i], ":")
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/bus"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/cpu"
+)
+
+func TestDoctor_LinesBeforeEachInstruction(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{0x00, 0x3E, 0x12, 0xF0, 0x44}) // NOP; LD A,$12; LDH A,[$44]
+	b := bus.New(rom)
+	b.SetLYOverride(DoctorLY)
+	c := cpu.New(b)
+	c.PC = 0x100
+	c.F = 0xB5 // low nibble must not show up
+	var out bytes.Buffer
+	c.SetTracer(NewDoctor(&out))
+	for i := 0; i < 3; i++ {
+		c.Step()
+	}
+	if c.A != 0x90 {
+		t.Fatalf("LY read %02X, want 90", c.A)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"A:00 F:B0 B:00 C:00 D:00 E:00 H:00 L:00 SP:FFFE PC:0100 PCMEM:00,3E,12,F0",
+		"A:00 F:B0 B:00 C:00 D:00 E:00 H:00 L:00 SP:FFFE PC:0101 PCMEM:3E,12,F0,44",
+		"A:12 F:B0 B:00 C:00 D:00 E:00 H:00 L:00 SP:FFFE PC:0103 PCMEM:F0,44,00,00",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d:\n got %s\nwant %s", i+1, lines[i], want[i])
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ref := "A:01 PC:0100\nA:01 PC:0101\nA:02 PC:0102\nA:03 PC:0103\n"
+	if d, err := Diff(strings.NewReader(ref), strings.NewReader(ref+"\n"), 2); err != nil || d != nil {
+		t.Fatalf("identical logs: %v %v", d, err)
+	}
+	ours := "A:01 PC:0100\nA:01 PC:0101\nA:02 PC:0102\nA:03 PC:0104\n"
+	d, err := Diff(strings.NewReader(ours), strings.NewReader(ref), 2)
+	if err != nil || d == nil {
+		t.Fatalf("want divergence, got %v %v", d, err)
+	}
+	if d.Line != 4 || d.Field != "PC" || len(d.Context) != 2 || d.Context[0] != "A:01 PC:0101" {
+		t.Fatalf("divergence = %+v", d)
+	}
+	if !strings.Contains(d.String(), "line 4 (PC differs)") {
+		t.Fatalf("report:\n%s", d)
+	}
+
+	d, _ = Diff(strings.NewReader(ref[:26]), strings.NewReader(ref), 0)
+	if d == nil || d.Line != 3 || d.Ours != "" || d.Field != "" || d.Context != nil {
+		t.Fatalf("short log: %+v", d)
+	}
+}
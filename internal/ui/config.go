@@ -10,7 +10,8 @@ type Config struct {
 	AudioBufferMs   int    // initial desired buffer in ms (approx)
 	AudioLowLatency bool   // hard-cap buffering for minimal latency
 	ROMsDir         string // directory to browse for ROMs
-	UseFetcherBG    bool   // render BG via fetcher/FIFO
+	UseFetcherBG    bool   // classic renderer with BG via fetcher/FIFO
+	ClassicPPU      bool   // draw frames from per-line snapshots instead of the PPU's pixel FIFO
 	// Visual effects
 	LCDShader    bool   // legacy: apply LCD-like shader (deprecated; use ShaderPreset)
 	ShaderPreset string // off|lcd|crt|ghost|dot
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/emu"
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/romfile"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -55,9 +56,11 @@ type App struct {
 	currentSlot int // 0..9
 
 	// rom picker state
-	romList []string
-	romSel  int
-	romOff  int // scroll offset for ROM list
+	romList    []string
+	romSel     int
+	romOff     int    // scroll offset for ROM list
+	romArchive string // zip whose entries romList shows; "" when listing files
+	romFiles   []string
 
 	// keybindings state
 	keysOff int // scroll offset for keybindings
@@ -92,9 +95,10 @@ func NewApp(cfg Config, m *emu.Machine) *App {
 	cfg = loadSettings(cfg)
 	cfg.Defaults()
 	ebiten.SetWindowTitle(cfg.Title)
-	ebiten.SetWindowSize(160*cfg.Scale, 144*cfg.Scale)
+	w, h := m.FrameSize()
+	ebiten.SetWindowSize(w*cfg.Scale, h*cfg.Scale)
 	a := &App{cfg: cfg, m: m}
-	a.curW, a.curH = 160, 144
+	a.curW, a.curH = w, h
 	a.lastTime = time.Now()
 	a.frameAcc = 0
 	a.turbo = 1
@@ -135,6 +139,7 @@ func NewApp(cfg Config, m *emu.Machine) *App {
 	// Propagate rendering config into emulator
 	if m != nil {
 		m.SetUseFetcherBG(a.cfg.UseFetcherBG)
+		m.SetClassicPPU(a.cfg.ClassicPPU)
 	}
 	// discover available skins and align selected index
 	a.shellList = a.findSkins()
@@ -202,6 +207,7 @@ func (a *App) Update() error {
 			btn.Select = true
 		}
 		a.m.SetButtons(btn)
+		a.updateTilt()
 	} else {
 		a.m.SetButtons(emu.Buttons{})
 	}
@@ -372,6 +378,10 @@ func (a *App) Update() error {
 		}
 	}
 
+	// A network link partner must not wait for frames while this side is not stepping
+	if a.m != nil {
+		a.m.PauseSerial(a.showMenu || a.paused)
+	}
 	// Emulation pacing: run at ~59.7275 FPS using a time accumulator, decoupled from Ebiten's ~60Hz
 	if !a.showMenu && !a.paused {
 		now := time.Now()
@@ -478,14 +488,17 @@ func (a *App) Draw(screen *ebiten.Image) {
 		}
 	}
 
-	// Draw the 160x144 game framebuffer; center it within current logical screen
+	// Draw the game framebuffer (160x144, or 256x224 with the SGB border); center it
+	// within current logical screen
 	oW, oH := screen.Bounds().Dx(), screen.Bounds().Dy()
-	if a.tex == nil {
-		a.tex = ebiten.NewImage(160, 144)
+	fw, fh := a.m.FrameSize()
+	if a.tex == nil || a.tex.Bounds().Dx() != fw || a.tex.Bounds().Dy() != fh {
+		a.tex = ebiten.NewImage(fw, fh)
+		a.ghostTex = nil
 	}
 	a.tex.WritePixels(a.m.Framebuffer())
-	dx := (oW - 160) / 2
-	dy := (oH - 144) / 2
+	dx := (oW - fw) / 2
+	dy := (oH - fh) / 2
 	// Optional subtle vertical jitter for retro feel
 	jitterOffset := 0.0
 	if a.cfg.Jitter {
@@ -514,11 +527,11 @@ func (a *App) Draw(screen *ebiten.Image) {
 				op.Images[1] = a.ghostTex
 			}
 			// uniforms may be added later for tuning
-			screen.DrawRectShader(160, 144, a.shader, op)
+			screen.DrawRectShader(fw, fh, a.shader, op)
 			// Update previous-frame buffer for ghosting
 			if a.cfg.ShaderPreset == "ghost" {
 				if a.ghostTex == nil {
-					a.ghostTex = ebiten.NewImage(160, 144)
+					a.ghostTex = ebiten.NewImage(fw, fh)
 				}
 				a.ghostTex.DrawImage(a.tex, nil)
 			}
@@ -746,7 +759,7 @@ func (a *App) toast(msg string) {
 	a.toastUntil = time.Now().Add(2 * time.Second)
 }
 
-// findROMs returns a sorted list of ROM file paths from testroms/ (and current dir if .gb files found)
+// findROMs returns a sorted list of ROM and ROM archive paths from the configured ROMs dir
 func (a *App) findROMs() []string {
 	var files []string
 	addFrom := func(dir string) {
@@ -759,8 +772,7 @@ func (a *App) findROMs() []string {
 				continue
 			}
 			name := e.Name()
-			ln := strings.ToLower(name)
-			if strings.HasSuffix(ln, ".gb") || strings.HasSuffix(ln, ".gbc") {
+			if romfile.IsLoadable(name) {
 				files = append(files, filepath.Join(dir, name))
 			}
 		}
@@ -827,6 +839,9 @@ func loadSettings(override Config) Config {
 	if override.UseFetcherBG {
 		cfg.UseFetcherBG = true
 	}
+	if override.ClassicPPU {
+		cfg.ClassicPPU = true
+	}
 	if cfg.Title == "" && override.Title == "" {
 		cfg.Title = "gbemu"
 	}
@@ -890,8 +905,8 @@ func (a *App) Layout(outW, outH int) (int, int) {
 			}
 		}
 	}
-	a.curW, a.curH = 160, 144
-	return 160, 144
+	a.curW, a.curH = a.m.FrameSize()
+	return a.curW, a.curH
 }
 
 // maxCharsForText estimates how many characters fit on a line starting at left margin x.
@@ -954,10 +969,11 @@ func (a *App) wrapText(s string, max int) []string {
 
 func (a *App) saveScreenshot() error {
 	fb := a.m.Framebuffer()
+	w, h := a.m.FrameSize()
 	img := &image.RGBA{
 		Pix:    make([]byte, len(fb)),
-		Stride: 4 * 160,
-		Rect:   image.Rect(0, 0, 160, 144),
+		Stride: 4 * w,
+		Rect:   image.Rect(0, 0, w, h),
 	}
 	copy(img.Pix, fb)
 	ts := time.Now().Format("20060102_150405")
@@ -970,13 +986,36 @@ func (a *App) saveScreenshot() error {
 	return png.Encode(f, img)
 }
 
+// updateTilt drives the MBC7 accelerometer: the first gamepad's left stick when one is
+// connected, otherwise the mouse offset from the screen center while the left button is held.
+func (a *App) updateTilt() {
+	if !a.m.HasTilt() {
+		return
+	}
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			x := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+			y := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical)
+			a.m.SetTilt(x, y)
+			return
+		}
+	}
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) || a.curW == 0 || a.curH == 0 {
+		a.m.SetTilt(0, 0)
+		return
+	}
+	cx, cy := ebiten.CursorPosition()
+	hw, hh := float64(a.curW)/2, float64(a.curH)/2
+	a.m.SetTilt((float64(cx)-hw)/hw, (float64(cy)-hh)/hh)
+}
+
 // applyWindowSize recalculates the window size depending on overlay presence.
 // When overlay is enabled and larger than 160x144, the window scales the overlay by cfg.Scale; otherwise scales the game area.
 func (a *App) applyWindowSize() {
 	if a == nil {
 		return
 	}
-	baseW, baseH := 160, 144
+	baseW, baseH := a.m.FrameSize()
 	if a.cfg.ShellOverlay && a.shellImg != nil {
 		w, h := a.shellImg.Size()
 		if w > 0 && h > 0 {
@@ -60,9 +60,12 @@ func (a *App) drawSlotMenu(screen *ebiten.Image) {
 
 func (a *App) drawRomMenu(screen *ebiten.Image) {
 	ebitenutil.DebugPrintAt(screen, "Select ROM (Enter to load, Backspace/Esc to return)", 10, 10)
-	// show configured ROMs directory
-	d := a.cfg.ROMsDir
-	d = a.truncateText("Dir: "+d, a.maxCharsForText(10))
+	// show configured ROMs directory, or the archive being browsed
+	d := "Dir: " + a.cfg.ROMsDir
+	if a.romArchive != "" {
+		d = "Archive: " + filepath.Base(a.romArchive)
+	}
+	d = a.truncateText(d, a.maxCharsForText(10))
 	ebitenutil.DebugPrintAt(screen, d, 10, 24)
 	if len(a.romList) == 0 {
 		ebitenutil.DebugPrintAt(screen, "No ROMs found", 10, 40)
@@ -148,6 +151,17 @@ func (a *App) drawKeysMenu(screen *ebiten.Image) {
 	}
 }
 
+// rendererName returns the label of the active renderer for the settings menu.
+func (a *App) rendererName() string {
+	switch {
+	case a.cfg.UseFetcherBG:
+		return "Fetcher"
+	case a.cfg.ClassicPPU:
+		return "Classic"
+	}
+	return "Pixel FIFO"
+}
+
 func (a *App) drawSettingsMenu(screen *ebiten.Image) {
 	title := "Settings (Up/Down select; Left/Right change; Enter: edit/apply; Backspace/Esc: back)"
 	cursorY := 10
@@ -165,7 +179,7 @@ func (a *App) drawSettingsMenu(screen *ebiten.Image) {
 		fmt.Sprintf("Audio: %s", map[bool]string{true: "Stereo", false: "Mono"}[a.cfg.AudioStereo]),
 		fmt.Sprintf("Audio Adaptive: %s", map[bool]string{true: "On", false: "Off"}[a.cfg.AudioAdaptive]),
 		fmt.Sprintf("Low-Latency Audio: %s", map[bool]string{true: "On", false: "Off"}[a.cfg.AudioLowLatency]),
-		fmt.Sprintf("BG Renderer: %s", map[bool]string{true: "Fetcher", false: "Classic"}[a.cfg.UseFetcherBG]),
+		fmt.Sprintf("BG Renderer: %s", a.rendererName()),
 		fmt.Sprintf("Shader: %s", map[string]string{"off": "Off", "lcd": "LCD", "crt": "CRT", "ghost": "Ghost", "dot": "Dot"}[a.cfg.ShaderPreset]),
 		fmt.Sprintf("Jitter: %s", map[bool]string{true: "On", false: "Off"}[a.cfg.Jitter]),
 		fmt.Sprintf("ROMs Dir: %s", a.truncateText(romDir, a.maxCharsForText(10)-11)),
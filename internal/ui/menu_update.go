@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/FabianRolfMatthiasNoll/GameBoyEmulator/internal/romfile"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
@@ -40,6 +41,7 @@ func (a *App) updateMainMenu() {
 			a.menuMode = "slot"
 			a.menuIdx = a.currentSlot
 		case 3:
+			a.romArchive = ""
 			a.romList = a.findROMs()
 			a.romSel = 0
 			a.romOff = 0
@@ -111,19 +113,30 @@ func (a *App) updateRomMenu() {
 		a.romOff = n - 1
 	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-		path := a.romList[a.romSel]
-		if err := a.m.LoadROMFromFile(path); err == nil {
+		path, entry := a.romList[a.romSel], ""
+		if a.romArchive != "" {
+			path, entry = a.romArchive, a.romList[a.romSel]
+		} else if strings.HasSuffix(strings.ToLower(path), ".zip") {
+			// Zips with several ROMs open as a sub-list to pick from
+			if names, err := romfile.Entries(path); err == nil && len(names) > 1 {
+				a.romFiles = a.romList
+				a.romArchive = path
+				a.romList = names
+				a.romSel, a.romOff = 0, 0
+				return
+			}
+		}
+		if err := a.m.LoadROMFromArchive(path, entry); err == nil {
 			a.toast("Loaded ROM: " + filepath.Base(path))
-			if strings.HasSuffix(strings.ToLower(path), ".gb") {
-				sav := strings.TrimSuffix(path, ".gb") + ".sav"
-				if data, err := os.ReadFile(sav); err == nil {
-					_ = a.m.LoadBattery(data)
-				}
+			if data, err := os.ReadFile(romfile.SavePath(a.m.ROMPath())); err == nil {
+				_ = a.m.LoadBattery(data)
 			}
 			// If user has CGB Colors toggled for a DMG ROM, restart into CGB compat now
 			if a.m.WantCGBColors() && !a.m.UseCGBBG() {
 				a.m.ResetCGBPostBoot(true)
 			}
+			// SGB games on an SGB model grow the picture by the border
+			a.applyWindowSize()
 			// Update window title with game title
 			title := a.cfg.Title
 			if t := a.m.ROMTitle(); t != "" {
@@ -139,9 +152,22 @@ func (a *App) updateRomMenu() {
 		} else {
 			a.toast("ROM load failed: " + err.Error())
 		}
+		a.romArchive = ""
 		a.menuMode = "main"
 	}
 	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		if a.romArchive != "" {
+			// Back from an archive's entries to the file list
+			a.romList = a.romFiles
+			a.romSel, a.romOff = 0, 0
+			for i, p := range a.romList {
+				if p == a.romArchive {
+					a.romSel = i
+				}
+			}
+			a.romArchive = ""
+			return
+		}
 		a.menuMode = "main"
 	}
 }
@@ -247,9 +273,18 @@ func (a *App) updateSettingsMenu() {
 		}
 	} else if a.menuIdx == 4 && !a.editingROMDir { // BG Renderer
 		if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) || inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
-			a.cfg.UseFetcherBG = !a.cfg.UseFetcherBG
+			// Cycle Pixel FIFO -> Classic -> Fetcher
+			switch {
+			case a.cfg.UseFetcherBG:
+				a.cfg.ClassicPPU, a.cfg.UseFetcherBG = false, false
+			case a.cfg.ClassicPPU:
+				a.cfg.ClassicPPU, a.cfg.UseFetcherBG = true, true
+			default:
+				a.cfg.ClassicPPU = true
+			}
 			if a.m != nil {
 				a.m.SetUseFetcherBG(a.cfg.UseFetcherBG)
+				a.m.SetClassicPPU(a.cfg.ClassicPPU)
 			}
 			a.saveSettings()
 		}